@@ -2,36 +2,80 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/Graylog2/go-gelf/gelf"
-
+	"myip/internal/apikey"
+	"myip/internal/asn"
 	"myip/internal/config"
+	"myip/internal/dns"
+	"myip/internal/dnsbl"
+	"myip/internal/dnsleak"
+	"myip/internal/dualstack"
+	"myip/internal/fingerprint"
+	"myip/internal/gelf"
+	"myip/internal/geoip"
+	"myip/internal/hosting"
+	"myip/internal/listen"
+	"myip/internal/mmdb"
+	"myip/internal/ratelimit"
 	"myip/internal/rdap"
+	"myip/internal/rdns"
+	"myip/internal/risk"
 	"myip/internal/store"
+	"myip/internal/stun"
+	"myip/internal/systemd"
+	"myip/internal/tor"
+	"myip/internal/useragent"
 	"myip/internal/web"
+	"myip/internal/whoami"
 )
 
-const shutdownTimeout = 5 * time.Second
+const (
+	shutdownTimeout = 5 * time.Second
+
+	// STUN bindings are matched with the candidates the page posts right
+	// after gathering them.
+	stunObservationTTL  = 2 * time.Minute
+	maxSTUNObservations = 100000
+)
 
 func main() {
+	resetStats := flag.Bool("reset-fingerprint-stats", false, "clear fingerprint attribute statistics and exit")
+	flag.Parse()
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
 
 	logger := initLogger(cfg)
+	defer logger.Close()
 
+	systemd.Status("connecting to redis %s", cfg.Redis)
 	redisStore := store.NewRedisStore(cfg.Redis, cfg.RedisUser, cfg.RedisPass)
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	if err := redisStore.Ping(ctx); err != nil {
 		logger.Fatalf("redis error: %v", err)
 	}
+	if *resetStats {
+		if err := fingerprint.NewStats(redisStore, 0).Reset(ctx); err != nil {
+			logger.Fatalf("redis error: %v", err)
+		}
+		logger.Printf("fingerprint statistics cleared")
+		return
+	}
 
 	templates, err := web.ParseTemplates()
 	if err != nil {
@@ -51,22 +95,436 @@ func main() {
 	}
 
 	service := web.NewService(redisStore, rdapClient, onError)
-	handler := web.NewHandler(templates, service)
+	dnsClient := dns.NewClient(cfg.DNSResolver)
+	var reverseDNS *rdns.Resolver
+	if cfg.RDNSEnabled {
+		reverseDNS = rdns.NewResolver(dnsClient, redisStore)
+		service.SetReverseDNS(reverseDNS)
+	}
+	if cfg.DNSBLEnabled {
+		dnsblClient := dnsClient
+		if cfg.DNSBLResolver != "" {
+			dnsblClient = dns.NewClient(cfg.DNSBLResolver)
+		}
+		service.SetReputation(dnsbl.NewChecker(dnsblClient, cfg.DNSBLZones, redisStore))
+	}
+	var geoFile *mmdb.File
+	if cfg.GeoIPDB != "" {
+		geoFile, err = mmdb.OpenFile(cfg.GeoIPDB)
+		if err != nil {
+			logger.Fatalf("geoip error: %v", err)
+		}
+		service.SetGeoIP(geoip.New(geoFile))
+	}
+	var asnData *asn.Dataset
+	if cfg.ASNDB != "" {
+		asnData, err = asn.OpenDataset(cfg.ASNDB)
+		if err != nil {
+			logger.Fatalf("asn error: %v", err)
+		}
+		var autnum asn.AutnumLookup
+		if cfg.RDAPAutnumAPI != "" {
+			autnum = rdap.NewAutnumClient(cfg.RDAPAutnumAPI)
+		}
+		service.SetASN(asn.NewResolver(asnData, autnum, redisStore))
+	}
+	var cloudRanges *hosting.Ranges
+	if cfg.CloudRangesDir != "" {
+		cloudRanges, err = hosting.OpenDir(cfg.CloudRangesDir)
+		if err != nil {
+			logger.Fatalf("cloud ranges error: %v", err)
+		}
+		logger.Printf("loaded %d cloud ranges from %s", cloudRanges.Len(), cfg.CloudRangesDir)
+		service.SetHosting(cloudRanges)
+	}
+	var torExits *tor.List
+	if cfg.TorExitList != "" {
+		torExits = tor.NewList(cfg.TorExitList, uint16(cfg.TorExitPort))
+		service.SetTorExits(torExits)
+	}
+	fingerprints := fingerprint.NewHistory(redisStore)
+	var fingerprintStats web.FingerprintStats
+	if cfg.FingerprintStatsLimit > 0 {
+		fingerprintStats = fingerprint.NewStats(redisStore, cfg.FingerprintStatsLimit)
+	}
+	webHandler := web.NewHandler(templates, service)
+	webHandler.SetFingerprints(fingerprints)
+	var handler http.Handler = webHandler
+
+	userAgents := useragent.Default()
+	if cfg.UserAgentRegexes != "" {
+		userAgents, err = useragent.Open(cfg.UserAgentRegexes)
+		if err != nil {
+			logger.Fatalf("user agent regexes error: %v", err)
+		}
+	}
+	if skipped := userAgents.Skipped(); skipped > 0 {
+		logger.Printf("user agent regexes: skipped %d patterns unsupported by Go regexp", skipped)
+	}
+	if reverseDNS != nil {
+		webHandler.SetUserAgents(userAgents, reverseDNS)
+	} else {
+		webHandler.SetUserAgents(userAgents, nil)
+	}
+
+	var fileKeys map[string]apikey.Key
+	if cfg.APIKeysFile != "" {
+		fileKeys, err = apikey.LoadFile(cfg.APIKeysFile)
+		if err != nil {
+			logger.Fatalf("api keys error: %v", err)
+		}
+	}
+	keys := apikey.NewManager(redisStore, fileKeys)
+	webHandler.Handle("GET /api/usage", web.UsageHandler(keys, onError))
+	webHandler.Handle("GET "+web.PrefixPath+"{cidr...}", web.PrefixHandler(service, onError))
+	webHandler.Handle("POST "+web.SummarizePath, web.SummarizeHandler(cfg.BatchMaxItems, onError))
+	weights, err := risk.ParseWeights(cfg.RiskWeights)
+	if err != nil {
+		logger.Fatalf("config error: %v", err)
+	}
+	riskEngine, err := risk.NewEngine(weights)
+	if err != nil {
+		logger.Fatalf("config error: %v", err)
+	}
+	webHandler.Handle("POST "+web.RiskPath, web.RiskHandler(service, riskEngine, onError))
+	webHandler.Handle("POST "+web.FingerprintPath, web.FingerprintHandler(fingerprints, fingerprintStats, onError))
+	var stunListeners *stunSockets
+	var stunObservations *stun.Observations
+	var stunSeen web.STUNObservations
+	if cfg.STUNAddr != "" {
+		stunListeners, err = openSTUN(cfg)
+		if err != nil {
+			logger.Fatalf("stun error: %v", err)
+		}
+		stunObservations = stun.NewObservations(stunObservationTTL, maxSTUNObservations)
+		stunSeen = stunObservations
+		port := cfg.STUNPort
+		if port == 0 {
+			port = stunListeners.packets[0].LocalAddr().(*net.UDPAddr).Port
+		}
+		webHandler.SetSTUNPort(port)
+	}
+	webHandler.Handle("POST "+web.WebRTCPath, web.WebRTCHandler(stunSeen, onError))
+	var dnsLeak *dnsleak.Test
+	if cfg.DNSLeakZone != "" {
+		addrs := make([]netip.Addr, 0, len(cfg.DNSLeakAddrs))
+		for _, raw := range cfg.DNSLeakAddrs {
+			addr, err := netip.ParseAddr(raw)
+			if err != nil {
+				logger.Fatalf("config error: DNS_LEAK_ADDRS: %v", err)
+			}
+			addrs = append(addrs, addr.Unmap())
+		}
+		dnsLeak = dnsleak.New(cfg.DNSLeakZone, addrs, redisStore)
+		webHandler.SetDNSLeak(true)
+		webHandler.Handle("POST "+web.DNSLeakPath, web.DNSLeakStartHandler(dnsLeak, onError))
+		webHandler.Handle("GET "+web.DNSLeakPath+"/{id}", web.DNSLeakResultHandler(dnsLeak, service, redisStore, onError))
+	}
+	if cfg.DualStackIPv4Host != "" {
+		dualStack := dualstack.New(dualstack.Hosts{IPv4: cfg.DualStackIPv4Host, IPv6: cfg.DualStackIPv6Host}, redisStore)
+		webHandler.SetDualStack(true)
+		webHandler.Handle("POST "+web.DualStackPath, web.DualStackStartHandler(dualStack, onError))
+		webHandler.Handle("GET "+web.DualStackPath+"/{id}/{probe}", web.DualStackProbeHandler(dualStack, onError))
+		webHandler.Handle("POST "+web.DualStackPath+"/{id}", web.DualStackSummaryHandler(dualStack, onError))
+	}
+	limiter, err := newLimiter(cfg, redisStore)
+	if err != nil {
+		logger.Fatalf("config error: %v", err)
+	}
+	batchOptions := web.BatchOptions{
+		MaxItems:    cfg.BatchMaxItems,
+		Concurrency: cfg.BatchConcurrency,
+	}
+	if cfg.APIKeysRequired {
+		batchOptions.Keys = keys
+	}
+	if limiter != nil {
+		batchOptions.Limiter = limiter
+	}
+	webHandler.Handle("POST "+web.BatchPath, web.BatchHandler(service, batchOptions))
+	// Keys are checked first so that lookups with a key are limited per
+	// key rather than per address.
+	if limiter != nil {
+		handler = web.RateLimit(handler, limiter, onError)
+	}
+	if cfg.APIKeysRequired {
+		handler = web.RequireAPIKey(handler, keys, onError)
+	}
+	proxies, err := web.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Fatalf("config error: TRUSTED_PROXIES: %v", err)
+	}
+	handler = web.TrustProxies(handler, proxies)
+
+	var dnsConn net.PacketConn
+	var dnsListener net.Listener
+	if cfg.DNSListen != "" {
+		if dnsConn, err = net.ListenPacket("udp", cfg.DNSListen); err != nil {
+			logger.Fatalf("dns listen error: %v", err)
+		}
+		if dnsListener, err = net.Listen("tcp", cfg.DNSListen); err != nil {
+			logger.Fatalf("dns listen error: %v", err)
+		}
+	}
+
+	listeners, err := openListeners(cfg)
+	if err != nil {
+		logger.Fatalf("listen error: %v", err)
+	}
 
 	server := &http.Server{
-		Addr:              cfg.WebAddr,
 		Handler:           handler,
 		ReadHeaderTimeout: 3 * time.Second,
+		ConnContext:       listen.ConnContext,
+	}
+
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go systemd.Watchdog(runCtx, redisStore.Ping, onError)
+	if geoFile != nil && cfg.GeoIPReload > 0 {
+		go geoFile.Watch(runCtx, cfg.GeoIPReload, onError)
+	}
+	if asnData != nil && cfg.ASNReload > 0 {
+		go asnData.Watch(runCtx, cfg.ASNReload, onError)
+	}
+	if cloudRanges != nil && cfg.CloudRangesReload > 0 {
+		go cloudRanges.Watch(runCtx, cfg.CloudRangesReload, onError)
 	}
+	if torExits != nil {
+		// A slow or unreachable list must not delay the listeners; until
+		// the first load finishes no address is reported as Tor.
+		go func() {
+			if err := torExits.Refresh(runCtx); err != nil {
+				onError(fmt.Errorf("tor exit list: %w", err))
+			}
+			if cfg.TorExitRefresh > 0 {
+				torExits.Watch(runCtx, cfg.TorExitRefresh, onError)
+			}
+		}()
+	}
+	if stunListeners != nil {
+		count := stun.CountBindings(runCtx, service, onError)
+		stunServer := stun.NewServer(func(b stun.Binding) {
+			stunObservations.Record(b)
+			count(b)
+		})
+		if stunListeners.alternate.IsValid() {
+			stunServer.SetAlternate(stunListeners.primary, stunListeners.alternate)
+		}
+		for _, conn := range stunListeners.packets {
+			go func(conn net.PacketConn) {
+				if err := stunServer.ServePacket(runCtx, conn); err != nil {
+					onError(fmt.Errorf("stun: %w", err))
+				}
+			}(conn)
+			logger.Printf("stun on udp %s", conn.LocalAddr())
+		}
+		if stunListeners.stream != nil {
+			go func() {
+				if err := stunServer.ServeStream(runCtx, stunListeners.stream); err != nil {
+					onError(fmt.Errorf("stun: %w", err))
+				}
+			}()
+			logger.Printf("stun on tcp %s", stunListeners.stream.Addr())
+		}
+	}
+	if dnsConn != nil {
+		mux := dns.NewMux()
+		if cfg.DNSWhoamiName != "" {
+			mux.Handle(cfg.DNSWhoamiName, whoami.New(cfg.DNSWhoamiName))
+			logger.Printf("dns whoami %s on %s", dns.CanonicalName(cfg.DNSWhoamiName), cfg.DNSListen)
+		}
+		if dnsLeak != nil {
+			mux.Handle(cfg.DNSLeakZone, dnsLeak)
+			go dnsLeak.Run(runCtx, onError)
+			logger.Printf("dns leak zone %s on %s", dns.CanonicalName(cfg.DNSLeakZone), cfg.DNSListen)
+		}
+		dnsServer := dns.NewServer(mux)
+		go func() {
+			if err := dnsServer.ServePacket(runCtx, dnsConn); err != nil {
+				onError(fmt.Errorf("dns server: %w", err))
+			}
+		}()
+		go func() {
+			if err := dnsServer.ServeStream(runCtx, dnsListener); err != nil {
+				onError(fmt.Errorf("dns server: %w", err))
+			}
+		}()
+	}
+	idle := make(chan struct{})
+	go func() {
+		defer close(idle)
+		<-runCtx.Done()
+		systemd.Notify("STOPPING=1")
+		logger.Printf("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			onError(err)
+		}
+	}()
 
-	logger.Printf("listening on %s", cfg.WebAddr)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	serveErrors := make(chan error, len(listeners))
+	addrs := make([]string, 0, len(listeners))
+	for _, ln := range listeners {
+		addrs = append(addrs, ln.Addr().String())
+		go func(ln net.Listener) {
+			if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+				serveErrors <- err
+			}
+		}(ln)
+	}
+
+	logger.Printf("listening on %s", strings.Join(addrs, ", "))
+	if err := systemd.Notify("READY=1\nSTATUS=serving on " + strings.Join(addrs, ", ")); err != nil {
+		onError(err)
+	}
+
+	select {
+	case err := <-serveErrors:
 		logger.Fatalf("server error: %v", err)
+	case <-idle:
+	}
+}
+
+// stunSockets are the listeners of the STUN server.
+type stunSockets struct {
+	packets            []net.PacketConn
+	stream             net.Listener
+	primary, alternate netip.AddrPort
+}
+
+// openSTUN listens on STUN_ADDR over UDP and, unless disabled, TCP. With
+// STUN_ALT_ADDR it also opens the three extra UDP sockets RFC 5780 needs
+// for NAT behaviour discovery.
+func openSTUN(cfg config.Config) (*stunSockets, error) {
+	sockets := &stunSockets{}
+	addrs := []string{cfg.STUNAddr}
+	if cfg.STUNAltAddr != "" {
+		primary, err := netip.ParseAddrPort(cfg.STUNAddr)
+		if err != nil {
+			return nil, fmt.Errorf("STUN_ADDR must be an explicit ip:port with STUN_ALT_ADDR: %w", err)
+		}
+		alternate, err := netip.ParseAddrPort(cfg.STUNAltAddr)
+		if err != nil {
+			return nil, fmt.Errorf("STUN_ALT_ADDR: %w", err)
+		}
+		if primary.Addr().IsUnspecified() || primary.Addr() == alternate.Addr() || primary.Port() == alternate.Port() ||
+			primary.Addr().Is4() != alternate.Addr().Is4() {
+			return nil, fmt.Errorf("STUN_ADDR and STUN_ALT_ADDR need different IPs of the same family and different ports")
+		}
+		sockets.primary, sockets.alternate = primary, alternate
+		addrs = addrs[:0]
+		for _, addr := range stun.AlternateAddrs(primary, alternate) {
+			addrs = append(addrs, addr.String())
+		}
+	}
+
+	closeAll := func() {
+		for _, conn := range sockets.packets {
+			conn.Close()
+		}
+	}
+	for _, addr := range addrs {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sockets.packets = append(sockets.packets, conn)
+	}
+	if cfg.STUNTCP {
+		ln, err := net.Listen("tcp", cfg.STUNAddr)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sockets.stream = ln
+	}
+	return sockets, nil
+}
+
+// newLimiter builds the rate limiter, or returns nil when no limit is set.
+func newLimiter(cfg config.Config, backend ratelimit.Backend) (*ratelimit.Limiter, error) {
+	rules := ratelimit.Rules{Limits: map[ratelimit.Class]ratelimit.Limit{}}
+	enabled := false
+	for class, raw := range map[ratelimit.Class]string{
+		ratelimit.ClassPage:   cfg.RateLimitPage,
+		ratelimit.ClassAPI:    cfg.RateLimitAPI,
+		ratelimit.ClassLookup: cfg.RateLimitLookup,
+	} {
+		limit, err := ratelimit.ParseLimit(raw)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %s: %w", class, err)
+		}
+		rules.Limits[class] = limit
+		enabled = enabled || limit.Enabled()
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	allowlist, err := ratelimit.ParseAllowlist(cfg.RateLimitAllow)
+	if err != nil {
+		return nil, err
+	}
+	rules.Allowlist = allowlist
+	return ratelimit.New(backend, rules), nil
+}
+
+// openListeners prefers sockets inherited from systemd socket activation
+// and falls back to binding every WEB address itself. Each listener is
+// tagged so handlers can report where the client connected.
+func openListeners(cfg config.Config) ([]net.Listener, error) {
+	inherited, names, err := systemd.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(inherited) > 0 {
+		for i, ln := range inherited {
+			inherited[i] = listen.Tag(ln, names[i])
+		}
+		return inherited, nil
+	}
+
+	listeners := make([]net.Listener, 0, len(cfg.WebAddrs))
+	for _, addr := range cfg.WebAddrs {
+		ln, err := listen.Open(addr, cfg.WebSocketMode)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listen.Tag(ln, addr))
+	}
+	return listeners, nil
+}
+
+// appLogger is a log.Logger whose Fatalf flushes asynchronous writers
+// before exiting, so the message explaining the exit is not lost.
+type appLogger struct {
+	*log.Logger
+	closer io.Closer
+}
+
+func (l *appLogger) Fatalf(format string, v ...any) {
+	l.Output(2, fmt.Sprintf(format, v...))
+	l.Close()
+	os.Exit(1)
+}
+
+// Close flushes and releases the log writer if it needs it.
+func (l *appLogger) Close() {
+	if l.closer != nil {
+		l.closer.Close()
 	}
 }
 
-func initLogger(cfg config.Config) *log.Logger {
+func initLogger(cfg config.Config) *appLogger {
 	var writer io.Writer
+	var closer io.Closer
 	var err error
 
 	switch cfg.LogType {
@@ -81,10 +539,18 @@ func initLogger(cfg config.Config) *log.Logger {
 			log.Printf("GELF address is not specified, falling back to stderr")
 			writer = os.Stderr
 		} else {
-			writer, err = gelf.NewWriter(cfg.LogAddr)
+			gelfWriter, err := gelf.New(gelf.Config{
+				Addr:        cfg.LogAddr,
+				Compression: cfg.GELFCompression,
+				ChunkSize:   cfg.GELFChunkSize,
+				BufferSize:  cfg.GELFBufferSize,
+				Fields:      cfg.LogFields,
+			})
 			if err != nil {
 				log.Printf("failed to initialize GELF: %v, falling back to stderr", err)
 				writer = os.Stderr
+			} else {
+				writer, closer = gelfWriter, gelfWriter
 			}
 		}
 	case "system":
@@ -105,5 +571,5 @@ func initLogger(cfg config.Config) *log.Logger {
 		flags = 0 // Remote loggers usually handle timestamps themselves
 	}
 
-	return log.New(writer, "", flags)
+	return &appLogger{Logger: log.New(writer, "", flags), closer: closer}
 }
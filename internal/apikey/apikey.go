@@ -0,0 +1,202 @@
+package apikey
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dayLayout   = "2006-01-02"
+	historyDays = 30
+	idLength    = 12
+)
+
+// Errors returned by Authorize.
+var (
+	ErrMissingKey    = errors.New("api key required")
+	ErrInvalidKey    = errors.New("invalid api key")
+	ErrQuotaExceeded = errors.New("daily quota exceeded")
+)
+
+// Key is an API key as stored at rest; the secret itself is never kept.
+type Key struct {
+	ID         string
+	Name       string
+	Hash       string
+	DailyQuota int64
+}
+
+// Quota describes the state of a key's daily quota after a request.
+type Quota struct {
+	Key       Key
+	Used      int64
+	Remaining int64
+	Reset     time.Duration
+}
+
+// DayUsage is the request count of a key for one UTC day.
+type DayUsage struct {
+	Day   string `json:"day"`
+	Count int64  `json:"count"`
+}
+
+// Store persists keys and per-day usage counters.
+type Store interface {
+	FindAPIKey(ctx context.Context, hash string) (string, int64, bool, error)
+	IncrementUsage(ctx context.Context, id, day string, n, quota int64) (int64, bool, error)
+	GetUsage(ctx context.Context, id string, days []string) ([]int64, error)
+}
+
+// Hash returns the hex SHA-256 of a key secret, the form keys are stored in.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadFile reads keys from a file with one "name hash quota" entry per line.
+// Lines starting with # are ignored.
+func LoadFile(path string) (map[string]Key, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	keys := map[string]Key{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid api key line: %s", line)
+		}
+		hash := strings.ToLower(fields[1])
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid api key hash for %s", fields[0])
+		}
+		quota, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid api key quota for %s: %w", fields[0], err)
+		}
+		keys[hash] = newKey(fields[0], hash, quota)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan api key file: %w", err)
+	}
+	return keys, nil
+}
+
+// Manager authorizes keys from a file and from the store and counts usage.
+type Manager struct {
+	store Store
+	keys  map[string]Key
+	now   func() time.Time
+}
+
+// NewManager creates a Manager. Keys from the file take precedence over
+// keys stored in Redis.
+func NewManager(store Store, fileKeys map[string]Key) *Manager {
+	if fileKeys == nil {
+		fileKeys = map[string]Key{}
+	}
+	return &Manager{store: store, keys: fileKeys, now: time.Now}
+}
+
+// Find resolves a secret to its key.
+func (m *Manager) Find(ctx context.Context, secret string) (Key, error) {
+	if secret == "" {
+		return Key{}, ErrMissingKey
+	}
+
+	hash := Hash(secret)
+	if key, ok := m.keys[hash]; ok {
+		return key, nil
+	}
+
+	name, quota, ok, err := m.store.FindAPIKey(ctx, hash)
+	if err != nil {
+		return Key{}, err
+	}
+	if !ok {
+		return Key{}, ErrInvalidKey
+	}
+	return newKey(name, hash, quota), nil
+}
+
+// Authorize resolves the secret, counts the request against today's quota
+// and returns ErrQuotaExceeded once the quota is used up. Rejected requests
+// are not counted. A zero quota means unlimited.
+func (m *Manager) Authorize(ctx context.Context, secret string) (Quota, error) {
+	return m.AuthorizeN(ctx, secret, 1)
+}
+
+// AuthorizeN is Authorize for a request that counts as n lookups.
+func (m *Manager) AuthorizeN(ctx context.Context, secret string, n int64) (Quota, error) {
+	key, err := m.Find(ctx, secret)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	now := m.now().UTC()
+	used, ok, err := m.store.IncrementUsage(ctx, key.ID, now.Format(dayLayout), n, key.DailyQuota)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	quota := Quota{Key: key, Used: used, Reset: untilMidnight(now)}
+	if key.DailyQuota > 0 {
+		quota.Remaining = max(key.DailyQuota-used, 0)
+	}
+	if !ok {
+		return quota, ErrQuotaExceeded
+	}
+	return quota, nil
+}
+
+// Usage returns the key and its request counts for the last 30 days,
+// newest first.
+func (m *Manager) Usage(ctx context.Context, secret string) (Key, []DayUsage, error) {
+	key, err := m.Find(ctx, secret)
+	if err != nil {
+		return Key{}, nil, err
+	}
+
+	now := m.now().UTC()
+	days := make([]string, 0, historyDays)
+	for i := 0; i < historyDays; i++ {
+		days = append(days, now.AddDate(0, 0, -i).Format(dayLayout))
+	}
+
+	counts, err := m.store.GetUsage(ctx, key.ID, days)
+	if err != nil {
+		return Key{}, nil, err
+	}
+
+	usage := make([]DayUsage, 0, len(days))
+	for i, day := range days {
+		usage = append(usage, DayUsage{Day: day, Count: counts[i]})
+	}
+	return key, usage, nil
+}
+
+// newKey derives the public ID from the hash so usage counters never
+// reveal the secret.
+func newKey(name, hash string, quota int64) Key {
+	return Key{ID: hash[:idLength], Name: name, Hash: hash, DailyQuota: quota}
+}
+
+func untilMidnight(now time.Time) time.Duration {
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(now)
+}
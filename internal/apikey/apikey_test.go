@@ -0,0 +1,119 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	keys  map[string]int64
+	usage map[string]int64
+}
+
+func (m *memoryStore) FindAPIKey(ctx context.Context, hash string) (string, int64, bool, error) {
+	quota, ok := m.keys[hash]
+	return "redis-key", quota, ok, nil
+}
+
+func (m *memoryStore) IncrementUsage(ctx context.Context, id, day string, n, quota int64) (int64, bool, error) {
+	used := m.usage[id+":"+day]
+	if quota > 0 && used+n > quota {
+		return used, false, nil
+	}
+	m.usage[id+":"+day] = used + n
+	return used + n, true, nil
+}
+
+func (m *memoryStore) GetUsage(ctx context.Context, id string, days []string) ([]int64, error) {
+	counts := make([]int64, len(days))
+	for i, day := range days {
+		counts[i] = m.usage[id+":"+day]
+	}
+	return counts, nil
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	content := "# name hash quota\nsecurity " + Hash("s3cret") + " 100\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	key, ok := keys[Hash("s3cret")]
+	if !ok {
+		t.Fatal("expected key to be loaded")
+	}
+	if key.Name != "security" || key.DailyQuota != 100 || len(key.ID) != idLength {
+		t.Errorf("unexpected key %+v", key)
+	}
+
+	if err := os.WriteFile(path, []byte("broken plaintext 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(path); err == nil {
+		t.Error("expected error for non-hash entry")
+	}
+}
+
+func TestManager_Authorize(t *testing.T) {
+	store := &memoryStore{keys: map[string]int64{Hash("redis"): 0}, usage: map[string]int64{}}
+	fileKeys := map[string]Key{Hash("file"): newKey("file-key", Hash("file"), 2)}
+	m := NewManager(store, fileKeys)
+	m.now = func() time.Time { return time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	if _, err := m.Authorize(ctx, ""); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("expected ErrMissingKey, got %v", err)
+	}
+	if _, err := m.Authorize(ctx, "unknown"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey, got %v", err)
+	}
+
+	quota, err := m.Authorize(ctx, "file")
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if quota.Used != 1 || quota.Remaining != 1 || quota.Reset != time.Hour {
+		t.Errorf("unexpected quota %+v", quota)
+	}
+	m.Authorize(ctx, "file")
+	if _, err := m.Authorize(ctx, "file"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+
+	batch := NewManager(store, map[string]Key{Hash("batch"): newKey("batch-key", Hash("batch"), 10)})
+	if quota, err := batch.AuthorizeN(ctx, "batch", 8); err != nil || quota.Remaining != 2 {
+		t.Errorf("expected 8 of 10 used, got %+v, %v", quota, err)
+	}
+	if _, err := batch.AuthorizeN(ctx, "batch", 3); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected a batch over the quota to be rejected, got %v", err)
+	}
+	if quota, err := batch.AuthorizeN(ctx, "batch", 2); err != nil || quota.Used != 10 || quota.Remaining != 0 {
+		t.Errorf("expected the rejected batch not to be counted, got %+v, %v", quota, err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := m.Authorize(ctx, "redis"); err != nil {
+			t.Fatalf("expected unlimited redis key, got %v", err)
+		}
+	}
+
+	key, usage, err := m.Usage(ctx, "redis")
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if key.Name != "redis-key" || len(usage) != historyDays {
+		t.Fatalf("unexpected usage for %+v: %d days", key, len(usage))
+	}
+	if usage[0].Day != "2024-05-01" || usage[0].Count != 5 {
+		t.Errorf("unexpected today usage %+v", usage[0])
+	}
+}
@@ -0,0 +1,107 @@
+package asn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"myip/internal/rdap"
+)
+
+const autnumTTL = 24 * time.Hour
+
+// Info is the autonomous system view of an address.
+type Info struct {
+	Number  uint32 `json:"asn"`
+	Name    string `json:"as_name,omitempty"`
+	Org     string `json:"as_org,omitempty"`
+	Country string `json:"as_country,omitempty"`
+	Prefix  string `json:"as_prefix,omitempty"`
+}
+
+// Origins maps addresses to their announced prefix and origin AS.
+type Origins interface {
+	Origin(addr netip.Addr) (Route, bool, error)
+}
+
+// AutnumLookup fetches RDAP data for an AS number.
+type AutnumLookup interface {
+	Lookup(ctx context.Context, asn uint32) (rdap.Autnum, error)
+}
+
+// Cache stores autnum results as JSON values with a TTL.
+type Cache interface {
+	GetValue(ctx context.Context, key string, v any) (bool, error)
+	SetValue(ctx context.Context, key string, v any, ttl time.Duration) error
+}
+
+// Resolver combines a local dataset with cached RDAP autnum lookups.
+type Resolver struct {
+	origins Origins
+	autnum  AutnumLookup
+	cache   Cache
+}
+
+// NewResolver creates a resolver. autnum and cache may be nil.
+func NewResolver(origins Origins, autnum AutnumLookup, cache Cache) *Resolver {
+	return &Resolver{origins: origins, autnum: autnum, cache: cache}
+}
+
+// Lookup returns AS information for ip. ok is false when the address is
+// not routed. RDAP and cache errors are returned together with the
+// dataset result.
+func (r *Resolver) Lookup(ctx context.Context, ip string) (Info, bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Info{}, false, fmt.Errorf("asn: invalid address %q", ip)
+	}
+
+	route, ok, err := r.origins.Origin(addr)
+	if err != nil || !ok {
+		return Info{}, false, err
+	}
+
+	info := Info{Number: route.ASN, Name: route.Name, Country: route.Country}
+	if route.Prefix.IsValid() {
+		info.Prefix = route.Prefix.String()
+	}
+	if r.autnum == nil {
+		return info, true, nil
+	}
+
+	autnum, err := r.lookupAutnum(ctx, route.ASN)
+	if autnum.Name != "" {
+		info.Name = autnum.Name
+	}
+	info.Org = autnum.Org
+	if autnum.Country != "" {
+		info.Country = autnum.Country
+	}
+	return info, true, err
+}
+
+func (r *Resolver) lookupAutnum(ctx context.Context, asn uint32) (rdap.Autnum, error) {
+	key := "autnum:" + strconv.FormatUint(uint64(asn), 10)
+	var cacheErr error
+	if r.cache != nil {
+		var cached rdap.Autnum
+		ok, err := r.cache.GetValue(ctx, key, &cached)
+		if err != nil {
+			cacheErr = err
+		} else if ok {
+			return cached, nil
+		}
+	}
+
+	autnum, err := r.autnum.Lookup(ctx, asn)
+	if err != nil {
+		return rdap.Autnum{}, errors.Join(fmt.Errorf("rdap autnum: %w", err), cacheErr)
+	}
+	if r.cache != nil && cacheErr == nil {
+		cacheErr = r.cache.SetValue(ctx, key, autnum, autnumTTL)
+	}
+	return autnum, cacheErr
+}
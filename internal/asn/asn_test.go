@@ -0,0 +1,88 @@
+package asn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"myip/internal/rdap"
+)
+
+type staticOrigins map[string]Route
+
+func (s staticOrigins) Origin(addr netip.Addr) (Route, bool, error) {
+	route, ok := s[addr.String()]
+	return route, ok, nil
+}
+
+type countingAutnum struct {
+	calls int
+	err   error
+}
+
+func (c *countingAutnum) Lookup(ctx context.Context, asn uint32) (rdap.Autnum, error) {
+	c.calls++
+	return rdap.Autnum{Handle: "AS15169", Name: "GOOGLE", Org: "Google LLC", Country: "US"}, c.err
+}
+
+type memoryCache map[string][]byte
+
+func (m memoryCache) GetValue(ctx context.Context, key string, v any) (bool, error) {
+	data, ok := m[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (m memoryCache) SetValue(ctx context.Context, key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	m[key] = data
+	return err
+}
+
+func TestResolver_Lookup(t *testing.T) {
+	origins := staticOrigins{
+		"8.8.8.8": {Prefix: netip.MustParsePrefix("8.8.8.0/24"), ASN: 15169, Name: "GOOGLE - Google LLC"},
+	}
+	autnum := &countingAutnum{}
+	cache := memoryCache{}
+	r := NewResolver(origins, autnum, cache)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		info, ok, err := r.Lookup(ctx, "8.8.8.8")
+		if err != nil || !ok {
+			t.Fatalf("Lookup failed: ok=%v err=%v", ok, err)
+		}
+		want := Info{Number: 15169, Name: "GOOGLE", Org: "Google LLC", Country: "US", Prefix: "8.8.8.0/24"}
+		if info != want {
+			t.Errorf("got %+v, want %+v", info, want)
+		}
+	}
+	if autnum.calls != 1 {
+		t.Errorf("expected autnum result to be cached, got %d calls", autnum.calls)
+	}
+
+	if _, ok, _ := r.Lookup(ctx, "9.9.9.9"); ok {
+		t.Error("expected unrouted address to report ok=false")
+	}
+}
+
+func TestResolver_LookupAutnumError(t *testing.T) {
+	origins := staticOrigins{
+		"8.8.8.8": {Prefix: netip.MustParsePrefix("8.8.8.0/24"), ASN: 15169, Name: "GOOGLE - Google LLC"},
+	}
+	r := NewResolver(origins, &countingAutnum{err: errors.New("timeout")}, nil)
+
+	info, ok, err := r.Lookup(context.Background(), "8.8.8.8")
+	if err == nil {
+		t.Error("expected autnum error to be returned")
+	}
+	if !ok || info.Name != "GOOGLE - Google LLC" || info.Number != 15169 {
+		t.Errorf("expected dataset result despite the error, got %+v", info)
+	}
+}
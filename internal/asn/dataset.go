@@ -0,0 +1,178 @@
+package asn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"myip/internal/filewatch"
+	"myip/internal/mmdb"
+	"myip/internal/netcalc"
+)
+
+// Route is the origin of the announced prefix covering an address.
+type Route struct {
+	Prefix  netip.Prefix
+	ASN     uint32
+	Name    string
+	Country string
+}
+
+// Dataset is an IP-to-ASN table loaded from an iptoasn TSV file
+// (ip2asn-combined.tsv) or a GeoLite2/DB-IP ASN .mmdb file. The format is
+// chosen by the file extension.
+type Dataset struct {
+	watcher *filewatch.Watcher[source]
+}
+
+type source struct {
+	reader *mmdb.Reader
+	ranges []asRange
+}
+
+type asRange struct {
+	first   netip.Addr
+	last    netip.Addr
+	asn     uint32
+	name    string
+	country string
+}
+
+// OpenDataset loads the dataset at path.
+func OpenDataset(path string) (*Dataset, error) {
+	watcher, err := filewatch.New(path, filewatch.FileSignature(path), func() (*source, error) {
+		var src source
+		var err error
+		if strings.HasSuffix(path, ".mmdb") {
+			src.reader, err = mmdb.Open(path)
+		} else {
+			src.ranges, err = loadTSV(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &src, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Dataset{watcher: watcher}, nil
+}
+
+// Reload re-reads the file if its modification time or size changed and
+// reports whether a new dataset was loaded.
+func (d *Dataset) Reload() (bool, error) {
+	return d.watcher.Reload()
+}
+
+// Watch polls the file every interval until ctx is done. A broken update
+// keeps the previous dataset in use.
+func (d *Dataset) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	d.watcher.Watch(ctx, interval, onError)
+}
+
+// Origin returns the route covering addr. ok is false for unrouted
+// addresses.
+func (d *Dataset) Origin(addr netip.Addr) (Route, bool, error) {
+	addr = addr.Unmap()
+	src := d.watcher.Load()
+	if src.reader != nil {
+		return mmdbOrigin(src.reader, addr)
+	}
+	route := tsvOrigin(src.ranges, addr)
+	return route, route.ASN != 0, nil
+}
+
+func mmdbOrigin(reader *mmdb.Reader, addr netip.Addr) (Route, bool, error) {
+	record, bits, ok, err := reader.Lookup(addr)
+	if err != nil || !ok {
+		return Route{}, false, err
+	}
+	route := Route{
+		ASN:  uint32(mmdb.Uint(record, "autonomous_system_number")),
+		Name: mmdb.String(record, "autonomous_system_organization"),
+	}
+	if route.ASN == 0 {
+		return Route{}, false, nil
+	}
+	route.Prefix, _ = addr.Prefix(bits)
+	return route, true, nil
+}
+
+func tsvOrigin(ranges []asRange, addr netip.Addr) Route {
+	i := sort.Search(len(ranges), func(i int) bool { return addr.Compare(ranges[i].last) <= 0 })
+	if i == len(ranges) || addr.Less(ranges[i].first) {
+		return Route{}
+	}
+	r := ranges[i]
+	route := Route{ASN: r.asn, Name: r.name, Country: r.country}
+	// iptoasn merges adjacent announcements into ranges; report the
+	// aligned block of the range that holds the address.
+	for _, prefix := range netcalc.RangePrefixes(r.first, r.last) {
+		if prefix.Contains(addr) {
+			route.Prefix = prefix
+			break
+		}
+	}
+	return route
+}
+
+func loadTSV(path string) ([]asRange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open asn dataset: %w", err)
+	}
+	defer file.Close()
+	return parseTSV(file)
+}
+
+// parseTSV reads "first\tlast\tasn\tcountry\tdescription" lines. Ranges
+// with AS 0 are unrouted and skipped.
+func parseTSV(r io.Reader) ([]asRange, error) {
+	var ranges []asRange
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		first, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		last, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		number, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid asn: %w", line, err)
+		}
+		if number == 0 {
+			continue
+		}
+		r := asRange{first: first.Unmap(), last: last.Unmap(), asn: uint32(number)}
+		if len(fields) > 3 && fields[3] != "None" {
+			r.country = fields[3]
+		}
+		if len(fields) > 4 && fields[4] != "Not routed" {
+			r.name = fields[4]
+		}
+		ranges = append(ranges, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan asn dataset: %w", err)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].first.Less(ranges[j].first) })
+	return ranges, nil
+}
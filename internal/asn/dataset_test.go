@@ -0,0 +1,82 @@
+package asn
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"myip/internal/mmdb/mmdbtest"
+)
+
+const sampleTSV = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+	"8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE\n" +
+	"10.0.0.0\t10.0.2.255\t64512\tZZ\tEXAMPLE\n" +
+	"2001:4860::\t2001:4860:ffff:ffff:ffff:ffff:ffff:ffff\t15169\tUS\tGOOGLE\n"
+
+func TestDataset_TSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip2asn-combined.tsv")
+	if err := os.WriteFile(path, []byte(sampleTSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenDataset(path)
+	if err != nil {
+		t.Fatalf("OpenDataset failed: %v", err)
+	}
+
+	tests := []struct {
+		ip     string
+		ok     bool
+		asn    uint32
+		prefix string
+	}{
+		{"8.8.8.8", true, 15169, "8.8.8.0/24"},
+		{"1.0.0.1", true, 13335, "1.0.0.0/24"},
+		{"1.0.2.1", false, 0, ""},
+		{"10.0.2.7", true, 64512, "10.0.2.0/24"},
+		{"2001:4860:4860::8888", true, 15169, "2001:4860::/32"},
+		{"9.9.9.9", false, 0, ""},
+	}
+	for _, tt := range tests {
+		route, ok, err := d.Origin(netip.MustParseAddr(tt.ip))
+		if err != nil {
+			t.Fatalf("Origin(%s) failed: %v", tt.ip, err)
+		}
+		if ok != tt.ok || route.ASN != tt.asn {
+			t.Errorf("Origin(%s) = %+v %v, want AS%d %v", tt.ip, route, ok, tt.asn, tt.ok)
+			continue
+		}
+		if ok && route.Prefix.String() != tt.prefix {
+			t.Errorf("Origin(%s) prefix = %s, want %s", tt.ip, route.Prefix, tt.prefix)
+		}
+	}
+}
+
+func TestDataset_MMDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asn.mmdb")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mmdbtest.Write(f, "GeoLite2-ASN", []mmdbtest.Entry{{
+		Prefix: netip.MustParsePrefix("8.8.8.0/24"),
+		Value:  map[string]any{"autonomous_system_number": uint32(15169), "autonomous_system_organization": "GOOGLE"},
+	}})
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := OpenDataset(path)
+	if err != nil {
+		t.Fatalf("OpenDataset failed: %v", err)
+	}
+	route, ok, err := d.Origin(netip.MustParseAddr("8.8.8.8"))
+	if err != nil || !ok {
+		t.Fatalf("Origin failed: ok=%v err=%v", ok, err)
+	}
+	if route.ASN != 15169 || route.Name != "GOOGLE" || route.Prefix.String() != "8.8.8.0/24" {
+		t.Errorf("unexpected route %+v", route)
+	}
+}
@@ -0,0 +1,284 @@
+// Package clienthints parses User-Agent Client Hints (Sec-CH-UA-*) and the
+// device and network hints Chromium sends instead of detail in its frozen
+// User-Agent string.
+package clienthints
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Header names of the hints.
+const (
+	UA                = "Sec-CH-UA"
+	UAMobile          = "Sec-CH-UA-Mobile"
+	UAPlatform        = "Sec-CH-UA-Platform"
+	UAFullVersionList = "Sec-CH-UA-Full-Version-List"
+	UAPlatformVersion = "Sec-CH-UA-Platform-Version"
+	UAArch            = "Sec-CH-UA-Arch"
+	UAModel           = "Sec-CH-UA-Model"
+	UABitness         = "Sec-CH-UA-Bitness"
+	ViewportWidth     = "Sec-CH-Viewport-Width"
+	DPR               = "Sec-CH-DPR"
+	ECT               = "ECT"
+)
+
+// Requested are the high entropy hints browsers only send after the server
+// asks for them with Accept-CH.
+var Requested = []string{UAFullVersionList, UAPlatformVersion, UAArch, UAModel, UABitness, ViewportWidth, DPR, ECT}
+
+// Critical are the requested hints worth a retry of the first navigation
+// when missing (Critical-CH). Viewport and network hints change too often
+// to be worth it.
+var Critical = []string{UAFullVersionList, UAPlatformVersion, UAArch, UAModel, UABitness}
+
+// Names are all hints Parse reads, the low entropy ones browsers send
+// unasked included.
+var Names = []string{UA, UAMobile, UAPlatform, UAFullVersionList, UAPlatformVersion, UAArch, UAModel, UABitness, ViewportWidth, DPR, ECT}
+
+// Brand is a browser brand and its version.
+type Brand struct {
+	Brand   string `json:"brand"`
+	Version string `json:"version"`
+}
+
+// Hints are the parsed client hints of a request. Raw holds the received
+// headers verbatim, including ones that failed to parse.
+type Hints struct {
+	Brands          []Brand           `json:"brands,omitempty"`
+	FullVersionList []Brand           `json:"full_version_list,omitempty"`
+	Mobile          *bool             `json:"mobile,omitempty"`
+	Platform        string            `json:"platform,omitempty"`
+	PlatformVersion string            `json:"platform_version,omitempty"`
+	OS              string            `json:"os,omitempty"`
+	Architecture    string            `json:"architecture,omitempty"`
+	Model           string            `json:"model,omitempty"`
+	Bitness         string            `json:"bitness,omitempty"`
+	ViewportWidth   int               `json:"viewport_width,omitempty"`
+	DPR             float64           `json:"dpr,omitempty"`
+	ECT             string            `json:"ect,omitempty"`
+	Raw             map[string]string `json:"raw"`
+
+	Inconsistencies []string `json:"inconsistencies,omitempty"`
+}
+
+// Parse reads the hints of h and checks them against its User-Agent. It
+// reports false when the request carries no hints at all.
+func Parse(h http.Header) (Hints, bool) {
+	hints := Hints{Raw: map[string]string{}}
+	for _, name := range Names {
+		if value := h.Get(name); value != "" {
+			hints.Raw[name] = value
+		}
+	}
+	if len(hints.Raw) == 0 {
+		return Hints{}, false
+	}
+
+	hints.Brands = parseBrands(hints.Raw[UA])
+	hints.FullVersionList = parseBrands(hints.Raw[UAFullVersionList])
+	if mobile, ok := parseBoolean(hints.Raw[UAMobile]); ok {
+		hints.Mobile = &mobile
+	}
+	hints.Platform, _ = parseString(hints.Raw[UAPlatform])
+	hints.PlatformVersion, _ = parseString(hints.Raw[UAPlatformVersion])
+	hints.Architecture, _ = parseString(hints.Raw[UAArch])
+	hints.Model, _ = parseString(hints.Raw[UAModel])
+	hints.Bitness, _ = parseString(hints.Raw[UABitness])
+	// Viewport width is an integer and DPR a decimal; ECT is a token.
+	if width, err := strconv.Atoi(strings.TrimSpace(hints.Raw[ViewportWidth])); err == nil && width > 0 {
+		hints.ViewportWidth = width
+	}
+	if dpr, err := strconv.ParseFloat(strings.TrimSpace(hints.Raw[DPR]), 64); err == nil && dpr > 0 {
+		hints.DPR = dpr
+	}
+	hints.ECT = strings.TrimSpace(hints.Raw[ECT])
+	hints.OS = osRelease(hints.Platform, hints.PlatformVersion)
+	hints.Inconsistencies = hints.Check(h.Get("User-Agent"))
+	return hints, true
+}
+
+// osRelease names the release behind a platform version where the frozen
+// User-Agent hides it, e.g. Windows 11 still claims "Windows NT 10.0".
+func osRelease(platform, version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return ""
+	}
+	switch platform {
+	case "Windows":
+		switch {
+		case n >= 13:
+			return "Windows 11"
+		case n > 0:
+			return "Windows 10"
+		default:
+			return "Windows 7/8/8.1"
+		}
+	case "macOS", "Android", "iOS", "Chrome OS", "ChromeOS":
+		return platform + " " + version
+	}
+	return ""
+}
+
+// uaPlatforms maps User-Agent tokens to the platform hint they imply, most
+// specific first: Android user agents also contain "Linux".
+var uaPlatforms = []struct{ token, platform string }{
+	{"Android", "Android"},
+	{"CrOS", "Chrome OS"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"Windows", "Windows"},
+	{"Macintosh", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// uaBrands maps User-Agent product tokens to the brand reporting their
+// version, most specific first: Edge and Opera also claim Chrome.
+var uaBrands = []struct{ token, brand string }{
+	{"Edg/", "Microsoft Edge"},
+	{"OPR/", "Opera"},
+	{"Chrome/", "Chromium"},
+}
+
+// Check lists the ways the hints contradict userAgent. Spoofing tools
+// often change one without the other.
+func (h Hints) Check(userAgent string) []string {
+	var issues []string
+	if !strings.Contains(userAgent, "Chrome/") && !strings.Contains(userAgent, "CriOS/") {
+		return append(issues, "client hints sent by a user agent that does not claim to be Chromium based")
+	}
+
+	if h.Platform != "" {
+		for _, p := range uaPlatforms {
+			if !strings.Contains(userAgent, p.token) {
+				continue
+			}
+			if !samePlatform(p.platform, h.Platform) {
+				issues = append(issues, "platform hint "+strconv.Quote(h.Platform)+" but User-Agent claims "+p.platform)
+			}
+			break
+		}
+	}
+
+	if h.Mobile != nil && *h.Mobile != strings.Contains(userAgent, "Mobile") {
+		issues = append(issues, "mobile hint "+strconv.FormatBool(*h.Mobile)+" disagrees with the User-Agent")
+	}
+
+	brands := h.FullVersionList
+	if len(brands) == 0 {
+		brands = h.Brands
+	}
+	for _, b := range uaBrands {
+		_, rest, found := strings.Cut(userAgent, b.token)
+		if !found {
+			continue
+		}
+		uaMajor, _, _ := strings.Cut(rest, ".")
+		uaMajor, _, _ = strings.Cut(uaMajor, " ")
+		if version, ok := brandVersion(brands, b.brand); ok {
+			major, _, _ := strings.Cut(version, ".")
+			if major != uaMajor {
+				issues = append(issues, b.brand+" version "+version+" in hints but "+uaMajor+" in User-Agent")
+			}
+		}
+		break
+	}
+	return issues
+}
+
+func samePlatform(a, b string) bool {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, " ", ""))
+	}
+	return normalize(a) == normalize(b)
+}
+
+func brandVersion(brands []Brand, name string) (string, bool) {
+	for _, b := range brands {
+		if b.Brand == name {
+			return b.Version, true
+		}
+	}
+	return "", false
+}
+
+// parseBrands reads a structured field list of strings with a "v"
+// parameter (RFC 8941), e.g. `"Chromium";v="124", "Not-A.Brand";v="99"`.
+// Parsing stops at the first malformed member.
+func parseBrands(value string) []Brand {
+	var brands []Brand
+	for len(value) > 0 {
+		value = strings.TrimLeft(value, " \t")
+		name, rest, ok := parseStringPrefix(value)
+		if !ok {
+			return brands
+		}
+		brand := Brand{Brand: name}
+		for strings.HasPrefix(rest, ";") {
+			key, after, _ := strings.Cut(rest[1:], "=")
+			var param string
+			if param, rest, ok = parseStringPrefix(after); !ok {
+				return brands
+			}
+			if strings.TrimSpace(key) == "v" {
+				brand.Version = param
+			}
+		}
+		brands = append(brands, brand)
+
+		rest = strings.TrimLeft(rest, " \t")
+		if !strings.HasPrefix(rest, ",") {
+			return brands
+		}
+		value = rest[1:]
+	}
+	return brands
+}
+
+// parseString reads a structured field string such as `"Windows"`.
+func parseString(value string) (string, bool) {
+	s, rest, ok := parseStringPrefix(strings.TrimSpace(value))
+	if !ok || rest != "" {
+		return "", false
+	}
+	return s, true
+}
+
+func parseStringPrefix(value string) (string, string, bool) {
+	if !strings.HasPrefix(value, `"`) {
+		return "", value, false
+	}
+	var b strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			if i+1 == len(value) || (value[i+1] != '"' && value[i+1] != '\\') {
+				return "", value, false
+			}
+			i++
+			b.WriteByte(value[i])
+		case '"':
+			return b.String(), value[i+1:], true
+		default:
+			if c < 0x20 || c > 0x7e {
+				return "", value, false
+			}
+			b.WriteByte(c)
+		}
+	}
+	return "", value, false
+}
+
+// parseBoolean reads a structured field boolean, ?0 or ?1.
+func parseBoolean(value string) (bool, bool) {
+	switch strings.TrimSpace(value) {
+	case "?1":
+		return true, true
+	case "?0":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,118 @@
+package clienthints
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const chromeWindowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+func chromeHeaders() http.Header {
+	h := http.Header{}
+	h.Set("User-Agent", chromeWindowsUA)
+	h.Set(UA, `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`)
+	h.Set(UAMobile, "?0")
+	h.Set(UAPlatform, `"Windows"`)
+	h.Set(UAFullVersionList, `"Chromium";v="124.0.6367.91", "Google Chrome";v="124.0.6367.91", "Not-A.Brand";v="99.0.0.0"`)
+	h.Set(UAPlatformVersion, `"15.0.0"`)
+	h.Set(UAArch, `"x86"`)
+	h.Set(UAModel, `""`)
+	h.Set(UABitness, `"64"`)
+	h.Set(ViewportWidth, "1280")
+	h.Set(DPR, "1.5")
+	h.Set(ECT, "4g")
+	return h
+}
+
+func TestParse(t *testing.T) {
+	hints, ok := Parse(chromeHeaders())
+	if !ok {
+		t.Fatal("expected hints")
+	}
+	if len(hints.Brands) != 3 || hints.Brands[1] != (Brand{"Google Chrome", "124"}) {
+		t.Errorf("unexpected brands %+v", hints.Brands)
+	}
+	if len(hints.FullVersionList) != 3 || hints.FullVersionList[0].Version != "124.0.6367.91" {
+		t.Errorf("unexpected full version list %+v", hints.FullVersionList)
+	}
+	if hints.Mobile == nil || *hints.Mobile {
+		t.Errorf("expected mobile false, got %v", hints.Mobile)
+	}
+	want := Hints{
+		Platform: "Windows", PlatformVersion: "15.0.0", OS: "Windows 11", Architecture: "x86", Bitness: "64",
+		ViewportWidth: 1280, DPR: 1.5, ECT: "4g",
+	}
+	got := Hints{
+		Platform: hints.Platform, PlatformVersion: hints.PlatformVersion, OS: hints.OS, Architecture: hints.Architecture,
+		Model: hints.Model, Bitness: hints.Bitness, ViewportWidth: hints.ViewportWidth, DPR: hints.DPR, ECT: hints.ECT,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if len(hints.Raw) != 11 || hints.Raw[UAPlatform] != `"Windows"` {
+		t.Errorf("unexpected raw headers %v", hints.Raw)
+	}
+	if len(hints.Inconsistencies) != 0 {
+		t.Errorf("expected consistent hints, got %v", hints.Inconsistencies)
+	}
+
+	if _, ok := Parse(http.Header{"User-Agent": {chromeWindowsUA}}); ok {
+		t.Error("expected no hints without Sec-CH headers")
+	}
+}
+
+func TestParse_Inconsistencies(t *testing.T) {
+	tests := map[string]struct {
+		userAgent string
+		header    string
+		value     string
+		want      string
+	}{
+		"platform": {
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want:      `platform hint "Windows" but User-Agent claims macOS`,
+		},
+		"version": {
+			userAgent: strings.Replace(chromeWindowsUA, "Chrome/124", "Chrome/120", 1),
+			want:      "Chromium version 124.0.6367.91 in hints but 120 in User-Agent",
+		},
+		"mobile": {
+			userAgent: chromeWindowsUA,
+			header:    UAMobile,
+			value:     "?1",
+			want:      "mobile hint true disagrees with the User-Agent",
+		},
+		"not chromium": {
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			want:      "client hints sent by a user agent that does not claim to be Chromium based",
+		},
+	}
+	for name, tt := range tests {
+		h := chromeHeaders()
+		h.Set("User-Agent", tt.userAgent)
+		if tt.header != "" {
+			h.Set(tt.header, tt.value)
+		}
+		hints, _ := Parse(h)
+		if len(hints.Inconsistencies) != 1 || hints.Inconsistencies[0] != tt.want {
+			t.Errorf("%s: got %q, want %q", name, hints.Inconsistencies, tt.want)
+		}
+	}
+}
+
+func TestParseBrands(t *testing.T) {
+	tests := map[string][]Brand{
+		`"A";v="1"`:                        {{"A", "1"}},
+		`"Not\"A\\Brand";v="8", "B";v="2"`: {{`Not"A\Brand`, "8"}, {"B", "2"}},
+		`"A";x="y";v="3"`:                  {{"A", "3"}},
+		`"A";v="1", bogus`:                 {{"A", "1"}},
+		`"unterminated`:                    nil,
+	}
+	for value, want := range tests {
+		if got := parseBrands(value); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", value, got, want)
+		}
+	}
+}
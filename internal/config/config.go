@@ -4,20 +4,81 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var envFileName = ".env"
 
 // Config holds service settings loaded from the environment.
 type Config struct {
-	WebAddr   string
-	Redis     string
-	RedisUser string
-	RedisPass string
-	RDAPAPI   string
-	LogType   string
-	LogAddr   string
+	WebAddr       string
+	WebAddrs      []string
+	WebSocketMode os.FileMode
+	Redis         string
+	RedisUser     string
+	RedisPass     string
+	RDAPAPI       string
+	LogType       string
+	LogAddr       string
+
+	GELFCompression string
+	GELFChunkSize   int
+	GELFBufferSize  int
+	LogFields       map[string]string
+
+	RateLimitPage   string
+	RateLimitAPI    string
+	RateLimitLookup string
+	RateLimitAllow  []string
+	TrustedProxies  []string
+
+	APIKeysRequired bool
+	APIKeysFile     string
+
+	BatchMaxItems    int
+	BatchConcurrency int
+
+	RDNSEnabled bool
+	DNSResolver string
+
+	GeoIPDB     string
+	GeoIPReload time.Duration
+
+	ASNDB         string
+	ASNReload     time.Duration
+	RDAPAutnumAPI string
+
+	CloudRangesDir    string
+	CloudRangesReload time.Duration
+
+	TorExitList    string
+	TorExitRefresh time.Duration
+	TorExitPort    int
+
+	DNSBLEnabled  bool
+	DNSBLZones    []string
+	DNSBLResolver string
+
+	RiskWeights map[string]string
+
+	FingerprintStatsLimit int
+
+	STUNAddr    string
+	STUNPort    int
+	STUNTCP     bool
+	STUNAltAddr string
+
+	DNSListen     string
+	DNSWhoamiName string
+	DNSLeakZone   string
+	DNSLeakAddrs  []string
+
+	DualStackIPv4Host string
+	DualStackIPv6Host string
+
+	UserAgentRegexes string
 }
 
 // Load reads .env and merges it with existing environment values.
@@ -40,6 +101,123 @@ func Load() (Config, error) {
 		cfg.LogType = "console"
 	}
 
+	var err error
+	cfg.WebAddrs = splitList(cfg.WebAddr)
+	cfg.WebSocketMode = 0o660
+	if raw := strings.TrimSpace(os.Getenv("WEB_SOCKET_MODE")); raw != "" {
+		mode, err := strconv.ParseUint(raw, 8, 32)
+		if err != nil {
+			return Config{}, fmt.Errorf("WEB_SOCKET_MODE must be octal: %w", err)
+		}
+		cfg.WebSocketMode = os.FileMode(mode)
+	}
+
+	cfg.GELFCompression = strings.TrimSpace(os.Getenv("LOG_GELF_COMPRESSION"))
+	if cfg.GELFChunkSize, err = envInt("LOG_GELF_CHUNK_SIZE", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.GELFBufferSize, err = envInt("LOG_GELF_BUFFER", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.LogFields, err = parsePairs(os.Getenv("LOG_FIELDS")); err != nil {
+		return Config{}, fmt.Errorf("LOG_FIELDS: %w", err)
+	}
+
+	cfg.RateLimitPage = strings.TrimSpace(os.Getenv("RATE_LIMIT"))
+	cfg.RateLimitAPI = strings.TrimSpace(os.Getenv("RATE_LIMIT_API"))
+	cfg.RateLimitLookup = strings.TrimSpace(os.Getenv("RATE_LIMIT_LOOKUP"))
+	cfg.RateLimitAllow = splitList(os.Getenv("RATE_LIMIT_ALLOW"))
+	cfg.TrustedProxies = splitList(os.Getenv("TRUSTED_PROXIES"))
+
+	if cfg.APIKeysRequired, err = envBool("API_KEYS_REQUIRED", true); err != nil {
+		return Config{}, err
+	}
+	cfg.APIKeysFile = strings.TrimSpace(os.Getenv("API_KEYS_FILE"))
+
+	if cfg.BatchMaxItems, err = envInt("BATCH_MAX_ITEMS", 1000); err != nil {
+		return Config{}, err
+	}
+	if cfg.BatchConcurrency, err = envInt("BATCH_CONCURRENCY", 8); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.RDNSEnabled, err = envBool("RDNS", false); err != nil {
+		return Config{}, err
+	}
+	cfg.DNSResolver = strings.TrimSpace(os.Getenv("DNS_RESOLVER"))
+
+	cfg.GeoIPDB = strings.TrimSpace(os.Getenv("GEOIP_DB"))
+	if cfg.GeoIPReload, err = envDuration("GEOIP_RELOAD", time.Hour); err != nil {
+		return Config{}, err
+	}
+
+	cfg.ASNDB = strings.TrimSpace(os.Getenv("ASN_DB"))
+	if cfg.ASNReload, err = envDuration("ASN_RELOAD", time.Hour); err != nil {
+		return Config{}, err
+	}
+	cfg.RDAPAutnumAPI = strings.TrimSpace(os.Getenv("RDAP_AUTNUM_API"))
+
+	cfg.CloudRangesDir = strings.TrimSpace(os.Getenv("CLOUD_RANGES_DIR"))
+	if cfg.CloudRangesReload, err = envDuration("CLOUD_RANGES_RELOAD", 10*time.Minute); err != nil {
+		return Config{}, err
+	}
+
+	cfg.TorExitList = strings.TrimSpace(os.Getenv("TOR_EXIT_LIST"))
+	if cfg.TorExitRefresh, err = envDuration("TOR_EXIT_REFRESH", 30*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.TorExitPort, err = envInt("TOR_EXIT_PORT", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.TorExitPort < 0 || cfg.TorExitPort > 65535 {
+		return Config{}, fmt.Errorf("TOR_EXIT_PORT must be a port number")
+	}
+
+	if cfg.DNSBLEnabled, err = envBool("DNSBL", false); err != nil {
+		return Config{}, err
+	}
+	cfg.DNSBLZones = splitList(os.Getenv("DNSBL_ZONES"))
+	cfg.DNSBLResolver = strings.TrimSpace(os.Getenv("DNSBL_RESOLVER"))
+
+	if cfg.RiskWeights, err = parsePairs(os.Getenv("RISK_WEIGHTS")); err != nil {
+		return Config{}, fmt.Errorf("RISK_WEIGHTS: %w", err)
+	}
+
+	if cfg.FingerprintStatsLimit, err = envInt("FINGERPRINT_STATS_LIMIT", 10000); err != nil {
+		return Config{}, err
+	}
+
+	cfg.STUNAddr = strings.TrimSpace(os.Getenv("STUN_ADDR"))
+	if cfg.STUNPort, err = envInt("STUN_PORT", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.STUNPort < 0 || cfg.STUNPort > 65535 {
+		return Config{}, fmt.Errorf("STUN_PORT must be a port number")
+	}
+	if cfg.STUNTCP, err = envBool("STUN_TCP", true); err != nil {
+		return Config{}, err
+	}
+	cfg.STUNAltAddr = strings.TrimSpace(os.Getenv("STUN_ALT_ADDR"))
+
+	cfg.DNSListen = strings.TrimSpace(os.Getenv("DNS_LISTEN"))
+	cfg.DNSWhoamiName = strings.TrimSpace(os.Getenv("DNS_WHOAMI_NAME"))
+	cfg.DNSLeakZone = strings.TrimSpace(os.Getenv("DNS_LEAK_ZONE"))
+	cfg.DNSLeakAddrs = splitList(os.Getenv("DNS_LEAK_ADDRS"))
+	if cfg.DNSListen != "" && cfg.DNSWhoamiName == "" && cfg.DNSLeakZone == "" {
+		return Config{}, fmt.Errorf("DNS_WHOAMI_NAME or DNS_LEAK_ZONE is required with DNS_LISTEN")
+	}
+	if cfg.DNSLeakZone != "" && cfg.DNSListen == "" {
+		return Config{}, fmt.Errorf("DNS_LISTEN is required with DNS_LEAK_ZONE")
+	}
+
+	cfg.DualStackIPv4Host = strings.TrimSpace(os.Getenv("DUALSTACK_IPV4_HOST"))
+	cfg.DualStackIPv6Host = strings.TrimSpace(os.Getenv("DUALSTACK_IPV6_HOST"))
+	if (cfg.DualStackIPv4Host == "") != (cfg.DualStackIPv6Host == "") {
+		return Config{}, fmt.Errorf("DUALSTACK_IPV4_HOST and DUALSTACK_IPV6_HOST must be set together")
+	}
+
+	cfg.UserAgentRegexes = strings.TrimSpace(os.Getenv("USERAGENT_REGEXES"))
+
 	if cfg.WebAddr == "" {
 		return Config{}, fmt.Errorf("WEB is required")
 	}
@@ -50,6 +228,71 @@ func Load() (Config, error) {
 	return cfg, nil
 }
 
+func envInt(key string, fallback int) (int, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return value, nil
+}
+
+func envBool(key string, fallback bool) (bool, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean: %w", key, err)
+	}
+	return value, nil
+}
+
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration: %w", key, err)
+	}
+	return value, nil
+}
+
+// splitList parses comma separated values, skipping empty items.
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePairs parses "key=value,key2=value2" lists.
+func parsePairs(raw string) (map[string]string, error) {
+	pairs := map[string]string{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid pair: %s", item)
+		}
+		pairs[key] = strings.TrimSpace(value)
+	}
+	return pairs, nil
+}
+
 func loadEnvFile(path string) error {
 	file, err := os.Open(path)
 	if err != nil {
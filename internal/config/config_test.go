@@ -2,7 +2,11 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -60,3 +64,188 @@ func TestLoadEnvFile(t *testing.T) {
 		t.Errorf("KEY2 expected VALUE2, got %s", os.Getenv("KEY2"))
 	}
 }
+
+// loadEnv runs Load with only WEB, REDIS and env set among the variables
+// these tests touch.
+func loadEnv(t *testing.T, env map[string]string) (Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	originalEnvFileName := envFileName
+	envFileName = path
+	t.Cleanup(func() { envFileName = originalEnvFileName })
+
+	for _, key := range []string{
+		"WEB_SOCKET_MODE", "LOG_TYPE", "LOG_FIELDS", "LOG_GELF_CHUNK_SIZE", "RISK_WEIGHTS", "API_KEYS_REQUIRED",
+		"BATCH_MAX_ITEMS", "BATCH_CONCURRENCY", "RDNS", "GEOIP_RELOAD", "ASN_RELOAD", "CLOUD_RANGES_RELOAD",
+		"TOR_EXIT_REFRESH", "TOR_EXIT_PORT", "FINGERPRINT_STATS_LIMIT", "STUN_PORT", "STUN_TCP", "DNS_LISTEN",
+		"DNS_WHOAMI_NAME", "DNS_LEAK_ZONE", "DUALSTACK_IPV4_HOST", "DUALSTACK_IPV6_HOST",
+	} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("WEB", ":8080")
+	t.Setenv("REDIS", "localhost:6379")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	return Load()
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := loadEnv(t, nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.LogType != "console" || cfg.WebSocketMode != 0o660 || !reflect.DeepEqual(cfg.WebAddrs, []string{":8080"}) {
+		t.Errorf("unexpected web and log defaults %+v", cfg)
+	}
+	if !cfg.APIKeysRequired || cfg.RDNSEnabled || !cfg.STUNTCP {
+		t.Errorf("unexpected boolean defaults: api keys %v, rdns %v, stun tcp %v", cfg.APIKeysRequired, cfg.RDNSEnabled, cfg.STUNTCP)
+	}
+	if cfg.BatchMaxItems != 1000 || cfg.BatchConcurrency != 8 || cfg.FingerprintStatsLimit != 10000 {
+		t.Errorf("unexpected integer defaults %d, %d, %d", cfg.BatchMaxItems, cfg.BatchConcurrency, cfg.FingerprintStatsLimit)
+	}
+	if cfg.GeoIPReload != time.Hour || cfg.ASNReload != time.Hour || cfg.CloudRangesReload != 10*time.Minute ||
+		cfg.TorExitRefresh != 30*time.Minute {
+		t.Errorf("unexpected duration defaults %v, %v, %v, %v", cfg.GeoIPReload, cfg.ASNReload, cfg.CloudRangesReload, cfg.TorExitRefresh)
+	}
+}
+
+func TestLoad_Values(t *testing.T) {
+	tests := map[string]struct {
+		env   map[string]string
+		check func(Config) bool
+	}{
+		"octal socket mode":                {map[string]string{"WEB_SOCKET_MODE": "0600"}, func(c Config) bool { return c.WebSocketMode == 0o600 }},
+		"socket mode without leading zero": {map[string]string{"WEB_SOCKET_MODE": "755"}, func(c Config) bool { return c.WebSocketMode == 0o755 }},
+		"several listeners": {map[string]string{"WEB": ":80, unix:/run/myip.sock,"}, func(c Config) bool {
+			return reflect.DeepEqual(c.WebAddrs, []string{":80", "unix:/run/myip.sock"})
+		}},
+		"log fields": {map[string]string{"LOG_FIELDS": "env=prod, dc = eu"}, func(c Config) bool {
+			return reflect.DeepEqual(c.LogFields, map[string]string{"env": "prod", "dc": "eu"})
+		}},
+		"boolean":  {map[string]string{"API_KEYS_REQUIRED": "false"}, func(c Config) bool { return !c.APIKeysRequired }},
+		"duration": {map[string]string{"GEOIP_RELOAD": "5m"}, func(c Config) bool { return c.GeoIPReload == 5*time.Minute }},
+		"dns whoami only": {map[string]string{"DNS_LISTEN": ":53", "DNS_WHOAMI_NAME": "whoami.example.com"}, func(c Config) bool {
+			return c.DNSListen == ":53" && c.DNSLeakZone == ""
+		}},
+		"dns leak zone": {map[string]string{"DNS_LISTEN": ":53", "DNS_LEAK_ZONE": "leak.example.com"}, func(c Config) bool {
+			return c.DNSLeakZone == "leak.example.com"
+		}},
+		"dual stack hosts": {map[string]string{"DUALSTACK_IPV4_HOST": "ipv4.example.com", "DUALSTACK_IPV6_HOST": "ipv6.example.com"}, func(c Config) bool {
+			return c.DualStackIPv4Host == "ipv4.example.com" && c.DualStackIPv6Host == "ipv6.example.com"
+		}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := loadEnv(t, tt.env)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("unexpected config %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]struct {
+		env      map[string]string
+		expected string
+	}{
+		"socket mode not octal":   {map[string]string{"WEB_SOCKET_MODE": "0789"}, "WEB_SOCKET_MODE must be octal"},
+		"socket mode symbolic":    {map[string]string{"WEB_SOCKET_MODE": "rw-rw----"}, "WEB_SOCKET_MODE must be octal"},
+		"integer":                 {map[string]string{"LOG_GELF_CHUNK_SIZE": "big"}, "LOG_GELF_CHUNK_SIZE must be an integer"},
+		"boolean":                 {map[string]string{"API_KEYS_REQUIRED": "maybe"}, "API_KEYS_REQUIRED must be a boolean"},
+		"duration":                {map[string]string{"GEOIP_RELOAD": "1 hour"}, "GEOIP_RELOAD must be a duration"},
+		"log field without value": {map[string]string{"LOG_FIELDS": "env"}, "LOG_FIELDS: invalid pair"},
+		"weight without key":      {map[string]string{"RISK_WEIGHTS": "=5"}, "RISK_WEIGHTS: invalid pair"},
+		"tor port":                {map[string]string{"TOR_EXIT_PORT": "70000"}, "TOR_EXIT_PORT must be a port number"},
+		"stun port":               {map[string]string{"STUN_PORT": "-1"}, "STUN_PORT must be a port number"},
+		"dns listen without zone": {map[string]string{"DNS_LISTEN": ":53"}, "DNS_WHOAMI_NAME or DNS_LEAK_ZONE is required"},
+		"dns leak without listen": {map[string]string{"DNS_LEAK_ZONE": "leak.example.com"}, "DNS_LISTEN is required"},
+		"only ipv4 host":          {map[string]string{"DUALSTACK_IPV4_HOST": "ipv4.example.com"}, "must be set together"},
+		"only ipv6 host":          {map[string]string{"DUALSTACK_IPV6_HOST": "ipv6.example.com"}, "must be set together"},
+		"no web":                  {map[string]string{"WEB": ""}, "WEB is required"},
+		"no redis":                {map[string]string{"REDIS": ""}, "REDIS is required"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadEnv(t, tt.env)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestEnvHelpers(t *testing.T) {
+	t.Setenv("TEST_BOOL", " true ")
+	t.Setenv("TEST_DURATION", "90s")
+	t.Setenv("TEST_INT", "42")
+	t.Setenv("TEST_EMPTY", " ")
+
+	if value, err := envBool("TEST_BOOL", false); err != nil || !value {
+		t.Errorf("envBool = %v, %v", value, err)
+	}
+	if value, err := envBool("TEST_EMPTY", true); err != nil || !value {
+		t.Errorf("expected the bool fallback for a blank value, got %v, %v", value, err)
+	}
+	if value, err := envDuration("TEST_DURATION", time.Hour); err != nil || value != 90*time.Second {
+		t.Errorf("envDuration = %v, %v", value, err)
+	}
+	if value, err := envDuration("TEST_EMPTY", time.Hour); err != nil || value != time.Hour {
+		t.Errorf("expected the duration fallback for a blank value, got %v, %v", value, err)
+	}
+	if value, err := envInt("TEST_INT", 0); err != nil || value != 42 {
+		t.Errorf("envInt = %v, %v", value, err)
+	}
+	if _, err := envDuration("TEST_INT", time.Hour); err == nil {
+		t.Error("expected a duration without unit to be rejected")
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := map[string][]string{
+		"":                   nil,
+		" , ,":               nil,
+		"a":                  {"a"},
+		" a , b,,c ":         {"a", "b", "c"},
+		"10.0.0.0/8,::1/128": {"10.0.0.0/8", "::1/128"},
+	}
+	for raw, expected := range tests {
+		if got := splitList(raw); !reflect.DeepEqual(got, expected) {
+			t.Errorf("splitList(%q) = %q, want %q", raw, got, expected)
+		}
+	}
+}
+
+func TestParsePairs(t *testing.T) {
+	tests := map[string]struct {
+		expected map[string]string
+		wantErr  bool
+	}{
+		"":                 {expected: map[string]string{}},
+		"a=1":              {expected: map[string]string{"a": "1"}},
+		" a = 1 , b=2, ,":  {expected: map[string]string{"a": "1", "b": "2"}},
+		"empty=":           {expected: map[string]string{"empty": ""}},
+		"url=http://x?a=b": {expected: map[string]string{"url": "http://x?a=b"}},
+		"novalue":          {wantErr: true},
+		" =1":              {wantErr: true},
+	}
+	for raw, tt := range tests {
+		got, err := parsePairs(raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parsePairs(%q): expected error", raw)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("parsePairs(%q) = %v, %v, want %v", raw, got, err, tt.expected)
+		}
+	}
+}
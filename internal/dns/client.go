@@ -0,0 +1,151 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 2 * time.Second
+	resolvConf     = "/etc/resolv.conf"
+	maxUDPSize     = 4096
+)
+
+// Client sends queries to a single recursive resolver.
+type Client struct {
+	server  string
+	timeout time.Duration
+}
+
+// NewClient creates a client for server (host:port or host). An empty
+// server uses the first nameserver from /etc/resolv.conf.
+func NewClient(server string) *Client {
+	server = strings.TrimSpace(server)
+	if server == "" {
+		server = systemResolver()
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &Client{server: server, timeout: defaultTimeout}
+}
+
+// Server returns the resolver address in use.
+func (c *Client) Server() string {
+	return c.server
+}
+
+// Query asks for name/qtype with recursion and returns the response. A
+// truncated UDP answer is retried over TCP.
+func (c *Client) Query(ctx context.Context, name string, qtype uint16) (*Message, error) {
+	id := make([]byte, 2)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("dns: generate id: %w", err)
+	}
+	query := &Message{
+		Header:    Header{ID: binary.BigEndian.Uint16(id), RecursionDesired: true},
+		Questions: []Question{{Name: CanonicalName(name), Type: qtype, Class: ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	resp, err := c.exchange(ctx, "udp", packed, query.ID)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Truncated {
+		return c.exchange(ctx, "tcp", packed, query.ID)
+	}
+	return resp, nil
+}
+
+func (c *Client) exchange(ctx context.Context, network string, packed []byte, id uint16) (*Message, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, c.server)
+	if err != nil {
+		return nil, fmt.Errorf("dns: dial %s: %w", c.server, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+		if _, err := conn.Write(append(framed, packed...)); err != nil {
+			return nil, fmt.Errorf("dns: write: %w", err)
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, fmt.Errorf("dns: read: %w", err)
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, fmt.Errorf("dns: read: %w", err)
+		}
+		return checkResponse(buf, id)
+	}
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, fmt.Errorf("dns: write: %w", err)
+	}
+	buf := make([]byte, maxUDPSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("dns: read: %w", err)
+		}
+		resp, err := checkResponse(buf[:n], id)
+		if errors.Is(err, errIDMismatch) {
+			continue
+		}
+		return resp, err
+	}
+}
+
+var errIDMismatch = errors.New("dns: response id mismatch")
+
+func checkResponse(b []byte, id uint16) (*Message, error) {
+	resp, err := Unpack(b)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ID != id || !resp.Response {
+		return nil, errIDMismatch
+	}
+	return resp, nil
+}
+
+func systemResolver() string {
+	file, err := os.Open(resolvConf)
+	if err != nil {
+		return "127.0.0.1:53"
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return "127.0.0.1:53"
+}
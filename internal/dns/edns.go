@@ -0,0 +1,130 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// OptionClientSubnet is the EDNS Client Subnet option code (RFC 7871).
+const OptionClientSubnet uint16 = 8
+
+// Option is an EDNS option carried in the OPT record.
+type Option struct {
+	Code uint16
+	Data []byte
+}
+
+// ClientSubnet is the network of the client a resolver queries for.
+type ClientSubnet struct {
+	Prefix      netip.Prefix
+	ScopePrefix uint8
+}
+
+// OPT returns the EDNS pseudo-record of the message.
+func (m *Message) OPT() (RR, bool) {
+	for _, rr := range m.Additional {
+		if rr.Type == TypeOPT {
+			return rr, true
+		}
+	}
+	return RR{}, false
+}
+
+// NewOPT builds an OPT record advertising udpSize with the given options.
+func NewOPT(udpSize uint16, options ...Option) RR {
+	var data []byte
+	for _, opt := range options {
+		data = binary.BigEndian.AppendUint16(data, opt.Code)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(opt.Data)))
+		data = append(data, opt.Data...)
+	}
+	return RR{Name: ".", Type: TypeOPT, Class: udpSize, Data: data}
+}
+
+// ParseOptions decodes the options of an OPT record.
+func ParseOptions(rr RR) ([]Option, error) {
+	var options []Option
+	for b := rr.Data; len(b) > 0; {
+		if len(b) < 4 {
+			return nil, errShortMessage
+		}
+		size := int(binary.BigEndian.Uint16(b[2:]))
+		if 4+size > len(b) {
+			return nil, errShortMessage
+		}
+		options = append(options, Option{Code: binary.BigEndian.Uint16(b), Data: append([]byte(nil), b[4:4+size]...)})
+		b = b[4+size:]
+	}
+	return options, nil
+}
+
+// ParseClientSubnet decodes an EDNS Client Subnet option.
+func ParseClientSubnet(data []byte) (ClientSubnet, error) {
+	if len(data) < 4 {
+		return ClientSubnet{}, errShortMessage
+	}
+	family := binary.BigEndian.Uint16(data)
+	bits, scope := int(data[2]), data[3]
+	addr := data[4:]
+	if len(addr) != (bits+7)/8 {
+		return ClientSubnet{}, errors.New("dns: client subnet address length does not match prefix")
+	}
+
+	var ip netip.Addr
+	switch family {
+	case 1:
+		var a [4]byte
+		if bits > 32 {
+			return ClientSubnet{}, fmt.Errorf("dns: invalid IPv4 client subnet prefix /%d", bits)
+		}
+		copy(a[:], addr)
+		ip = netip.AddrFrom4(a)
+	case 2:
+		var a [16]byte
+		if bits > 128 {
+			return ClientSubnet{}, fmt.Errorf("dns: invalid IPv6 client subnet prefix /%d", bits)
+		}
+		copy(a[:], addr)
+		ip = netip.AddrFrom16(a)
+	default:
+		return ClientSubnet{}, fmt.Errorf("dns: unknown client subnet family %d", family)
+	}
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		return ClientSubnet{}, err
+	}
+	return ClientSubnet{Prefix: prefix, ScopePrefix: scope}, nil
+}
+
+// ClientSubnetOf returns the first valid client subnet option of the OPT
+// record opt, or nil when it carries none.
+func ClientSubnetOf(opt RR) *ClientSubnet {
+	options, err := ParseOptions(opt)
+	if err != nil {
+		return nil
+	}
+	for _, o := range options {
+		if o.Code != OptionClientSubnet {
+			continue
+		}
+		if subnet, err := ParseClientSubnet(o.Data); err == nil {
+			return &subnet
+		}
+	}
+	return nil
+}
+
+// Option encodes the subnet as an EDNS option.
+func (c ClientSubnet) Option() Option {
+	family := uint16(2)
+	if c.Prefix.Addr().Is4() {
+		family = 1
+	}
+	bits := c.Prefix.Bits()
+	data := binary.BigEndian.AppendUint16(nil, family)
+	data = append(data, byte(bits), c.ScopePrefix)
+	data = append(data, c.Prefix.Masked().Addr().AsSlice()[:(bits+7)/8]...)
+	return Option{Code: OptionClientSubnet, Data: data}
+}
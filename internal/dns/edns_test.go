@@ -0,0 +1,58 @@
+package dns
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClientSubnet_RoundTrip(t *testing.T) {
+	for _, prefix := range []string{"198.51.100.0/24", "2001:db8:1200::/40", "0.0.0.0/0"} {
+		subnet := ClientSubnet{Prefix: netip.MustParsePrefix(prefix)}
+		opt := NewOPT(1232, Option{Code: 10, Data: []byte("cookie12")}, subnet.Option())
+
+		msg := &Message{Additional: []RR{opt}}
+		packed, err := msg.Pack()
+		if err != nil {
+			t.Fatalf("%s: Pack failed: %v", prefix, err)
+		}
+		got, err := Unpack(packed)
+		if err != nil {
+			t.Fatalf("%s: Unpack failed: %v", prefix, err)
+		}
+
+		rr, ok := got.OPT()
+		if !ok || rr.Class != 1232 {
+			t.Fatalf("%s: expected OPT with UDP size, got %+v", prefix, rr)
+		}
+		options, err := ParseOptions(rr)
+		if err != nil || len(options) != 2 || options[1].Code != OptionClientSubnet {
+			t.Fatalf("%s: unexpected options %+v, %v", prefix, options, err)
+		}
+		parsed, err := ParseClientSubnet(options[1].Data)
+		if err != nil || parsed.Prefix != subnet.Prefix {
+			t.Errorf("%s: got %+v, %v", prefix, parsed, err)
+		}
+		if found := ClientSubnetOf(rr); found == nil || found.Prefix != subnet.Prefix {
+			t.Errorf("%s: ClientSubnetOf got %+v", prefix, found)
+		}
+	}
+
+	if found := ClientSubnetOf(NewOPT(1232, Option{Code: 10, Data: []byte("cookie12")})); found != nil {
+		t.Errorf("expected no subnet without the option, got %+v", found)
+	}
+}
+
+func TestParseClientSubnet_Invalid(t *testing.T) {
+	tests := map[string][]byte{
+		"short":         {0, 1},
+		"length":        {0, 1, 24, 0, 198, 51},
+		"family":        {0, 9, 0, 0},
+		"ipv4 too long": {0, 1, 33, 0, 1, 2, 3, 4, 5},
+		"ipv6 too long": append([]byte{0, 2, 129, 0}, make([]byte, 17)...),
+	}
+	for name, data := range tests {
+		if _, err := ParseClientSubnet(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Record types.
+const (
+	TypeA     uint16 = 1
+	TypeNS    uint16 = 2
+	TypeCNAME uint16 = 5
+	TypeSOA   uint16 = 6
+	TypePTR   uint16 = 12
+	TypeTXT   uint16 = 16
+	TypeAAAA  uint16 = 28
+	TypeOPT   uint16 = 41
+	TypeANY   uint16 = 255
+)
+
+// ClassINET is the Internet class.
+const ClassINET uint16 = 1
+
+// Response codes.
+const (
+	RcodeSuccess  uint8 = 0
+	RcodeFormErr  uint8 = 1
+	RcodeServFail uint8 = 2
+	RcodeNXDomain uint8 = 3
+	RcodeNotImp   uint8 = 4
+	RcodeRefused  uint8 = 5
+)
+
+const (
+	headerSize      = 12
+	maxNameLength   = 255
+	maxLabelLength  = 63
+	maxPointerHops  = 16
+	maxTXTChunkSize = 255
+)
+
+var errShortMessage = errors.New("dns: message too short")
+
+// Header holds the fixed part of a DNS message.
+type Header struct {
+	ID                 uint16
+	Response           bool
+	Opcode             uint8
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	Rcode              uint8
+}
+
+// Question is a single query.
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// SOA holds start-of-authority data.
+type SOA struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+// NewSOA returns the SOA record of a zone served by this process, with
+// answers and negative answers cached for ttl. zone must be canonical.
+func NewSOA(zone string, ttl uint32) RR {
+	return RR{Name: zone, Type: TypeSOA, Class: ClassINET, TTL: ttl, SOA: &SOA{
+		MName: zone, RName: "hostmaster." + zone, Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, Minimum: ttl,
+	}}
+}
+
+// RR is a resource record. Only the field matching Type is used: Addr for
+// A/AAAA, Target for PTR/CNAME/NS, TXT for TXT, SOA for SOA and Data for
+// anything else, including OPT.
+type RR struct {
+	Name   string
+	Type   uint16
+	Class  uint16
+	TTL    uint32
+	Addr   netip.Addr
+	Target string
+	TXT    []string
+	SOA    *SOA
+	Data   []byte
+}
+
+// Message is a complete DNS message.
+type Message struct {
+	Header
+	Questions  []Question
+	Answers    []RR
+	Authority  []RR
+	Additional []RR
+}
+
+// Pack encodes the message without name compression.
+func (m *Message) Pack() ([]byte, error) {
+	b := make([]byte, headerSize, 512)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+
+	var flags uint16
+	if m.Response {
+		flags |= 1 << 15
+	}
+	flags |= uint16(m.Opcode&0x0f) << 11
+	if m.Authoritative {
+		flags |= 1 << 10
+	}
+	if m.Truncated {
+		flags |= 1 << 9
+	}
+	if m.RecursionDesired {
+		flags |= 1 << 8
+	}
+	if m.RecursionAvailable {
+		flags |= 1 << 7
+	}
+	flags |= uint16(m.Rcode & 0x0f)
+	binary.BigEndian.PutUint16(b[2:], flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.Questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.Answers)))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(m.Authority)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.Additional)))
+
+	var err error
+	for _, q := range m.Questions {
+		if b, err = appendName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, q.Type)
+		b = binary.BigEndian.AppendUint16(b, q.Class)
+	}
+	for _, section := range [][]RR{m.Answers, m.Authority, m.Additional} {
+		for _, rr := range section {
+			if b, err = appendRR(b, rr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// Unpack decodes a DNS message.
+func Unpack(b []byte) (*Message, error) {
+	if len(b) < headerSize {
+		return nil, errShortMessage
+	}
+
+	flags := binary.BigEndian.Uint16(b[2:])
+	m := &Message{Header: Header{
+		ID:                 binary.BigEndian.Uint16(b[0:]),
+		Response:           flags&(1<<15) != 0,
+		Opcode:             uint8(flags>>11) & 0x0f,
+		Authoritative:      flags&(1<<10) != 0,
+		Truncated:          flags&(1<<9) != 0,
+		RecursionDesired:   flags&(1<<8) != 0,
+		RecursionAvailable: flags&(1<<7) != 0,
+		Rcode:              uint8(flags & 0x0f),
+	}}
+
+	counts := [4]int{}
+	for i := range counts {
+		counts[i] = int(binary.BigEndian.Uint16(b[4+2*i:]))
+	}
+
+	off := headerSize
+	for i := 0; i < counts[0]; i++ {
+		name, next, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(b) {
+			return nil, errShortMessage
+		}
+		m.Questions = append(m.Questions, Question{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(b[next:]),
+			Class: binary.BigEndian.Uint16(b[next+2:]),
+		})
+		off = next + 4
+	}
+
+	sections := []*[]RR{&m.Answers, &m.Authority, &m.Additional}
+	for s, section := range sections {
+		for i := 0; i < counts[s+1]; i++ {
+			rr, next, err := readRR(b, off)
+			if err != nil {
+				return nil, err
+			}
+			*section = append(*section, rr)
+			off = next
+		}
+	}
+	return m, nil
+}
+
+// CanonicalName lowercases a name and makes it fully qualified.
+func CanonicalName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+func appendRR(b []byte, rr RR) ([]byte, error) {
+	b, err := appendName(b, rr.Name)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint16(b, rr.Type)
+	b = binary.BigEndian.AppendUint16(b, rr.Class)
+	b = binary.BigEndian.AppendUint32(b, rr.TTL)
+
+	lengthAt := len(b)
+	b = append(b, 0, 0)
+	switch rr.Type {
+	case TypeA:
+		if !rr.Addr.Is4() {
+			return nil, fmt.Errorf("dns: A record needs an IPv4 address")
+		}
+		a := rr.Addr.As4()
+		b = append(b, a[:]...)
+	case TypeAAAA:
+		a := rr.Addr.As16()
+		b = append(b, a[:]...)
+	case TypePTR, TypeCNAME, TypeNS:
+		if b, err = appendName(b, rr.Target); err != nil {
+			return nil, err
+		}
+	case TypeTXT:
+		for _, txt := range rr.TXT {
+			for len(txt) > maxTXTChunkSize {
+				b = append(b, maxTXTChunkSize)
+				b = append(b, txt[:maxTXTChunkSize]...)
+				txt = txt[maxTXTChunkSize:]
+			}
+			b = append(b, byte(len(txt)))
+			b = append(b, txt...)
+		}
+	case TypeSOA:
+		if rr.SOA == nil {
+			return nil, fmt.Errorf("dns: SOA record without data")
+		}
+		if b, err = appendName(b, rr.SOA.MName); err != nil {
+			return nil, err
+		}
+		if b, err = appendName(b, rr.SOA.RName); err != nil {
+			return nil, err
+		}
+		for _, v := range []uint32{rr.SOA.Serial, rr.SOA.Refresh, rr.SOA.Retry, rr.SOA.Expire, rr.SOA.Minimum} {
+			b = binary.BigEndian.AppendUint32(b, v)
+		}
+	default:
+		b = append(b, rr.Data...)
+	}
+	binary.BigEndian.PutUint16(b[lengthAt:], uint16(len(b)-lengthAt-2))
+	return b, nil
+}
+
+func readRR(b []byte, off int) (RR, int, error) {
+	name, off, err := readName(b, off)
+	if err != nil {
+		return RR{}, 0, err
+	}
+	if off+10 > len(b) {
+		return RR{}, 0, errShortMessage
+	}
+	rr := RR{
+		Name:  name,
+		Type:  binary.BigEndian.Uint16(b[off:]),
+		Class: binary.BigEndian.Uint16(b[off+2:]),
+		TTL:   binary.BigEndian.Uint32(b[off+4:]),
+	}
+	length := int(binary.BigEndian.Uint16(b[off+8:]))
+	start := off + 10
+	end := start + length
+	if end > len(b) {
+		return RR{}, 0, errShortMessage
+	}
+	data := b[start:end]
+
+	switch rr.Type {
+	case TypeA:
+		if length != 4 {
+			return RR{}, 0, fmt.Errorf("dns: invalid A record length %d", length)
+		}
+		rr.Addr = netip.AddrFrom4([4]byte(data))
+	case TypeAAAA:
+		if length != 16 {
+			return RR{}, 0, fmt.Errorf("dns: invalid AAAA record length %d", length)
+		}
+		rr.Addr = netip.AddrFrom16([16]byte(data))
+	case TypePTR, TypeCNAME, TypeNS:
+		if rr.Target, _, err = readName(b, start); err != nil {
+			return RR{}, 0, err
+		}
+	case TypeTXT:
+		for i := 0; i < len(data); {
+			n := int(data[i])
+			if i+1+n > len(data) {
+				return RR{}, 0, errShortMessage
+			}
+			rr.TXT = append(rr.TXT, string(data[i+1:i+1+n]))
+			i += 1 + n
+		}
+	case TypeSOA:
+		soa := &SOA{}
+		next := start
+		if soa.MName, next, err = readName(b, next); err != nil {
+			return RR{}, 0, err
+		}
+		if soa.RName, next, err = readName(b, next); err != nil {
+			return RR{}, 0, err
+		}
+		if next+20 > end {
+			return RR{}, 0, errShortMessage
+		}
+		soa.Serial = binary.BigEndian.Uint32(b[next:])
+		soa.Refresh = binary.BigEndian.Uint32(b[next+4:])
+		soa.Retry = binary.BigEndian.Uint32(b[next+8:])
+		soa.Expire = binary.BigEndian.Uint32(b[next+12:])
+		soa.Minimum = binary.BigEndian.Uint32(b[next+16:])
+		rr.SOA = soa
+	default:
+		rr.Data = append([]byte(nil), data...)
+	}
+	return rr, end, nil
+}
+
+func appendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) > maxNameLength {
+		return nil, fmt.Errorf("dns: name too long: %s", name)
+	}
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if label == "" || len(label) > maxLabelLength {
+				return nil, fmt.Errorf("dns: invalid label in %s", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// readName decodes a possibly compressed name and returns it fully
+// qualified together with the offset after the name at its original place.
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for hops := 0; ; {
+		if off >= len(b) {
+			return "", 0, errShortMessage
+		}
+		length := int(b[off])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case length&0xc0 == 0xc0:
+			if off+1 >= len(b) {
+				return "", 0, errShortMessage
+			}
+			if hops++; hops > maxPointerHops {
+				return "", 0, errors.New("dns: too many compression pointers")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+		case length > maxLabelLength:
+			return "", 0, fmt.Errorf("dns: invalid label length %d", length)
+		default:
+			if off+1+length > len(b) {
+				return "", 0, errShortMessage
+			}
+			labels = append(labels, string(b[off+1:off+1+length]))
+			off += 1 + length
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package dns
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestMessage_RoundTrip(t *testing.T) {
+	msg := &Message{
+		Header:    Header{ID: 42, Response: true, Authoritative: true, RecursionDesired: true, Rcode: RcodeSuccess},
+		Questions: []Question{{Name: "example.com.", Type: TypeA, Class: ClassINET}},
+		Answers: []RR{
+			{Name: "example.com.", Type: TypeA, Class: ClassINET, TTL: 300, Addr: netip.MustParseAddr("192.0.2.1")},
+			{Name: "example.com.", Type: TypeAAAA, Class: ClassINET, TTL: 300, Addr: netip.MustParseAddr("2001:db8::1")},
+			{Name: "1.2.0.192.in-addr.arpa.", Type: TypePTR, Class: ClassINET, TTL: 60, Target: "host.example.com."},
+			{Name: "example.com.", Type: TypeTXT, Class: ClassINET, TTL: 60, TXT: []string{"hello", "world"}},
+		},
+		Authority: []RR{
+			{Name: "example.com.", Type: TypeSOA, Class: ClassINET, TTL: 3600, SOA: &SOA{
+				MName: "ns.example.com.", RName: "admin.example.com.", Serial: 1, Refresh: 2, Retry: 3, Expire: 4, Minimum: 5,
+			}},
+		},
+	}
+
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	got, err := Unpack(packed)
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, msg) {
+		t.Errorf("round trip mismatch:\n%+v\nwant\n%+v", got, msg)
+	}
+}
+
+func TestNewSOA(t *testing.T) {
+	rr := NewSOA("example.com.", 30)
+	if rr.Type != TypeSOA || rr.TTL != 30 || rr.SOA.MName != "example.com." ||
+		rr.SOA.RName != "hostmaster.example.com." || rr.SOA.Minimum != 30 {
+		t.Errorf("unexpected SOA %+v %+v", rr, rr.SOA)
+	}
+}
+
+func TestUnpack_Compression(t *testing.T) {
+	// Response for "a.example." with an answer whose name and PTR target
+	// point back into the question.
+	b := []byte{
+		0x00, 0x01, 0x81, 0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+		0x01, 'a', 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x00,
+		0x00, 0x0c, 0x00, 0x01,
+		0xc0, 0x0c,
+		0x00, 0x0c, 0x00, 0x01, 0x00, 0x00, 0x00, 0x3c, 0x00, 0x04,
+		0x01, 'b', 0xc0, 0x0e,
+	}
+	msg, err := Unpack(b)
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if len(msg.Answers) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(msg.Answers))
+	}
+	rr := msg.Answers[0]
+	if rr.Name != "a.example." || rr.Target != "b.example." || rr.TTL != 60 {
+		t.Errorf("unexpected record %+v", rr)
+	}
+}
+
+func TestUnpack_PointerLoop(t *testing.T) {
+	b := []byte{
+		0x00, 0x01, 0x81, 0x80, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xc0, 0x0c, 0x00, 0x01, 0x00, 0x01,
+	}
+	if _, err := Unpack(b); err == nil {
+		t.Error("expected error for compression loop")
+	}
+}
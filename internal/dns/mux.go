@@ -0,0 +1,46 @@
+package dns
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// Mux routes queries to the handler of the most specific zone containing
+// the question name. Queries outside every zone are refused.
+type Mux struct {
+	zones map[string]Handler
+}
+
+// NewMux creates an empty mux.
+func NewMux() *Mux {
+	return &Mux{zones: map[string]Handler{}}
+}
+
+// Handle registers handler for zone and all names below it.
+func (m *Mux) Handle(zone string, handler Handler) {
+	m.zones[CanonicalName(zone)] = handler
+}
+
+// ServeDNS implements Handler.
+func (m *Mux) ServeDNS(req *Message, from netip.AddrPort, transport string) *Message {
+	if len(req.Questions) == 1 {
+		name := CanonicalName(req.Questions[0].Name)
+		for {
+			if handler, ok := m.zones[name]; ok {
+				return handler.ServeDNS(req, from, transport)
+			}
+			_, parent, found := strings.Cut(name, ".")
+			if !found || parent == "" {
+				break
+			}
+			name = parent
+		}
+	}
+
+	resp := req.Reply()
+	resp.Rcode = RcodeRefused
+	if len(req.Questions) != 1 {
+		resp.Rcode = RcodeFormErr
+	}
+	return resp
+}
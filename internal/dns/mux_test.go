@@ -0,0 +1,41 @@
+package dns
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	named := func(name string) Handler {
+		return HandlerFunc(func(req *Message, from netip.AddrPort, transport string) *Message {
+			resp := req.Reply()
+			resp.Answers = []RR{{Name: req.Questions[0].Name, Type: TypeTXT, Class: ClassINET, TXT: []string{name}}}
+			return resp
+		})
+	}
+	mux := NewMux()
+	mux.Handle("example.com", named("parent"))
+	mux.Handle("Leak.Example.com.", named("leak"))
+
+	from := netip.MustParseAddrPort("192.0.2.1:53")
+	tests := map[string]string{
+		"example.com.":          "parent",
+		"www.example.com.":      "parent",
+		"LEAK.example.com.":     "leak",
+		"a.b.leak.example.com.": "leak",
+	}
+	for name, want := range tests {
+		resp := mux.ServeDNS(&Message{Questions: []Question{{Name: name, Type: TypeTXT, Class: ClassINET}}}, from, "udp")
+		if len(resp.Answers) != 1 || resp.Answers[0].TXT[0] != want {
+			t.Errorf("%s: expected %s handler, got %+v", name, want, resp)
+		}
+	}
+
+	resp := mux.ServeDNS(&Message{Questions: []Question{{Name: "example.org.", Type: TypeA, Class: ClassINET}}}, from, "udp")
+	if resp.Rcode != RcodeRefused {
+		t.Errorf("expected REFUSED outside the zones, got %+v", resp)
+	}
+	if resp = mux.ServeDNS(&Message{}, from, "udp"); resp.Rcode != RcodeFormErr {
+		t.Errorf("expected FORMERR without a question, got %+v", resp)
+	}
+}
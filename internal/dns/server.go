@@ -0,0 +1,183 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+)
+
+const (
+	minUDPSize     = 512
+	maxStreamConns = 1024
+	streamIdle     = 10 * time.Second
+)
+
+// Handler answers a query from addr. It returns nil to leave the query
+// unanswered.
+type Handler interface {
+	ServeDNS(req *Message, from netip.AddrPort, transport string) *Message
+}
+
+// HandlerFunc adapts a function to Handler.
+type HandlerFunc func(req *Message, from netip.AddrPort, transport string) *Message
+
+// ServeDNS calls f.
+func (f HandlerFunc) ServeDNS(req *Message, from netip.AddrPort, transport string) *Message {
+	return f(req, from, transport)
+}
+
+// Server serves DNS over UDP and TCP.
+type Server struct {
+	handler Handler
+}
+
+// NewServer creates a server answering through handler.
+func NewServer(handler Handler) *Server {
+	return &Server{handler: handler}
+}
+
+// Reply starts a response to the query m that echoes its ID, opcode,
+// recursion desired flag and question.
+func (m *Message) Reply() *Message {
+	return &Message{
+		Header: Header{
+			ID:               m.ID,
+			Response:         true,
+			Opcode:           m.Opcode,
+			RecursionDesired: m.RecursionDesired,
+		},
+		Questions: m.Questions,
+	}
+}
+
+// ServePacket answers queries on conn until ctx is done or conn fails.
+func (s *Server) ServePacket(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, maxUDPSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		udp, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		req, err := Unpack(buf[:n])
+		if err != nil || req.Response {
+			continue
+		}
+		if reply := s.answer(req, udp.AddrPort(), "udp"); reply != nil {
+			conn.WriteTo(reply, addr)
+		}
+	}
+}
+
+// ServeStream answers length-prefixed queries on connections accepted
+// from ln until ctx is done.
+func (s *Server) ServeStream(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	slots := make(chan struct{}, maxStreamConns)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer func() { <-slots }()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	from, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil {
+		return
+	}
+
+	var size [2]byte
+	for {
+		conn.SetReadDeadline(time.Now().Add(streamIdle))
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		req, err := Unpack(buf)
+		if err != nil || req.Response {
+			return
+		}
+		reply := s.answer(req, from, "tcp")
+		if reply == nil {
+			continue
+		}
+		conn.SetWriteDeadline(time.Now().Add(streamIdle))
+		if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...)); err != nil {
+			return
+		}
+	}
+}
+
+// answer runs the handler and packs its response. UDP responses larger
+// than the client accepts are truncated to the header and question so
+// the client retries over TCP.
+func (s *Server) answer(req *Message, from netip.AddrPort, transport string) []byte {
+	from = netip.AddrPortFrom(from.Addr().Unmap(), from.Port())
+	resp := s.handler.ServeDNS(req, from, transport)
+	if resp == nil {
+		return nil
+	}
+	packed, err := resp.Pack()
+	if err != nil {
+		resp = req.Reply()
+		resp.Rcode = RcodeServFail
+		if packed, err = resp.Pack(); err != nil {
+			return nil
+		}
+	}
+	if transport != "udp" {
+		return packed
+	}
+
+	limit := minUDPSize
+	if opt, ok := req.OPT(); ok {
+		limit = min(max(int(opt.Class), minUDPSize), maxUDPSize)
+	}
+	if len(packed) > limit {
+		truncated := resp.Reply()
+		truncated.Header = resp.Header
+		truncated.Truncated = true
+		if packed, err = truncated.Pack(); err != nil {
+			return nil
+		}
+	}
+	return packed
+}
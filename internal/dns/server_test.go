@@ -0,0 +1,69 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// echoHandler answers every TXT query with the querier's address and
+// transport, padded to size bytes.
+func echoHandler(size int) Handler {
+	return HandlerFunc(func(req *Message, from netip.AddrPort, transport string) *Message {
+		resp := req.Reply()
+		resp.Answers = []RR{{
+			Name: req.Questions[0].Name, Type: TypeTXT, Class: ClassINET,
+			TXT: []string{from.Addr().String() + " " + transport, strings.Repeat("x", size)},
+		}}
+		return resp
+	})
+}
+
+func TestServer_UDPAndTCP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(echoHandler(600))
+	go server.ServePacket(ctx, conn)
+	go server.ServeStream(ctx, ln)
+
+	// The 600 byte answer does not fit the classic 512 byte UDP limit, so
+	// the client retries over TCP.
+	client := NewClient(conn.LocalAddr().String())
+	resp, err := client.Query(ctx, "whoami.test.", TypeTXT)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(resp.Answers) != 1 || resp.Answers[0].TXT[0] != "127.0.0.1 tcp" {
+		t.Errorf("unexpected answers %+v", resp.Answers)
+	}
+}
+
+func TestServer_Truncate(t *testing.T) {
+	server := NewServer(echoHandler(600))
+	from := netip.MustParseAddrPort("192.0.2.1:5353")
+	req := &Message{Header: Header{ID: 7}, Questions: []Question{{Name: "a.test.", Type: TypeTXT, Class: ClassINET}}}
+
+	resp, err := Unpack(server.answer(req, from, "udp"))
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if !resp.Truncated || len(resp.Answers) != 0 || resp.ID != 7 {
+		t.Errorf("expected truncated reply, got %+v", resp)
+	}
+
+	req.Additional = []RR{NewOPT(1232)}
+	if resp, _ = Unpack(server.answer(req, from, "udp")); resp.Truncated || len(resp.Answers) != 1 {
+		t.Errorf("expected full reply within the EDNS size, got %+v", resp)
+	}
+}
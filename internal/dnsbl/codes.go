@@ -0,0 +1,89 @@
+package dnsbl
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// zoneCodes maps the return codes of well-known lists to reasons.
+var zoneCodes = map[string]map[string]string{
+	"zen.spamhaus.org": {
+		"127.0.0.2":  "SBL: Spamhaus spam source",
+		"127.0.0.3":  "SBL CSS: snowshoe spam",
+		"127.0.0.4":  "XBL: exploited or infected host",
+		"127.0.0.5":  "XBL: exploited or infected host",
+		"127.0.0.6":  "XBL: exploited or infected host",
+		"127.0.0.7":  "XBL: exploited or infected host",
+		"127.0.0.9":  "SBL DROP: hijacked netblock",
+		"127.0.0.10": "PBL: ISP maintained dynamic range",
+		"127.0.0.11": "PBL: Spamhaus maintained dynamic range",
+	},
+	"sbl.spamhaus.org": {
+		"127.0.0.2": "SBL: Spamhaus spam source",
+		"127.0.0.3": "SBL CSS: snowshoe spam",
+		"127.0.0.9": "SBL DROP: hijacked netblock",
+	},
+	"xbl.spamhaus.org": {
+		"127.0.0.4": "XBL: exploited or infected host",
+	},
+	"pbl.spamhaus.org": {
+		"127.0.0.10": "PBL: ISP maintained dynamic range",
+		"127.0.0.11": "PBL: Spamhaus maintained dynamic range",
+	},
+	"bl.spamcop.net": {
+		"127.0.0.2": "SpamCop: reported spam source",
+	},
+	"b.barracudacentral.org": {
+		"127.0.0.2": "Barracuda: poor reputation",
+	},
+	"dnsbl.sorbs.net": {
+		"127.0.0.2":  "SORBS: open HTTP proxy",
+		"127.0.0.3":  "SORBS: open SOCKS proxy",
+		"127.0.0.4":  "SORBS: misc open proxy",
+		"127.0.0.5":  "SORBS: open SMTP relay",
+		"127.0.0.6":  "SORBS: spam source",
+		"127.0.0.7":  "SORBS: vulnerable web server",
+		"127.0.0.9":  "SORBS: hijacked network",
+		"127.0.0.10": "SORBS: dynamic IP range",
+	},
+	"dnsbl.dronebl.org": {
+		"127.0.0.3":  "DroneBL: IRC drone",
+		"127.0.0.6":  "DroneBL: unknown spambot or drone",
+		"127.0.0.7":  "DroneBL: DDoS drone",
+		"127.0.0.8":  "DroneBL: SOCKS proxy",
+		"127.0.0.9":  "DroneBL: HTTP proxy",
+		"127.0.0.10": "DroneBL: ProxyChain",
+		"127.0.0.13": "DroneBL: brute force attacker",
+		"127.0.0.14": "DroneBL: open Wingate proxy",
+		"127.0.0.15": "DroneBL: compromised router",
+		"127.0.0.17": "DroneBL: automatically determined botnet IP",
+		"127.0.0.19": "DroneBL: abused VPN service",
+	},
+}
+
+// decode returns the reason for a return code and whether it is a
+// listing. Codes outside 127.0.0.0/8 and Spamhaus' 127.255.255.0/24 error
+// codes are reported as errors.
+func decode(zone string, code netip.Addr) (string, bool) {
+	code = code.Unmap()
+	if !code.Is4() || code.As4()[0] != 127 {
+		return "unexpected answer " + code.String(), false
+	}
+	b := code.As4()
+	if b[1] == 255 && b[2] == 255 {
+		switch b[3] {
+		case 252:
+			return "query refused: typing error in zone name", false
+		case 254:
+			return "query refused: public resolver", false
+		case 255:
+			return "query refused: excessive number of queries", false
+		}
+		return "query refused: " + code.String(), false
+	}
+
+	if reason, ok := zoneCodes[strings.ToLower(strings.TrimSuffix(zone, "."))][code.String()]; ok {
+		return reason, true
+	}
+	return "listed (" + code.String() + ")", true
+}
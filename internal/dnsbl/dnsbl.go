@@ -0,0 +1,156 @@
+package dnsbl
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"myip/internal/dns"
+	"myip/internal/netcalc"
+)
+
+const cacheTTL = 30 * time.Minute
+
+// DefaultZones are checked when no zones are configured.
+var DefaultZones = []string{"zen.spamhaus.org", "bl.spamcop.net", "b.barracudacentral.org"}
+
+// Result is the reputation of an address across all configured zones.
+type Result struct {
+	Listed bool         `json:"listed"`
+	Zones  []ZoneResult `json:"zones"`
+}
+
+// ZoneResult is the answer of a single blocklist.
+type ZoneResult struct {
+	Zone    string   `json:"zone"`
+	Listed  bool     `json:"listed"`
+	Codes   []string `json:"codes,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Querier sends a single DNS query.
+type Querier interface {
+	Query(ctx context.Context, name string, qtype uint16) (*dns.Message, error)
+}
+
+// Cache stores results as JSON values with a TTL.
+type Cache interface {
+	GetValue(ctx context.Context, key string, v any) (bool, error)
+	SetValue(ctx context.Context, key string, v any, ttl time.Duration) error
+}
+
+// Checker queries DNS blocklists.
+type Checker struct {
+	client Querier
+	zones  []string
+	cache  Cache
+}
+
+// NewChecker creates a checker for zones. cache may be nil.
+func NewChecker(client Querier, zones []string, cache Cache) *Checker {
+	if len(zones) == 0 {
+		zones = DefaultZones
+	}
+	return &Checker{client: client, zones: zones, cache: cache}
+}
+
+// Check queries all zones concurrently. ok is false for addresses that
+// are not globally routable and therefore never listed. Results with
+// failed zones are not cached; a cache error is returned together with a
+// valid result.
+func (c *Checker) Check(ctx context.Context, ip string) (Result, bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Result{}, false, fmt.Errorf("dnsbl: invalid address %q", ip)
+	}
+	addr = addr.Unmap()
+	if !netcalc.IsGlobal(addr) {
+		return Result{}, false, nil
+	}
+
+	key := "dnsbl:" + addr.String()
+	var cacheErr error
+	if c.cache != nil {
+		var cached Result
+		ok, err := c.cache.GetValue(ctx, key, &cached)
+		if err != nil {
+			cacheErr = err
+		} else if ok {
+			return cached, true, nil
+		}
+	}
+
+	result := Result{Zones: make([]ZoneResult, len(c.zones))}
+	label := queryLabel(addr)
+	var wg sync.WaitGroup
+	for i, zone := range c.zones {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result.Zones[i] = c.checkZone(ctx, label, zone)
+		}()
+	}
+	wg.Wait()
+
+	failed := false
+	for _, zone := range result.Zones {
+		result.Listed = result.Listed || zone.Listed
+		failed = failed || zone.Error != ""
+	}
+
+	if c.cache != nil && cacheErr == nil && !failed {
+		cacheErr = c.cache.SetValue(ctx, key, result, cacheTTL)
+	}
+	return result, true, cacheErr
+}
+
+func (c *Checker) checkZone(ctx context.Context, label, zone string) ZoneResult {
+	result := ZoneResult{Zone: zone}
+	resp, err := c.client.Query(ctx, label+dns.CanonicalName(zone), dns.TypeA)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	switch resp.Rcode {
+	case dns.RcodeNXDomain:
+		return result
+	case dns.RcodeSuccess:
+	default:
+		result.Error = fmt.Sprintf("rcode %d", resp.Rcode)
+		return result
+	}
+
+	for _, rr := range resp.Answers {
+		if rr.Type != dns.TypeA {
+			continue
+		}
+		reason, listed := decode(zone, rr.Addr)
+		if !listed {
+			// Refusals such as Spamhaus 127.255.255.254 for queries via
+			// public resolvers are errors, not listings.
+			result.Error = reason
+			continue
+		}
+		result.Listed = true
+		result.Codes = append(result.Codes, rr.Addr.String())
+		result.Reasons = append(result.Reasons, reason)
+	}
+	if result.Listed {
+		result.Error = ""
+	}
+	return result
+}
+
+// queryLabel returns the reversed octets or nibbles of addr followed by a
+// dot, ready to prepend to a zone.
+func queryLabel(addr netip.Addr) string {
+	name := netcalc.PTRName(addr)
+	if addr.Is4() {
+		return strings.TrimSuffix(name, "in-addr.arpa.")
+	}
+	return strings.TrimSuffix(name, "ip6.arpa.")
+}
@@ -0,0 +1,108 @@
+package dnsbl
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"myip/internal/dns"
+)
+
+type memoryCache map[string][]byte
+
+func (m memoryCache) GetValue(ctx context.Context, key string, v any) (bool, error) {
+	data, ok := m[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (m memoryCache) SetValue(ctx context.Context, key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	m[key] = data
+	return err
+}
+
+// serveDNS answers A queries from a fixed table of names until the test
+// ends; unknown names get NXDOMAIN.
+func serveDNS(t *testing.T, records map[string][]string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query, err := dns.Unpack(buf[:n])
+			if err != nil {
+				continue
+			}
+			q := query.Questions[0]
+			resp := &dns.Message{Header: dns.Header{ID: query.ID, Response: true}, Questions: query.Questions}
+			codes, ok := records[q.Name]
+			if !ok {
+				resp.Rcode = dns.RcodeNXDomain
+			}
+			for _, code := range codes {
+				resp.Answers = append(resp.Answers, dns.RR{
+					Name: q.Name, Type: dns.TypeA, Class: dns.ClassINET, TTL: 300, Addr: netip.MustParseAddr(code),
+				})
+			}
+			packed, _ := resp.Pack()
+			conn.WriteTo(packed, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestChecker_Check(t *testing.T) {
+	server := serveDNS(t, map[string][]string{
+		"2.0.0.127.zen.spamhaus.org.": {"127.0.0.2", "127.0.0.10"},
+		"2.0.0.127.bl.spamcop.net.":   {"127.0.0.2"},
+		"8.8.8.8.zen.spamhaus.org.":   {"127.255.255.254"},
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.2.6.2.zen.spamhaus.org.": {"127.0.0.4"},
+	})
+	zones := []string{"zen.spamhaus.org", "bl.spamcop.net"}
+	cache := memoryCache{}
+	checker := NewChecker(dns.NewClient(server), zones, cache)
+	ctx := context.Background()
+
+	// 127.0.0.2 is the conventional test entry but not global, so query
+	// its listing through the label helper instead.
+	if _, ok, _ := checker.Check(ctx, "127.0.0.2"); ok {
+		t.Error("expected non-global address to be skipped")
+	}
+	zone := checker.checkZone(ctx, queryLabel(netip.MustParseAddr("127.0.0.2")), "zen.spamhaus.org")
+	if !zone.Listed || len(zone.Reasons) != 2 || zone.Reasons[1] != "PBL: ISP maintained dynamic range" {
+		t.Errorf("unexpected zone result %+v", zone)
+	}
+
+	result, ok, err := checker.Check(ctx, "2620::1")
+	if err != nil || !ok {
+		t.Fatalf("Check failed: ok=%v err=%v", ok, err)
+	}
+	if !result.Listed || !result.Zones[0].Listed || result.Zones[1].Listed {
+		t.Errorf("expected IPv6 listing on zen only, got %+v", result)
+	}
+	if _, cached := cache["dnsbl:2620::1"]; !cached {
+		t.Error("expected clean result to be cached")
+	}
+
+	result, _, _ = checker.Check(ctx, "8.8.8.8")
+	if result.Listed || result.Zones[0].Error != "query refused: public resolver" {
+		t.Errorf("expected refusal to be an error, got %+v", result)
+	}
+	if _, cached := cache["dnsbl:8.8.8.8"]; cached {
+		t.Error("expected result with errors not to be cached")
+	}
+}
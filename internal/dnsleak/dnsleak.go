@@ -0,0 +1,245 @@
+// Package dnsleak reveals the recursive resolvers a browser uses. Every
+// visit gets random subdomains of a delegated zone; the page resolves them
+// and the authoritative responder records which resolvers asked.
+package dnsleak
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"myip/internal/dns"
+)
+
+const (
+	// TTL bounds how long a visit accepts and keeps queries.
+	TTL = 10 * time.Minute
+	// HostsPerVisit is the number of names the page resolves. Stub
+	// resolvers spread queries over their configured servers, so more names
+	// reveal more of them.
+	HostsPerVisit = 6
+
+	idBytes      = 10
+	answerTTL    = 0
+	udpSize      = 1232
+	queueSize    = 1024
+	storeTimeout = 2 * time.Second
+)
+
+// Store keeps the queries of a visit.
+type Store interface {
+	StartDNSLeak(ctx context.Context, id string, ttl time.Duration) error
+	AddDNSLeakQuery(ctx context.Context, id string, data []byte) (bool, error)
+	DNSLeakQueries(ctx context.Context, id string) ([][]byte, bool, error)
+}
+
+// Visit is a started test.
+type Visit struct {
+	ID    string   `json:"id"`
+	Hosts []string `json:"hosts"`
+}
+
+// Query is a lookup of a visit name seen by the responder.
+type Query struct {
+	Resolver  string    `json:"resolver"`
+	Subnet    string    `json:"subnet,omitempty"`
+	Transport string    `json:"transport"`
+	At        time.Time `json:"at"`
+}
+
+// Resolver aggregates the queries of one resolver address.
+type Resolver struct {
+	IP        string    `json:"ip"`
+	Subnets   []string  `json:"subnets,omitempty"`
+	Queries   int       `json:"queries"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+type record struct {
+	id    string
+	query Query
+}
+
+// Test issues visits and answers the DNS queries for their names.
+type Test struct {
+	zone  string
+	addrs []netip.Addr
+	store Store
+	soa   dns.RR
+	queue chan record
+	now   func() time.Time
+}
+
+// New creates a test authoritative for zone. A and AAAA queries for visit
+// names are answered with the addresses of matching family in addrs.
+func New(zone string, addrs []netip.Addr, store Store) *Test {
+	zone = dns.CanonicalName(zone)
+	return &Test{
+		zone:  zone,
+		addrs: addrs,
+		store: store,
+		soa:   dns.NewSOA(zone, answerTTL),
+		queue: make(chan record, queueSize),
+		now:   time.Now,
+	}
+}
+
+// Start issues a new visit whose names are resolvable until TTL passes.
+func (t *Test) Start(ctx context.Context) (Visit, error) {
+	buf := make([]byte, idBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return Visit{}, fmt.Errorf("dns leak id: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+	if err := t.store.StartDNSLeak(ctx, id, TTL); err != nil {
+		return Visit{}, err
+	}
+
+	visit := Visit{ID: id, Hosts: make([]string, 0, HostsPerVisit)}
+	for i := range HostsPerVisit {
+		visit.Hosts = append(visit.Hosts, strconv.Itoa(i)+"."+id+"."+strings.TrimSuffix(t.zone, "."))
+	}
+	return visit, nil
+}
+
+// Results returns the resolvers that queried names of visit id in the
+// order they first did. It reports false for unknown or expired visits.
+func (t *Test) Results(ctx context.Context, id string) ([]Resolver, bool, error) {
+	if !validID(id) {
+		return nil, false, nil
+	}
+	items, ok, err := t.store.DNSLeakQueries(ctx, id)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+
+	resolvers := []Resolver{}
+	index := map[string]int{}
+	for _, data := range items {
+		var q Query
+		if err := json.Unmarshal(data, &q); err != nil {
+			continue
+		}
+		i, seen := index[q.Resolver]
+		if !seen {
+			i = len(resolvers)
+			index[q.Resolver] = i
+			resolvers = append(resolvers, Resolver{IP: q.Resolver, FirstSeen: q.At})
+		}
+		r := &resolvers[i]
+		r.Queries++
+		if q.Subnet != "" && !slices.Contains(r.Subnets, q.Subnet) {
+			r.Subnets = append(r.Subnets, q.Subnet)
+		}
+	}
+	return resolvers, true, nil
+}
+
+// Run stores recorded queries until ctx is done. A single worker keeps a
+// query flood from piling up store calls; queries arriving while the
+// queue is full are dropped.
+func (t *Test) Run(ctx context.Context, onError func(error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case rec := <-t.queue:
+			data, err := json.Marshal(rec.query)
+			if err != nil {
+				continue
+			}
+			storeCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+			if _, err := t.store.AddDNSLeakQuery(storeCtx, rec.id, data); err != nil {
+				onError(fmt.Errorf("dns leak: %w", err))
+			}
+			cancel()
+		}
+	}
+}
+
+// ServeDNS implements dns.Handler.
+func (t *Test) ServeDNS(req *dns.Message, from netip.AddrPort, transport string) *dns.Message {
+	resp := req.Reply()
+	resp.Authoritative = true
+
+	var subnet string
+	if opt, ok := req.OPT(); ok {
+		var options []dns.Option
+		if ecs := dns.ClientSubnetOf(opt); ecs != nil {
+			subnet = ecs.Prefix.String()
+			// Answers do not depend on the subnet.
+			ecs.ScopePrefix = 0
+			options = append(options, ecs.Option())
+		}
+		resp.Additional = append(resp.Additional, dns.NewOPT(udpSize, options...))
+	}
+
+	switch {
+	case req.Opcode != 0:
+		resp.Rcode = dns.RcodeNotImp
+		return resp
+	case len(req.Questions) != 1:
+		resp.Rcode = dns.RcodeFormErr
+		return resp
+	}
+
+	q := req.Questions[0]
+	name := dns.CanonicalName(q.Name)
+	if name == t.zone {
+		if q.Type == dns.TypeSOA {
+			resp.Answers = append(resp.Answers, t.soa)
+		} else {
+			resp.Authority = append(resp.Authority, t.soa)
+		}
+		return resp
+	}
+	labels, found := strings.CutSuffix(name, "."+t.zone)
+	if !found {
+		resp.Authoritative = false
+		resp.Rcode = dns.RcodeRefused
+		return resp
+	}
+
+	// Visit names are <n>.<id>.zone; resolvers minimizing the query name
+	// ask for <id>.zone first.
+	parts := strings.Split(labels, ".")
+	id := parts[len(parts)-1]
+	if len(parts) > 2 || !validID(id) {
+		resp.Rcode = dns.RcodeNXDomain
+		resp.Authority = append(resp.Authority, t.soa)
+		return resp
+	}
+	t.record(id, Query{Resolver: from.Addr().String(), Subnet: subnet, Transport: transport, At: t.now().UTC()})
+
+	for _, addr := range t.addrs {
+		if q.Type == dns.TypeA && addr.Is4() || q.Type == dns.TypeAAAA && addr.Is6() {
+			resp.Answers = append(resp.Answers, dns.RR{Name: q.Name, Type: q.Type, Class: dns.ClassINET, TTL: answerTTL, Addr: addr})
+		}
+	}
+	if len(resp.Answers) == 0 {
+		resp.Authority = append(resp.Authority, t.soa)
+	}
+	return resp
+}
+
+func (t *Test) record(id string, q Query) {
+	select {
+	case t.queue <- record{id: id, query: q}:
+	default:
+	}
+}
+
+func validID(id string) bool {
+	if len(id) != 2*idBytes {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
@@ -0,0 +1,149 @@
+package dnsleak
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"myip/internal/dns"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	visits  map[string]bool
+	queries map[string][][]byte
+	added   chan struct{}
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{visits: map[string]bool{}, queries: map[string][][]byte{}, added: make(chan struct{}, 16)}
+}
+
+func (m *memoryStore) StartDNSLeak(ctx context.Context, id string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.visits[id] = true
+	return nil
+}
+
+func (m *memoryStore) AddDNSLeakQuery(ctx context.Context, id string, data []byte) (bool, error) {
+	m.mu.Lock()
+	defer func() {
+		m.mu.Unlock()
+		m.added <- struct{}{}
+	}()
+	if !m.visits[id] {
+		return false, nil
+	}
+	m.queries[id] = append(m.queries[id], data)
+	return true, nil
+}
+
+func (m *memoryStore) DNSLeakQueries(ctx context.Context, id string) ([][]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.queries[id], m.visits[id], nil
+}
+
+func query(name string, qtype uint16, subnet string) *dns.Message {
+	req := &dns.Message{Questions: []dns.Question{{Name: name, Type: qtype, Class: dns.ClassINET}}}
+	if subnet != "" {
+		ecs := dns.ClientSubnet{Prefix: netip.MustParsePrefix(subnet)}
+		req.Additional = []dns.RR{dns.NewOPT(1232, ecs.Option())}
+	}
+	return req
+}
+
+func TestTest_RecordsResolvers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := newMemoryStore()
+	test := New("Leak.Example.com", []netip.Addr{netip.MustParseAddr("192.0.2.80")}, store)
+	go test.Run(ctx, func(err error) { t.Error(err) })
+
+	visit, err := test.Start(ctx)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if len(visit.Hosts) != HostsPerVisit || visit.Hosts[0] != "0."+visit.ID+".leak.example.com" {
+		t.Fatalf("unexpected visit %+v", visit)
+	}
+
+	first := netip.MustParseAddrPort("198.51.100.1:4000")
+	second := netip.MustParseAddrPort("[2001:db8::53]:4000")
+	// Resolvers randomize the case of query names.
+	resp := test.ServeDNS(query(strings.ToUpper(visit.Hosts[0])+".", dns.TypeA, "203.0.113.0/24"), first, "udp")
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answers) != 1 || resp.Answers[0].Addr.String() != "192.0.2.80" {
+		t.Fatalf("unexpected A answer %+v", resp)
+	}
+	opt, ok := resp.OPT()
+	if !ok {
+		t.Fatal("expected OPT echoed")
+	}
+	options, _ := dns.ParseOptions(opt)
+	if ecs, err := dns.ParseClientSubnet(options[0].Data); err != nil || ecs.ScopePrefix != 0 {
+		t.Errorf("expected ECS with scope 0, got %+v, %v", ecs, err)
+	}
+
+	resp = test.ServeDNS(query(visit.Hosts[1]+".", dns.TypeAAAA, ""), first, "udp")
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answers) != 0 || len(resp.Authority) != 1 {
+		t.Errorf("expected NODATA for AAAA, got %+v", resp)
+	}
+	test.ServeDNS(query(visit.ID+".leak.example.com.", dns.TypeA, ""), second, "tcp")
+	for range 3 {
+		<-store.added
+	}
+
+	resolvers, ok, err := test.Results(ctx, visit.ID)
+	if err != nil || !ok {
+		t.Fatalf("Results failed: %v, %v", ok, err)
+	}
+	if len(resolvers) != 2 {
+		t.Fatalf("expected 2 resolvers, got %+v", resolvers)
+	}
+	if r := resolvers[0]; r.IP != "198.51.100.1" || r.Queries != 2 || len(r.Subnets) != 1 || r.Subnets[0] != "203.0.113.0/24" {
+		t.Errorf("unexpected first resolver %+v", r)
+	}
+	if r := resolvers[1]; r.IP != "2001:db8::53" || r.Queries != 1 {
+		t.Errorf("unexpected second resolver %+v", r)
+	}
+}
+
+func TestTest_ServeDNS(t *testing.T) {
+	test := New("leak.example.com.", nil, newMemoryStore())
+	from := netip.MustParseAddrPort("198.51.100.1:4000")
+	id := strings.Repeat("ab", idBytes)
+
+	tests := []struct {
+		name    string
+		qtype   uint16
+		rcode   uint8
+		answers int
+	}{
+		{"leak.example.com.", dns.TypeSOA, dns.RcodeSuccess, 1},
+		{"leak.example.com.", dns.TypeA, dns.RcodeSuccess, 0},
+		{"0." + id + ".leak.example.com.", dns.TypeA, dns.RcodeSuccess, 0},
+		{"short.leak.example.com.", dns.TypeA, dns.RcodeNXDomain, 0},
+		{"a.0." + id + ".leak.example.com.", dns.TypeA, dns.RcodeNXDomain, 0},
+		{"example.org.", dns.TypeA, dns.RcodeRefused, 0},
+	}
+	for _, tt := range tests {
+		resp := test.ServeDNS(query(tt.name, tt.qtype, ""), from, "udp")
+		if resp.Rcode != tt.rcode || len(resp.Answers) != tt.answers {
+			t.Errorf("%s %v: expected rcode %v with %d answers, got %+v", tt.name, tt.qtype, tt.rcode, tt.answers, resp)
+		}
+	}
+}
+
+func TestTest_ResultsUnknownVisit(t *testing.T) {
+	test := New("leak.example.com", nil, newMemoryStore())
+	for _, id := range []string{"missing", strings.Repeat("AB", idBytes), strings.Repeat("ab", idBytes)} {
+		if _, ok, err := test.Results(context.Background(), id); ok || err != nil {
+			t.Errorf("%s: expected unknown visit, got %v, %v", id, ok, err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package dualstack checks IPv4 and IPv6 connectivity like test-ipv6.com.
+// The page fetches probes from an IPv4-only, an IPv6-only and a dual-stack
+// host; the server records the address each probe came from and the page
+// reports which probes completed and how fast.
+package dualstack
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// TTL bounds how long a visit accepts probes.
+const TTL = 10 * time.Minute
+
+// Probe names. The large probes return a response spanning several full
+// size packets, so they fail where path MTU discovery is broken.
+const (
+	ProbeIPv4      = "ipv4"
+	ProbeIPv6      = "ipv6"
+	ProbeDual      = "dual"
+	ProbeIPv4Large = "ipv4_large"
+	ProbeIPv6Large = "ipv6_large"
+)
+
+// Address families.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// Connectivity verdicts.
+const (
+	StatusDualStack = "dual_stack"
+	StatusIPv4Only  = "ipv4_only"
+	StatusIPv6Only  = "ipv6_only"
+	StatusNone      = "none"
+)
+
+const (
+	idBytes = 10
+	// slowerBy is the delay after which a family counts as noticeably
+	// slower; browsers give IPv6 a 250-300ms head start.
+	slowerBy = 300 * time.Millisecond
+)
+
+// Store keeps the observed probes of a visit.
+type Store interface {
+	StartDualStack(ctx context.Context, id string, ttl time.Duration) error
+	AddDualStackProbe(ctx context.Context, id, probe string, data []byte) (bool, error)
+	DualStackProbes(ctx context.Context, id string) (map[string][]byte, bool, error)
+}
+
+// Hosts are the names probes are fetched from, optionally with a port.
+type Hosts struct {
+	IPv4 string
+	IPv6 string
+}
+
+// Visit is a started test. Probe URLs are scheme relative so the page
+// fetches them the way it was loaded.
+type Visit struct {
+	ID     string            `json:"id"`
+	Probes map[string]string `json:"probes"`
+}
+
+// Observation is what the server saw of a probe request.
+type Observation struct {
+	IP     string    `json:"ip"`
+	Family string    `json:"family"`
+	At     time.Time `json:"at"`
+}
+
+// ClientResult is what the page saw of a probe.
+type ClientResult struct {
+	OK         bool    `json:"ok"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// ProbeResult combines both views of a probe.
+type ProbeResult struct {
+	Name string `json:"name"`
+	ClientResult
+	Observation *Observation `json:"observation,omitempty"`
+}
+
+// Summary is the outcome of a visit.
+type Summary struct {
+	Status        string        `json:"status"`
+	IPv4          string        `json:"ipv4,omitempty"`
+	IPv6          string        `json:"ipv6,omitempty"`
+	Preferred     string        `json:"preferred,omitempty"`
+	HappyEyeballs string        `json:"happy_eyeballs"`
+	MTU           []string      `json:"mtu"`
+	Probes        []ProbeResult `json:"probes"`
+}
+
+// Test issues visits and evaluates their probes.
+type Test struct {
+	hosts Hosts
+	store Store
+	now   func() time.Time
+}
+
+// New creates a test fetching single-family probes from hosts.
+func New(hosts Hosts, store Store) *Test {
+	return &Test{hosts: hosts, store: store, now: time.Now}
+}
+
+// Start issues a visit. dualHost is the dual-stack name the page was
+// loaded from.
+func (t *Test) Start(ctx context.Context, dualHost string) (Visit, error) {
+	buf := make([]byte, idBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return Visit{}, fmt.Errorf("dual stack id: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+	if err := t.store.StartDualStack(ctx, id, TTL); err != nil {
+		return Visit{}, err
+	}
+
+	url := func(host, probe string) string {
+		return "//" + host + "/api/dualstack/" + id + "/" + probe
+	}
+	return Visit{ID: id, Probes: map[string]string{
+		ProbeIPv4:      url(t.hosts.IPv4, ProbeIPv4),
+		ProbeIPv6:      url(t.hosts.IPv6, ProbeIPv6),
+		ProbeDual:      url(dualHost, ProbeDual),
+		ProbeIPv4Large: url(t.hosts.IPv4, ProbeIPv4Large),
+		ProbeIPv6Large: url(t.hosts.IPv6, ProbeIPv6Large),
+	}}, nil
+}
+
+// Record stores that probe of visit id arrived from addr. It reports false
+// for unknown probes and unknown or expired visits.
+func (t *Test) Record(ctx context.Context, id, probe string, addr netip.Addr) (Observation, bool, error) {
+	if !validProbe(probe) || !validID(id) {
+		return Observation{}, false, nil
+	}
+	addr = addr.Unmap()
+	obs := Observation{IP: addr.String(), Family: FamilyIPv4, At: t.now().UTC()}
+	if addr.Is6() {
+		obs.Family = FamilyIPv6
+	}
+	data, err := json.Marshal(obs)
+	if err != nil {
+		return Observation{}, false, fmt.Errorf("encode probe: %w", err)
+	}
+	ok, err := t.store.AddDualStackProbe(ctx, id, probe, data)
+	if err != nil || !ok {
+		return Observation{}, false, err
+	}
+	return obs, true, nil
+}
+
+// Summarize evaluates visit id from the observed probes and the results
+// the page reported. It reports false for unknown or expired visits.
+func (t *Test) Summarize(ctx context.Context, id string, results map[string]ClientResult) (Summary, bool, error) {
+	if !validID(id) {
+		return Summary{}, false, nil
+	}
+	items, ok, err := t.store.DualStackProbes(ctx, id)
+	if err != nil || !ok {
+		return Summary{}, ok, err
+	}
+	observed := map[string]*Observation{}
+	for probe, data := range items {
+		var obs Observation
+		if validProbe(probe) && json.Unmarshal(data, &obs) == nil {
+			observed[probe] = &obs
+		}
+	}
+	return summarize(observed, results), true, nil
+}
+
+func summarize(observed map[string]*Observation, results map[string]ClientResult) Summary {
+	summary := Summary{MTU: []string{}}
+	for _, probe := range []string{ProbeIPv4, ProbeIPv6, ProbeDual, ProbeIPv4Large, ProbeIPv6Large} {
+		summary.Probes = append(summary.Probes, ProbeResult{Name: probe, ClientResult: results[probe], Observation: observed[probe]})
+	}
+
+	// A single-family probe counts when the page completed it and the
+	// server saw it arrive over the intended family.
+	works := func(probe, family string) bool {
+		obs := observed[probe]
+		return results[probe].OK && obs != nil && obs.Family == family
+	}
+	v4, v6 := works(ProbeIPv4, FamilyIPv4), works(ProbeIPv6, FamilyIPv6)
+	if v4 {
+		summary.IPv4 = observed[ProbeIPv4].IP
+	}
+	if v6 {
+		summary.IPv6 = observed[ProbeIPv6].IP
+	}
+	switch {
+	case v4 && v6:
+		summary.Status = StatusDualStack
+	case v4:
+		summary.Status = StatusIPv4Only
+	case v6:
+		summary.Status = StatusIPv6Only
+	default:
+		summary.Status = StatusNone
+	}
+
+	dual := observed[ProbeDual]
+	if dual != nil && results[ProbeDual].OK {
+		summary.Preferred = dual.Family
+	}
+	summary.HappyEyeballs = happyEyeballs(summary, results)
+
+	for _, check := range []struct {
+		works        bool
+		probe, label string
+	}{
+		{v4, ProbeIPv4Large, "IPv4"},
+		{v6, ProbeIPv6Large, "IPv6"},
+	} {
+		if !check.works {
+			continue
+		}
+		if results[check.probe].OK {
+			summary.MTU = append(summary.MTU, check.label+" large responses arrive intact")
+		} else {
+			summary.MTU = append(summary.MTU, check.label+" small responses work but large ones fail: path MTU discovery is likely broken by filtered ICMP or a tunnel with a smaller MTU")
+		}
+	}
+	return summary
+}
+
+func happyEyeballs(summary Summary, results map[string]ClientResult) string {
+	v4Time := time.Duration(results[ProbeIPv4].DurationMs * float64(time.Millisecond))
+	v6Time := time.Duration(results[ProbeIPv6].DurationMs * float64(time.Millisecond))
+	switch {
+	case summary.Preferred == "":
+		return "the dual-stack probe failed, so the preferred family is unknown"
+	case summary.Status != StatusDualStack:
+		return "only one family works, so the browser has no choice to make"
+	case summary.Preferred == FamilyIPv6 && v6Time > v4Time+slowerBy:
+		return "the browser prefers IPv6 even though it is noticeably slower here"
+	case summary.Preferred == FamilyIPv6:
+		return "the browser prefers IPv6 as recommended (RFC 6724, RFC 8305)"
+	case v6Time > v4Time+slowerBy:
+		return "the browser fell back to IPv4 because IPv6 is noticeably slower"
+	default:
+		return "the browser uses IPv4 although IPv6 works; the system may prefer IPv4 or IPv6 lost the Happy Eyeballs race"
+	}
+}
+
+func validProbe(probe string) bool {
+	switch probe {
+	case ProbeIPv4, ProbeIPv6, ProbeDual, ProbeIPv4Large, ProbeIPv6Large:
+		return true
+	}
+	return false
+}
+
+func validID(id string) bool {
+	if len(id) != 2*idBytes {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,152 @@
+package dualstack
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	mu     sync.Mutex
+	visits map[string]map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{visits: map[string]map[string][]byte{}}
+}
+
+func (m *memoryStore) StartDualStack(ctx context.Context, id string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.visits[id] = map[string][]byte{}
+	return nil
+}
+
+func (m *memoryStore) AddDualStackProbe(ctx context.Context, id, probe string, data []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	probes, ok := m.visits[id]
+	if !ok {
+		return false, nil
+	}
+	probes[probe] = data
+	return true, nil
+}
+
+func (m *memoryStore) DualStackProbes(ctx context.Context, id string) (map[string][]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	probes, ok := m.visits[id]
+	return probes, ok, nil
+}
+
+func TestTest_DualStack(t *testing.T) {
+	ctx := context.Background()
+	test := New(Hosts{IPv4: "v4.example.com", IPv6: "v6.example.com:8443"}, newMemoryStore())
+
+	visit, err := test.Start(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if len(visit.Probes) != 5 ||
+		visit.Probes[ProbeIPv6Large] != "//v6.example.com:8443/api/dualstack/"+visit.ID+"/ipv6_large" ||
+		visit.Probes[ProbeDual] != "//example.com/api/dualstack/"+visit.ID+"/dual" {
+		t.Fatalf("unexpected visit %+v", visit)
+	}
+
+	v4 := netip.MustParseAddr("::ffff:198.51.100.7")
+	v6 := netip.MustParseAddr("2001:db8::7")
+	for probe, addr := range map[string]netip.Addr{
+		ProbeIPv4: v4, ProbeIPv4Large: v4, ProbeIPv6: v6, ProbeIPv6Large: v6, ProbeDual: v6,
+	} {
+		if _, ok, err := test.Record(ctx, visit.ID, probe, addr); !ok || err != nil {
+			t.Fatalf("Record %s failed: %v, %v", probe, ok, err)
+		}
+	}
+
+	summary, ok, err := test.Summarize(ctx, visit.ID, map[string]ClientResult{
+		ProbeIPv4:      {OK: true, DurationMs: 40},
+		ProbeIPv6:      {OK: true, DurationMs: 45},
+		ProbeDual:      {OK: true, DurationMs: 45},
+		ProbeIPv4Large: {OK: true, DurationMs: 60},
+	})
+	if err != nil || !ok {
+		t.Fatalf("Summarize failed: %v, %v", ok, err)
+	}
+	if summary.Status != StatusDualStack || summary.IPv4 != "198.51.100.7" || summary.IPv6 != "2001:db8::7" || summary.Preferred != FamilyIPv6 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if !strings.Contains(summary.HappyEyeballs, "prefers IPv6") {
+		t.Errorf("unexpected Happy Eyeballs verdict %q", summary.HappyEyeballs)
+	}
+	if len(summary.MTU) != 2 || !strings.Contains(summary.MTU[0], "intact") || !strings.Contains(summary.MTU[1], "path MTU") {
+		t.Errorf("unexpected MTU hints %v", summary.MTU)
+	}
+	if len(summary.Probes) != 5 || summary.Probes[0].Observation == nil {
+		t.Errorf("unexpected probes %+v", summary.Probes)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	v4 := &Observation{IP: "198.51.100.7", Family: FamilyIPv4}
+	v6 := &Observation{IP: "2001:db8::7", Family: FamilyIPv6}
+
+	tests := map[string]struct {
+		observed      map[string]*Observation
+		results       map[string]ClientResult
+		status        string
+		happyEyeballs string
+	}{
+		"ipv4 only": {
+			observed:      map[string]*Observation{ProbeIPv4: v4, ProbeDual: v4},
+			results:       map[string]ClientResult{ProbeIPv4: {OK: true}, ProbeDual: {OK: true}},
+			status:        StatusIPv4Only,
+			happyEyeballs: "only one family",
+		},
+		"slow ipv6 fallback": {
+			observed:      map[string]*Observation{ProbeIPv4: v4, ProbeIPv6: v6, ProbeDual: v4},
+			results:       map[string]ClientResult{ProbeIPv4: {OK: true, DurationMs: 30}, ProbeIPv6: {OK: true, DurationMs: 900}, ProbeDual: {OK: true}},
+			status:        StatusDualStack,
+			happyEyeballs: "fell back to IPv4",
+		},
+		// NAT64 or a misconfigured name makes the IPv4 probe arrive over IPv6.
+		"wrong family": {
+			observed:      map[string]*Observation{ProbeIPv4: v6, ProbeIPv6: v6},
+			results:       map[string]ClientResult{ProbeIPv4: {OK: true}, ProbeIPv6: {OK: true}},
+			status:        StatusIPv6Only,
+			happyEyeballs: "unknown",
+		},
+		"page reported failure": {
+			observed:      map[string]*Observation{ProbeIPv4: v4},
+			results:       map[string]ClientResult{},
+			status:        StatusNone,
+			happyEyeballs: "unknown",
+		},
+	}
+	for name, tt := range tests {
+		summary := summarize(tt.observed, tt.results)
+		if summary.Status != tt.status || !strings.Contains(summary.HappyEyeballs, tt.happyEyeballs) {
+			t.Errorf("%s: got status %s, %q", name, summary.Status, summary.HappyEyeballs)
+		}
+	}
+}
+
+func TestTest_Unknown(t *testing.T) {
+	ctx := context.Background()
+	test := New(Hosts{}, newMemoryStore())
+	visit, _ := test.Start(ctx, "example.com")
+	addr := netip.MustParseAddr("198.51.100.7")
+
+	if _, ok, _ := test.Record(ctx, visit.ID, "other", addr); ok {
+		t.Error("expected unknown probe to be rejected")
+	}
+	if _, ok, _ := test.Record(ctx, strings.Repeat("ab", idBytes), ProbeIPv4, addr); ok {
+		t.Error("expected unknown visit to be rejected")
+	}
+	if _, ok, _ := test.Summarize(ctx, "../x", nil); ok {
+		t.Error("expected invalid id to be rejected")
+	}
+}
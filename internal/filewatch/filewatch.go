@@ -0,0 +1,90 @@
+// Package filewatch keeps data loaded from files on disk current by
+// polling them for changes, so data set updates need no restart.
+package filewatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Watcher holds the value loaded from a file or directory and reloads it
+// when the signature of the source changes.
+type Watcher[T any] struct {
+	name      string
+	stat      func() (string, error)
+	load      func() (*T, error)
+	value     atomic.Pointer[T]
+	mu        sync.Mutex
+	signature string
+}
+
+// New loads the value once. stat returns a signature that changes with
+// the source, load reads it; name identifies the source in errors.
+func New[T any](name string, stat func() (string, error), load func() (*T, error)) (*Watcher[T], error) {
+	w := &Watcher[T]{name: name, stat: stat, load: load}
+	if _, err := w.Reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// FileSignature returns a stat function for a single file based on its
+// modification time and size.
+func FileSignature(path string) func() (string, error) {
+	return func() (string, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size()), nil
+	}
+}
+
+// Load returns the currently loaded value.
+func (w *Watcher[T]) Load() *T {
+	return w.value.Load()
+}
+
+// Reload loads the source again if its signature changed and reports
+// whether a new value was loaded. On error the previous value stays.
+func (w *Watcher[T]) Reload() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	signature, err := w.stat()
+	if err != nil {
+		return false, err
+	}
+	if w.value.Load() != nil && signature == w.signature {
+		return false, nil
+	}
+
+	value, err := w.load()
+	if err != nil {
+		return false, err
+	}
+	w.value.Store(value)
+	w.signature = signature
+	return true, nil
+}
+
+// Watch polls the source every interval until ctx is done. A broken
+// update keeps the previous value in use.
+func (w *Watcher[T]) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.Reload(); err != nil && onError != nil {
+				onError(fmt.Errorf("reload %s: %w", w.name, err))
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package filewatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loads := 0
+	load := func() (*string, error) {
+		loads++
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if string(data) == "broken" {
+			return nil, errors.New("broken data")
+		}
+		value := string(data)
+		return &value, nil
+	}
+
+	w, err := New("data", FileSignature(path), load)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if reloaded, err := w.Reload(); err != nil || reloaded || loads != 1 {
+		t.Errorf("expected no reload for unchanged file, got %v %v after %d loads", reloaded, err, loads)
+	}
+
+	os.WriteFile(path, []byte("newer"), 0o644)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if reloaded, err := w.Reload(); err != nil || !reloaded || *w.Load() != "newer" {
+		t.Fatalf("expected reload, got %v %v", reloaded, err)
+	}
+
+	os.WriteFile(path, []byte("broken"), 0o644)
+	os.Chtimes(path, future.Add(time.Minute), future.Add(time.Minute))
+	if _, err := w.Reload(); err == nil {
+		t.Error("expected error for broken data")
+	}
+	if *w.Load() != "newer" {
+		t.Errorf("expected previous value to stay loaded, got %s", *w.Load())
+	}
+
+	if _, err := New("missing", FileSignature(path+".missing"), load); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestWatcher_Watch(t *testing.T) {
+	version := "1"
+	w, err := New("data", func() (string, error) { return version, nil }, func() (*string, error) {
+		value := version
+		return &value, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w.mu.Lock()
+	version = "2"
+	w.mu.Unlock()
+	go w.Watch(ctx, time.Millisecond, func(err error) { errs <- err })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for *w.Load() != "2" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected Watch to pick up the change")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("unexpected error %v", err)
+	default:
+	}
+}
@@ -0,0 +1,292 @@
+package fingerprint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the fingerprint format accepted by Validate.
+const SchemaVersion = 1
+
+// Limits on submitted values keep stored records small.
+const (
+	maxStringLen    = 512
+	maxLanguages    = 32
+	maxFonts        = 256
+	maxCandidates   = 32
+	maxCapabilities = 64
+	maxExtensions   = 128
+	maxVoices       = 256
+	historySize     = 10
+)
+
+// Fingerprint is the browser data collected by the page.
+type Fingerprint struct {
+	Version             int               `json:"version"`
+	UserAgent           string            `json:"user_agent"`
+	Platform            string            `json:"platform,omitempty"`
+	Language            string            `json:"language,omitempty"`
+	Languages           []string          `json:"languages,omitempty"`
+	CookiesEnabled      bool              `json:"cookies_enabled"`
+	DoNotTrack          string            `json:"do_not_track,omitempty"`
+	HardwareConcurrency int               `json:"hardware_concurrency,omitempty"`
+	DeviceMemory        float64           `json:"device_memory,omitempty"`
+	TouchPoints         int               `json:"touch_points,omitempty"`
+	TimeZone            string            `json:"time_zone,omitempty"`
+	TimezoneOffset      int               `json:"timezone_offset"`
+	Screen              Screen            `json:"screen"`
+	Canvas              Canvas            `json:"canvas"`
+	WebRTC              WebRTC            `json:"webrtc"`
+	WebGLRenderer       string            `json:"webgl_renderer,omitempty"`
+	Fonts               []string          `json:"fonts,omitempty"`
+	Capabilities        map[string]string `json:"capabilities,omitempty"`
+	WebGL               *WebGL            `json:"webgl,omitempty"`
+	Audio               *Audio            `json:"audio,omitempty"`
+	Voices              []Voice           `json:"voices,omitempty"`
+	MediaDevices        map[string]int    `json:"media_devices,omitempty"`
+	Permissions         map[string]string `json:"permissions,omitempty"`
+}
+
+// Screen describes the display.
+type Screen struct {
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	AvailWidth  int     `json:"avail_width"`
+	AvailHeight int     `json:"avail_height"`
+	ColorDepth  int     `json:"color_depth"`
+	PixelRatio  float64 `json:"pixel_ratio"`
+}
+
+// Canvas is the result of the canvas rendering test.
+type Canvas struct {
+	Hash       string `json:"hash"`
+	DataLength int    `json:"data_length"`
+}
+
+// WebRTC lists the ICE candidate addresses seen by the page.
+type WebRTC struct {
+	Supported  bool     `json:"supported"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// WebGL describes the WebGL implementation and the hash of a rendered
+// scene.
+type WebGL struct {
+	Vendor          string            `json:"vendor,omitempty"`
+	Renderer        string            `json:"renderer,omitempty"`
+	Version         string            `json:"version,omitempty"`
+	ShadingLanguage string            `json:"shading_language,omitempty"`
+	Extensions      []string          `json:"extensions,omitempty"`
+	Parameters      map[string]string `json:"parameters,omitempty"`
+	ImageHash       string            `json:"image_hash,omitempty"`
+}
+
+// Audio is the result of the AudioContext rendering test.
+type Audio struct {
+	Hash string  `json:"hash"`
+	Sum  float64 `json:"sum"`
+}
+
+// Voice is a speech synthesis voice.
+type Voice struct {
+	Name    string `json:"name"`
+	Lang    string `json:"lang,omitempty"`
+	Local   bool   `json:"local"`
+	Default bool   `json:"default,omitempty"`
+}
+
+// Record is a stored fingerprint.
+type Record struct {
+	ID          string      `json:"id"`
+	SeenAt      time.Time   `json:"seen_at"`
+	Fingerprint Fingerprint `json:"fingerprint"`
+}
+
+// Validate checks the schema version and size limits.
+func (f Fingerprint) Validate() error {
+	if f.Version != SchemaVersion {
+		return fmt.Errorf("unsupported fingerprint version %d, expected %d", f.Version, SchemaVersion)
+	}
+	if f.UserAgent == "" {
+		return errors.New("user_agent is required")
+	}
+
+	strs := map[string]string{
+		"user_agent": f.UserAgent, "platform": f.Platform, "language": f.Language,
+		"do_not_track": f.DoNotTrack, "time_zone": f.TimeZone, "canvas.hash": f.Canvas.Hash,
+		"webgl_renderer": f.WebGLRenderer,
+	}
+	webgl := f.WebGL
+	if webgl == nil {
+		webgl = &WebGL{}
+	}
+	for name, value := range map[string]string{
+		"webgl.vendor": webgl.Vendor, "webgl.renderer": webgl.Renderer, "webgl.version": webgl.Version,
+		"webgl.shading_language": webgl.ShadingLanguage, "webgl.image_hash": webgl.ImageHash,
+	} {
+		strs[name] = value
+	}
+	if f.Audio != nil {
+		strs["audio.hash"] = f.Audio.Hash
+	}
+	for name, value := range strs {
+		if len(value) > maxStringLen {
+			return fmt.Errorf("%s is longer than %d bytes", name, maxStringLen)
+		}
+	}
+
+	lists := []struct {
+		name  string
+		items []string
+		max   int
+	}{
+		{"languages", f.Languages, maxLanguages},
+		{"fonts", f.Fonts, maxFonts},
+		{"webrtc.candidates", f.WebRTC.Candidates, maxCandidates},
+		{"webgl.extensions", webgl.Extensions, maxExtensions},
+	}
+	for _, list := range lists {
+		if len(list.items) > list.max {
+			return fmt.Errorf("%s has more than %d items", list.name, list.max)
+		}
+		for _, item := range list.items {
+			if len(item) > maxStringLen {
+				return fmt.Errorf("%s item is longer than %d bytes", list.name, maxStringLen)
+			}
+		}
+	}
+
+	maps := []struct {
+		name    string
+		entries map[string]string
+	}{
+		{"capabilities", f.Capabilities},
+		{"webgl.parameters", webgl.Parameters},
+		{"permissions", f.Permissions},
+	}
+	for _, m := range maps {
+		if len(m.entries) > maxCapabilities {
+			return fmt.Errorf("%s has more than %d entries", m.name, maxCapabilities)
+		}
+		for key, value := range m.entries {
+			if len(key) > maxStringLen || len(value) > maxStringLen {
+				return fmt.Errorf("%s entry %.32q is too long", m.name, key)
+			}
+		}
+	}
+
+	if len(f.Voices) > maxVoices {
+		return fmt.Errorf("voices has more than %d items", maxVoices)
+	}
+	for _, voice := range f.Voices {
+		if len(voice.Name) > maxStringLen || len(voice.Lang) > maxStringLen {
+			return errors.New("voices item is too long")
+		}
+	}
+
+	if len(f.MediaDevices) > maxCapabilities {
+		return fmt.Errorf("media_devices has more than %d entries", maxCapabilities)
+	}
+	for kind, count := range f.MediaDevices {
+		if len(kind) > maxStringLen {
+			return fmt.Errorf("media_devices kind %.32q is too long", kind)
+		}
+		if count < 0 {
+			return errors.New("numeric values must not be negative")
+		}
+	}
+
+	if f.Screen.Width < 0 || f.Screen.Height < 0 || f.HardwareConcurrency < 0 || f.TouchPoints < 0 {
+		return errors.New("numeric values must not be negative")
+	}
+	return nil
+}
+
+// ID identifies the browser across visits. ICE candidates are left out
+// because browsers randomize their mDNS host names per session, and media
+// devices and permissions because they change whenever a device is plugged
+// in or a prompt is answered.
+func (f Fingerprint) ID() string {
+	f.WebRTC.Candidates = nil
+	f.MediaDevices = nil
+	f.Permissions = nil
+	// Marshal sorts map keys, so the encoding is canonical.
+	data, _ := json.Marshal(f)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Store persists encoded records per scope, an IP address combined with
+// the browser that submitted them.
+type Store interface {
+	SaveFingerprint(ctx context.Context, scope, id string, data []byte, seenAt time.Time) (bool, error)
+	Fingerprints(ctx context.Context, scope string, limit int) ([][]byte, error)
+}
+
+// History stores and returns fingerprints submitted from an address by one
+// browser. The browser is identified by an owner secret it keeps, so other
+// clients behind the same address never see its fingerprints.
+type History struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewHistory creates a history backed by store.
+func NewHistory(store Store) *History {
+	return &History{store: store, now: time.Now}
+}
+
+// Save validates and stores fp for ip and owner and reports whether the
+// fingerprint is new for them. Resubmitting the same fingerprint updates
+// its time.
+func (h *History) Save(ctx context.Context, ip, owner string, fp Fingerprint) (Record, bool, error) {
+	if owner == "" {
+		return Record{}, false, errors.New("fingerprint owner is required")
+	}
+	if err := fp.Validate(); err != nil {
+		return Record{}, false, err
+	}
+	record := Record{ID: fp.ID(), SeenAt: h.now().UTC(), Fingerprint: fp}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("encode fingerprint: %w", err)
+	}
+	created, err := h.store.SaveFingerprint(ctx, scope(ip, owner), record.ID, data, record.SeenAt)
+	if err != nil {
+		return Record{}, false, err
+	}
+	return record, created, nil
+}
+
+// Recent returns the latest fingerprints of ip and owner, newest first.
+// Without an owner there is nothing to return.
+func (h *History) Recent(ctx context.Context, ip, owner string) ([]Record, error) {
+	if owner == "" {
+		return nil, nil
+	}
+	items, err := h.store.Fingerprints(ctx, scope(ip, owner), historySize)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(items))
+	for _, data := range items {
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// scope keys records by address and a hash of the owner secret, so the
+// secret itself never reaches the store.
+func scope(ip, owner string) string {
+	sum := sha256.Sum256([]byte(owner))
+	return ip + ":" + hex.EncodeToString(sum[:8])
+}
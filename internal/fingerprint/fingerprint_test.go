@@ -0,0 +1,154 @@
+package fingerprint
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	data map[string][]byte
+	seen map[string]time.Time
+}
+
+func (m *memoryStore) SaveFingerprint(ctx context.Context, ip, id string, data []byte, seenAt time.Time) (bool, error) {
+	_, exists := m.data[ip+":"+id]
+	m.data[ip+":"+id] = data
+	m.seen[ip+":"+id] = seenAt
+	return !exists, nil
+}
+
+func (m *memoryStore) Fingerprints(ctx context.Context, ip string, limit int) ([][]byte, error) {
+	var keys []string
+	for key := range m.data {
+		if strings.HasPrefix(key, ip+":") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return m.seen[keys[i]].After(m.seen[keys[j]]) })
+	var items [][]byte
+	for _, key := range keys[:min(limit, len(keys))] {
+		items = append(items, m.data[key])
+	}
+	return items, nil
+}
+
+func sample() Fingerprint {
+	return Fingerprint{
+		Version:   SchemaVersion,
+		UserAgent: "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0",
+		Languages: []string{"en-US", "en"},
+		TimeZone:  "Europe/Berlin",
+		Screen:    Screen{Width: 1920, Height: 1080, ColorDepth: 24, PixelRatio: 1},
+		Canvas:    Canvas{Hash: "abc123", DataLength: 4000},
+		WebRTC:    WebRTC{Supported: true, Candidates: []string{"1f2e.local"}},
+		Fonts:     []string{"Arial", "Georgia"},
+	}
+}
+
+func TestFingerprint_Validate(t *testing.T) {
+	if err := sample().Validate(); err != nil {
+		t.Fatalf("expected valid fingerprint, got %v", err)
+	}
+
+	tests := map[string]func(*Fingerprint){
+		"version":      func(f *Fingerprint) { f.Version = 2 },
+		"user agent":   func(f *Fingerprint) { f.UserAgent = "" },
+		"long string":  func(f *Fingerprint) { f.Platform = strings.Repeat("x", maxStringLen+1) },
+		"many fonts":   func(f *Fingerprint) { f.Fonts = make([]string, maxFonts+1) },
+		"long font":    func(f *Fingerprint) { f.Fonts = []string{strings.Repeat("x", maxStringLen+1)} },
+		"capabilities": func(f *Fingerprint) { f.Capabilities = map[string]string{"k": strings.Repeat("x", maxStringLen+1)} },
+		"negative":     func(f *Fingerprint) { f.Screen.Width = -1 },
+		"extensions":   func(f *Fingerprint) { f.WebGL = &WebGL{Extensions: make([]string, maxExtensions+1)} },
+		"webgl param": func(f *Fingerprint) {
+			f.WebGL = &WebGL{Parameters: map[string]string{"k": strings.Repeat("x", maxStringLen+1)}}
+		},
+		"audio hash":  func(f *Fingerprint) { f.Audio = &Audio{Hash: strings.Repeat("x", maxStringLen+1)} },
+		"many voices": func(f *Fingerprint) { f.Voices = make([]Voice, maxVoices+1) },
+		"devices":     func(f *Fingerprint) { f.MediaDevices = map[string]int{"videoinput": -1} },
+		"permissions": func(f *Fingerprint) {
+			f.Permissions = map[string]string{strings.Repeat("x", maxStringLen+1): "granted"}
+		},
+	}
+	for name, mutate := range tests {
+		fp := sample()
+		mutate(&fp)
+		if err := fp.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestFingerprint_ID(t *testing.T) {
+	a, b := sample(), sample()
+	b.WebRTC.Candidates = []string{"9a8b.local"}
+	if a.ID() != b.ID() {
+		t.Error("expected ID to ignore ICE candidates")
+	}
+	b.MediaDevices = map[string]int{"videoinput": 1}
+	b.Permissions = map[string]string{"camera": "granted"}
+	if a.ID() != b.ID() {
+		t.Error("expected ID to ignore media devices and permissions")
+	}
+	b.Canvas.Hash = "def456"
+	if a.ID() == b.ID() {
+		t.Error("expected ID to change with the canvas hash")
+	}
+	b = sample()
+	b.WebGL = &WebGL{ImageHash: "0f0f"}
+	if a.ID() == b.ID() {
+		t.Error("expected ID to change with the WebGL image hash")
+	}
+	if len(a.ID()) != 16 {
+		t.Errorf("expected 16 hex characters, got %q", a.ID())
+	}
+}
+
+func TestHistory_SaveRecent(t *testing.T) {
+	store := &memoryStore{data: map[string][]byte{}, seen: map[string]time.Time{}}
+	history := NewHistory(store)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	history.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	first, created, err := history.Save(ctx, "203.0.113.1", "browser", sample())
+	if err != nil || !created {
+		t.Fatalf("Save failed: %v, created %v", err, created)
+	}
+	now = now.Add(time.Hour)
+	other := sample()
+	other.UserAgent = "curl/8.0"
+	second, _, err := history.Save(ctx, "203.0.113.1", "browser", other)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, created, _ := history.Save(ctx, "203.0.113.1", "browser", other); created {
+		t.Error("expected resubmitted fingerprint not to be reported as new")
+	}
+
+	records, err := history.Recent(ctx, "203.0.113.1", "browser")
+	if err != nil {
+		t.Fatalf("Recent failed: %v", err)
+	}
+	if len(records) != 2 || records[0].ID != second.ID || records[1].ID != first.ID {
+		t.Fatalf("expected newest first, got %+v", records)
+	}
+	if records[1].Fingerprint.Canvas.Hash != "abc123" {
+		t.Errorf("expected stored fingerprint data, got %+v", records[1].Fingerprint)
+	}
+
+	if _, _, err := history.Save(ctx, "203.0.113.1", "browser", Fingerprint{Version: 99}); err == nil {
+		t.Error("expected invalid fingerprint to be rejected")
+	}
+
+	for _, owner := range []string{"neighbour", ""} {
+		if records, _ := history.Recent(ctx, "203.0.113.1", owner); len(records) != 0 {
+			t.Errorf("expected no records for owner %q behind the same address, got %+v", owner, records)
+		}
+	}
+	if _, _, err := history.Save(ctx, "203.0.113.1", "", sample()); err == nil {
+		t.Error("expected a fingerprint without owner to be rejected")
+	}
+}
@@ -0,0 +1,158 @@
+package fingerprint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultStatsLimit is the number of distinct values kept per attribute.
+const DefaultStatsLimit = 10000
+
+// combinedAttribute counts whole fingerprints by ID.
+const combinedAttribute = "fingerprint"
+
+// StatAttributes are the attributes whose value frequencies are tracked.
+var StatAttributes = []string{"canvas", "user_agent", "screen", "time_zone", "fonts", "webgl_renderer", "webgl", "audio", combinedAttribute}
+
+// StatsStore keeps anonymous value counters per attribute. Besides the
+// count of each value it maintains the total number of observations and,
+// per attribute, the sum of c*log2(c) over all value counts c, which is
+// enough to derive the Shannon entropy without reading every counter.
+type StatsStore interface {
+	AddFingerprintStats(ctx context.Context, values map[string]string, limit int) (int64, map[string]int64, map[string]float64, error)
+	FingerprintStats(ctx context.Context, values map[string]string) (int64, map[string]int64, map[string]float64, error)
+	ResetFingerprintStats(ctx context.Context, attributes []string) error
+}
+
+// AttributeStats describes how common one attribute value is.
+type AttributeStats struct {
+	Name string `json:"name"`
+	// Count is the number of fingerprints sharing the value.
+	Count int64   `json:"count"`
+	Share float64 `json:"share"`
+	OneIn float64 `json:"one_in"`
+	// Bits is the identifying information of the value, log2(OneIn).
+	Bits float64 `json:"bits"`
+	// Entropy is the Shannon entropy of the attribute across all visitors.
+	Entropy float64 `json:"entropy"`
+}
+
+// Summary is the uniqueness report for a fingerprint.
+type Summary struct {
+	Total      int64            `json:"total"`
+	Attributes []AttributeStats `json:"attributes"`
+	Combined   AttributeStats   `json:"combined"`
+}
+
+// Stats aggregates attribute value frequencies of submitted fingerprints.
+// Values are hashed before they are stored, and each attribute keeps at
+// most limit distinct values; the rarest ones are evicted first.
+type Stats struct {
+	store StatsStore
+	limit int
+}
+
+// NewStats creates an aggregate backed by store.
+func NewStats(store StatsStore, limit int) *Stats {
+	if limit <= 0 {
+		limit = DefaultStatsLimit
+	}
+	return &Stats{store: store, limit: limit}
+}
+
+// Add counts fp and returns its summary.
+func (s *Stats) Add(ctx context.Context, fp Fingerprint) (Summary, error) {
+	total, counts, sums, err := s.store.AddFingerprintStats(ctx, statValues(fp), s.limit)
+	if err != nil {
+		return Summary{}, err
+	}
+	return summarize(total, counts, sums), nil
+}
+
+// Lookup returns the summary of fp without counting it.
+func (s *Stats) Lookup(ctx context.Context, fp Fingerprint) (Summary, error) {
+	total, counts, sums, err := s.store.FingerprintStats(ctx, statValues(fp))
+	if err != nil {
+		return Summary{}, err
+	}
+	return summarize(total, counts, sums), nil
+}
+
+// Reset clears all counters.
+func (s *Stats) Reset(ctx context.Context) error {
+	if err := s.store.ResetFingerprintStats(ctx, StatAttributes); err != nil {
+		return fmt.Errorf("reset fingerprint stats: %w", err)
+	}
+	return nil
+}
+
+// statValues returns the hashed value of every tracked attribute.
+func statValues(fp Fingerprint) map[string]string {
+	fonts := append([]string(nil), fp.Fonts...)
+	sort.Strings(fonts)
+	timeZone := fp.TimeZone
+	if timeZone == "" {
+		timeZone = fmt.Sprintf("UTC%+d", -fp.TimezoneOffset)
+	}
+
+	var webglHash, audioHash string
+	if fp.WebGL != nil {
+		webglHash = fp.WebGL.ImageHash
+	}
+	if fp.Audio != nil {
+		audioHash = fp.Audio.Hash
+	}
+
+	raw := map[string]string{
+		"canvas":          fp.Canvas.Hash,
+		"user_agent":      fp.UserAgent,
+		"screen":          fmt.Sprintf("%dx%dx%d@%g", fp.Screen.Width, fp.Screen.Height, fp.Screen.ColorDepth, fp.Screen.PixelRatio),
+		"time_zone":       timeZone,
+		"fonts":           strings.Join(fonts, ","),
+		"webgl_renderer":  fp.WebGLRenderer,
+		"webgl":           webglHash,
+		"audio":           audioHash,
+		combinedAttribute: fp.ID(),
+	}
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		sum := sha256.Sum256([]byte(name + "\x00" + value))
+		values[name] = hex.EncodeToString(sum[:8])
+	}
+	return values
+}
+
+func summarize(total int64, counts map[string]int64, sums map[string]float64) Summary {
+	summary := Summary{Total: total}
+	for _, name := range StatAttributes {
+		stats := attributeStats(name, total, counts[name], sums[name])
+		if name == combinedAttribute {
+			summary.Combined = stats
+			continue
+		}
+		summary.Attributes = append(summary.Attributes, stats)
+	}
+	return summary
+}
+
+// attributeStats derives frequencies from the counters. A value evicted
+// from the store is reported as unique. The entropy is
+// log2(N) - sum(c*log2(c))/N, where evicted values count as seen once.
+func attributeStats(name string, total, count int64, sum float64) AttributeStats {
+	stats := AttributeStats{Name: name, Count: count}
+	if total <= 0 {
+		return stats
+	}
+	count = min(max(count, 1), total)
+	n := float64(total)
+	stats.Share = float64(count) / n
+	stats.OneIn = n / float64(count)
+	stats.Bits = math.Log2(stats.OneIn)
+	stats.Entropy = max(0, math.Log2(n)-sum/n)
+	return stats
+}
@@ -0,0 +1,106 @@
+package fingerprint
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+type memoryStats struct {
+	total  int64
+	counts map[string]map[string]int64
+}
+
+func (m *memoryStats) AddFingerprintStats(ctx context.Context, values map[string]string, limit int) (int64, map[string]int64, map[string]float64, error) {
+	m.total++
+	for attr, value := range values {
+		if m.counts[attr] == nil {
+			m.counts[attr] = map[string]int64{}
+		}
+		m.counts[attr][value]++
+	}
+	return m.FingerprintStats(ctx, values)
+}
+
+func (m *memoryStats) FingerprintStats(ctx context.Context, values map[string]string) (int64, map[string]int64, map[string]float64, error) {
+	counts := map[string]int64{}
+	sums := map[string]float64{}
+	for attr, value := range values {
+		counts[attr] = m.counts[attr][value]
+		for _, c := range m.counts[attr] {
+			sums[attr] += float64(c) * math.Log2(float64(c))
+		}
+	}
+	return m.total, counts, sums, nil
+}
+
+func (m *memoryStats) ResetFingerprintStats(ctx context.Context, attributes []string) error {
+	m.total = 0
+	m.counts = map[string]map[string]int64{}
+	return nil
+}
+
+func TestStats_Add(t *testing.T) {
+	store := &memoryStats{counts: map[string]map[string]int64{}}
+	stats := NewStats(store, 0)
+	ctx := context.Background()
+
+	// Two identical browsers and two that differ only by time zone.
+	for _, tz := range []string{"Europe/Berlin", "Europe/Berlin", "Asia/Tokyo", "America/Lima"} {
+		fp := sample()
+		fp.TimeZone = tz
+		if _, err := stats.Add(ctx, fp); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	summary, err := stats.Lookup(ctx, sample())
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if summary.Total != 4 || len(summary.Attributes) != len(StatAttributes)-1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	byName := map[string]AttributeStats{}
+	for _, attr := range summary.Attributes {
+		byName[attr.Name] = attr
+	}
+	tz := byName["time_zone"]
+	if tz.Count != 2 || tz.Share != 0.5 || tz.OneIn != 2 || tz.Bits != 1 {
+		t.Errorf("unexpected time zone stats %+v", tz)
+	}
+	// p = 1/2, 1/4, 1/4
+	if math.Abs(tz.Entropy-1.5) > 1e-9 {
+		t.Errorf("expected entropy 1.5 bits, got %v", tz.Entropy)
+	}
+	if canvas := byName["canvas"]; canvas.Count != 4 || canvas.Bits != 0 || canvas.Entropy != 0 {
+		t.Errorf("expected shared canvas hash to carry no information, got %+v", canvas)
+	}
+	if summary.Combined.Count != 2 || summary.Combined.Name != "fingerprint" {
+		t.Errorf("unexpected combined stats %+v", summary.Combined)
+	}
+
+	if err := stats.Reset(ctx); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if summary, _ := stats.Lookup(ctx, sample()); summary.Total != 0 || summary.Combined.OneIn != 0 {
+		t.Errorf("expected empty stats after reset, got %+v", summary)
+	}
+}
+
+func TestStatValues(t *testing.T) {
+	a, b := sample(), sample()
+	b.Fonts = []string{"Georgia", "Arial"}
+	if statValues(a)["fonts"] != statValues(b)["fonts"] {
+		t.Error("expected font order not to matter")
+	}
+	for name, value := range statValues(a) {
+		if len(value) != 16 {
+			t.Errorf("%s: expected hashed value, got %q", name, value)
+		}
+	}
+	if statValues(a)["canvas"] == a.Canvas.Hash {
+		t.Error("expected raw values not to be stored")
+	}
+}
@@ -0,0 +1,406 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChunkSize  = 1420
+	defaultBufferSize = 1000
+	dialTimeout       = 5 * time.Second
+	closeTimeout      = 5 * time.Second
+	maxBackoff        = 30 * time.Second
+	maxChunks         = 128
+	chunkHeaderSize   = 12
+)
+
+// Syslog severity levels used by GELF.
+const (
+	LevelError   = 3
+	LevelWarning = 4
+	LevelInfo    = 6
+	LevelDebug   = 7
+)
+
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// Config describes a GELF destination.
+type Config struct {
+	// Addr is host:port, optionally prefixed with udp://, tcp:// or tls://.
+	Addr string
+	// Compression applies to UDP only: gzip (default), zlib or none.
+	Compression string
+	// ChunkSize is the maximum UDP datagram size.
+	ChunkSize int
+	// BufferSize bounds the number of queued messages for TCP and TLS.
+	BufferSize int
+	// Fields are static extra fields added to every message.
+	Fields map[string]string
+	// TLSConfig is used for tls:// addresses.
+	TLSConfig *tls.Config
+}
+
+// Writer sends each written line as a GELF message.
+type Writer struct {
+	cfg       Config
+	transport string
+	addr      string
+	host      string
+
+	udp net.Conn
+
+	queue chan []byte
+	done  chan struct{}
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	drops int
+}
+
+// New creates a writer for the configured transport.
+func New(cfg Config) (*Writer, error) {
+	transport, addr := splitAddr(cfg.Addr)
+	if addr == "" {
+		return nil, fmt.Errorf("gelf address is empty")
+	}
+	if cfg.ChunkSize <= chunkHeaderSize {
+		cfg.ChunkSize = defaultChunkSize
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultBufferSize
+	}
+	switch cfg.Compression {
+	case "":
+		cfg.Compression = "gzip"
+	case "gzip", "zlib", "none":
+	default:
+		return nil, fmt.Errorf("unknown gelf compression %q", cfg.Compression)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+
+	w := &Writer{cfg: cfg, transport: transport, addr: addr, host: host}
+
+	switch transport {
+	case "udp":
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("dial gelf udp: %w", err)
+		}
+		w.udp = conn
+	case "tcp", "tls":
+		w.queue = make(chan []byte, cfg.BufferSize)
+		w.done = make(chan struct{})
+		w.wg.Add(1)
+		go w.run()
+	default:
+		return nil, fmt.Errorf("unknown gelf transport %q", transport)
+	}
+
+	return w, nil
+}
+
+// Write encodes p as a GELF message and sends or queues it.
+func (w *Writer) Write(p []byte) (int, error) {
+	payload, err := w.encode(p, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if w.udp != nil {
+		if err := w.sendUDP(payload); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	w.enqueue(payload)
+	return len(p), nil
+}
+
+// Dropped returns the number of messages discarded because the buffer was full.
+func (w *Writer) Dropped() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.drops
+}
+
+// Close flushes queued messages where possible and releases the
+// connection. It gives up after closeTimeout so that an unreachable
+// Graylog cannot block shutdown.
+func (w *Writer) Close() error {
+	if w.udp != nil {
+		return w.udp.Close()
+	}
+	close(w.done)
+	flushed := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		return nil
+	case <-time.After(closeTimeout):
+		return fmt.Errorf("flush gelf queue: timed out after %v", closeTimeout)
+	}
+}
+
+type message map[string]any
+
+func (w *Writer) encode(p []byte, now time.Time) ([]byte, error) {
+	text := strings.TrimRight(string(p), "\n")
+	short, full, _ := strings.Cut(text, "\n")
+
+	msg := message{
+		"version":       "1.1",
+		"host":          w.host,
+		"short_message": short,
+		"timestamp":     float64(now.UnixNano()) / float64(time.Second),
+		"level":         levelOf(short),
+	}
+	if full != "" {
+		msg["full_message"] = text
+	}
+	for key, value := range w.cfg.Fields {
+		// GELF reserves _id, so it is dropped however it is spelled.
+		key = strings.TrimPrefix(key, "_")
+		if key == "id" {
+			continue
+		}
+		msg["_"+key] = value
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("encode gelf message: %w", err)
+	}
+	return payload, nil
+}
+
+// levelOf maps the leading "xxx error:" style prefix used across the
+// service to a syslog severity.
+func levelOf(line string) int {
+	prefix, _, ok := strings.Cut(line, ":")
+	if !ok {
+		return LevelInfo
+	}
+	prefix = strings.ToLower(prefix)
+	switch {
+	case strings.Contains(prefix, "error"), strings.Contains(prefix, "fatal"):
+		return LevelError
+	case strings.Contains(prefix, "warn"):
+		return LevelWarning
+	case strings.Contains(prefix, "debug"):
+		return LevelDebug
+	default:
+		return LevelInfo
+	}
+}
+
+func (w *Writer) sendUDP(payload []byte) error {
+	data, err := compress(payload, w.cfg.Compression)
+	if err != nil {
+		return err
+	}
+
+	if len(data) <= w.cfg.ChunkSize {
+		if _, err := w.udp.Write(data); err != nil {
+			return fmt.Errorf("send gelf udp: %w", err)
+		}
+		return nil
+	}
+
+	chunks, err := chunk(data, w.cfg.ChunkSize)
+	if err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if _, err := w.udp.Write(c); err != nil {
+			return fmt.Errorf("send gelf chunk: %w", err)
+		}
+	}
+	return nil
+}
+
+func compress(payload []byte, method string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch method {
+	case "none":
+		return payload, nil
+	case "zlib":
+		zw := zlib.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			return nil, fmt.Errorf("zlib compress: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("zlib compress: %w", err)
+		}
+	default:
+		gw := gzip.NewWriter(&buf)
+		if _, err := gw.Write(payload); err != nil {
+			return nil, fmt.Errorf("gzip compress: %w", err)
+		}
+		if err := gw.Close(); err != nil {
+			return nil, fmt.Errorf("gzip compress: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func chunk(data []byte, size int) ([][]byte, error) {
+	body := size - chunkHeaderSize
+	count := (len(data) + body - 1) / body
+	if count > maxChunks {
+		return nil, fmt.Errorf("gelf message too large: %d chunks", count)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generate chunk id: %w", err)
+	}
+
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * body
+		if end > len(data) {
+			end = len(data)
+		}
+		c := make([]byte, 0, chunkHeaderSize+end-i*body)
+		c = append(c, chunkMagic...)
+		c = append(c, id...)
+		c = append(c, byte(i), byte(count))
+		c = append(c, data[i*body:end]...)
+		chunks = append(chunks, c)
+	}
+	return chunks, nil
+}
+
+// enqueue adds a message to the bounded buffer, dropping the oldest entry
+// when Graylog is unreachable for long enough to fill it.
+func (w *Writer) enqueue(payload []byte) {
+	for {
+		select {
+		case w.queue <- payload:
+			return
+		default:
+		}
+		select {
+		case <-w.queue:
+			w.mu.Lock()
+			w.drops++
+			w.mu.Unlock()
+		default:
+		}
+	}
+}
+
+func (w *Writer) run() {
+	defer w.wg.Done()
+
+	var conn net.Conn
+	var pending []byte
+	backoff := time.Second
+
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		if pending == nil {
+			select {
+			case pending = <-w.queue:
+			case <-w.done:
+				conn = w.flush(conn, nil)
+				return
+			}
+		}
+
+		if conn == nil {
+			c, err := w.dial()
+			if err != nil {
+				select {
+				case <-time.After(backoff):
+				case <-w.done:
+					conn = w.flush(nil, pending)
+					return
+				}
+				backoff = min(backoff*2, maxBackoff)
+				continue
+			}
+			conn = c
+			backoff = time.Second
+		}
+
+		if _, err := conn.Write(append(pending, 0)); err != nil {
+			conn.Close()
+			conn = nil
+			continue
+		}
+		pending = nil
+	}
+}
+
+// flush writes pending and whatever is still queued on shutdown, dialing
+// once more when there is no connection, and returns the connection used.
+func (w *Writer) flush(conn net.Conn, pending []byte) net.Conn {
+	if conn == nil {
+		c, err := w.dial()
+		if err != nil {
+			return nil
+		}
+		conn = c
+	}
+	conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	if pending != nil {
+		if _, err := conn.Write(append(pending, 0)); err != nil {
+			return conn
+		}
+	}
+	for {
+		select {
+		case payload := <-w.queue:
+			if _, err := conn.Write(append(payload, 0)); err != nil {
+				return conn
+			}
+		default:
+			return conn
+		}
+	}
+}
+
+func (w *Writer) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if w.transport == "tls" {
+		cfg := w.cfg.TLSConfig
+		if cfg == nil {
+			host, _, _ := net.SplitHostPort(w.addr)
+			cfg = &tls.Config{ServerName: host}
+		}
+		return tls.DialWithDialer(dialer, "tcp", w.addr, cfg)
+	}
+	return dialer.Dial("tcp", w.addr)
+}
+
+func splitAddr(addr string) (string, string) {
+	addr = strings.TrimSpace(addr)
+	if scheme, rest, ok := strings.Cut(addr, "://"); ok {
+		return strings.ToLower(scheme), rest
+	}
+	return "udp", addr
+}
@@ -0,0 +1,217 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w, err := New(Config{
+		Addr:        conn.LocalAddr().String(),
+		Compression: "none",
+		Fields:      map[string]string{"environment": "test", "_id": "x", "id": "y"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("redis error: connection refused\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	buf := make([]byte, 8192)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg map[string]any
+	if err := json.Unmarshal(buf[:n], &msg); err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	if msg["short_message"] != "redis error: connection refused" {
+		t.Errorf("unexpected short_message %v", msg["short_message"])
+	}
+	if msg["level"] != float64(LevelError) {
+		t.Errorf("expected level %d, got %v", LevelError, msg["level"])
+	}
+	if msg["_environment"] != "test" {
+		t.Errorf("expected _environment test, got %v", msg["_environment"])
+	}
+	if _, ok := msg["_id"]; ok {
+		t.Errorf("expected reserved _id to be dropped, got %v", msg["_id"])
+	}
+}
+
+func TestWriter_UDPChunked(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w, err := New(Config{Addr: "udp://" + conn.LocalAddr().String(), Compression: "gzip", ChunkSize: 64})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer w.Close()
+
+	line := bytes.Repeat([]byte("0123456789abcdef"), 64)
+	if _, err := w.Write(line); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	parts := map[byte][]byte{}
+	var total byte
+	buf := make([]byte, 128)
+	for total == 0 || len(parts) < int(total) {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read chunk: %v", err)
+		}
+		if n > 64 || !bytes.Equal(buf[:2], chunkMagic) {
+			t.Fatalf("invalid chunk of %d bytes", n)
+		}
+		total = buf[11]
+		parts[buf[10]] = append([]byte(nil), buf[chunkHeaderSize:n]...)
+	}
+
+	var data []byte
+	for i := byte(0); i < total; i++ {
+		data = append(data, parts[i]...)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg map[string]any
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	if msg["short_message"] != string(line) {
+		t.Errorf("reassembled message mismatch")
+	}
+}
+
+func TestWriter_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w, err := New(Config{Addr: "tcp://" + ln.Addr().String()})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("listening on :85\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	frame, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+
+	var msg map[string]any
+	if err := json.Unmarshal(frame[:len(frame)-1], &msg); err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	if msg["short_message"] != "listening on :85" {
+		t.Errorf("unexpected short_message %v", msg["short_message"])
+	}
+	if msg["level"] != float64(LevelInfo) {
+		t.Errorf("expected level %d, got %v", LevelInfo, msg["level"])
+	}
+}
+
+func TestWriter_CloseFlushes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w, err := New(Config{Addr: "tcp://" + ln.Addr().String()})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	w.Write([]byte("fatal error: boom\n"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frame, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil {
+		t.Fatalf("expected the message queued before Close to be sent: %v", err)
+	}
+	if !strings.Contains(string(frame), "fatal error: boom") {
+		t.Errorf("unexpected frame %s", frame)
+	}
+}
+
+func TestWriter_BufferBounded(t *testing.T) {
+	w := &Writer{queue: make(chan []byte, 2)}
+	w.enqueue([]byte("1"))
+	w.enqueue([]byte("2"))
+	w.enqueue([]byte("3"))
+
+	if w.Dropped() != 1 {
+		t.Errorf("expected 1 dropped message, got %d", w.Dropped())
+	}
+	if got := string(<-w.queue); got != "2" {
+		t.Errorf("expected oldest message dropped, got %s first", got)
+	}
+}
+
+func TestLevelOf(t *testing.T) {
+	tests := map[string]int{
+		"error: boom":             LevelError,
+		"server error: x":         LevelError,
+		"warning: slow":           LevelWarning,
+		"listening on :85":        LevelInfo,
+		"debug: cache hit":        LevelDebug,
+		"no colon error anywhere": LevelInfo,
+	}
+	for line, expected := range tests {
+		if got := levelOf(line); got != expected {
+			t.Errorf("levelOf(%q) = %d, want %d", line, got, expected)
+		}
+	}
+}
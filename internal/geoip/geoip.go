@@ -0,0 +1,59 @@
+package geoip
+
+import (
+	"net/netip"
+
+	"myip/internal/mmdb"
+)
+
+// Location is the geolocation of an address from a City database.
+type Location struct {
+	CountryCode    string  `json:"country_code,omitempty"`
+	Country        string  `json:"country,omitempty"`
+	Region         string  `json:"region,omitempty"`
+	City           string  `json:"city,omitempty"`
+	Latitude       float64 `json:"latitude,omitempty"`
+	Longitude      float64 `json:"longitude,omitempty"`
+	TimeZone       string  `json:"time_zone,omitempty"`
+	AccuracyRadius uint64  `json:"accuracy_radius_km,omitempty"`
+}
+
+// DB looks up locations in a MaxMind GeoIP2/GeoLite2 or DB-IP City MMDB.
+type DB struct {
+	file *mmdb.File
+}
+
+// New wraps an opened database file.
+func New(file *mmdb.File) *DB {
+	return &DB{file: file}
+}
+
+// Lookup returns the location of ip. ok is false for unknown addresses.
+func (d *DB) Lookup(ip string) (Location, bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Location{}, false, nil
+	}
+
+	record, _, ok, err := d.file.Reader().Lookup(addr)
+	if err != nil || !ok {
+		return Location{}, false, err
+	}
+
+	loc := Location{
+		CountryCode:    mmdb.String(record, "country", "iso_code"),
+		Country:        mmdb.String(record, "country", "names", "en"),
+		Region:         mmdb.String(record, "subdivisions", 0, "names", "en"),
+		City:           mmdb.String(record, "city", "names", "en"),
+		Latitude:       mmdb.Float(record, "location", "latitude"),
+		Longitude:      mmdb.Float(record, "location", "longitude"),
+		TimeZone:       mmdb.String(record, "location", "time_zone"),
+		AccuracyRadius: mmdb.Uint(record, "location", "accuracy_radius"),
+	}
+	if loc.CountryCode == "" {
+		// Anycast and satellite ranges often only carry the registered country.
+		loc.CountryCode = mmdb.String(record, "registered_country", "iso_code")
+		loc.Country = mmdb.String(record, "registered_country", "names", "en")
+	}
+	return loc, true, nil
+}
@@ -0,0 +1,66 @@
+package geoip
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"myip/internal/mmdb"
+	"myip/internal/mmdb/mmdbtest"
+)
+
+func writeDB(t *testing.T, entries []mmdbtest.Entry) string {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := mmdbtest.Write(f, "GeoLite2-City", entries); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDB_Lookup(t *testing.T) {
+	path := writeDB(t, []mmdbtest.Entry{
+		{Prefix: netip.MustParsePrefix("81.2.69.0/24"), Value: map[string]any{
+			"country":      map[string]any{"iso_code": "GB", "names": map[string]any{"en": "United Kingdom"}},
+			"subdivisions": []any{map[string]any{"names": map[string]any{"en": "England"}}},
+			"city":         map[string]any{"names": map[string]any{"en": "London"}},
+			"location": map[string]any{
+				"latitude": 51.5142, "longitude": -0.0931, "time_zone": "Europe/London", "accuracy_radius": uint16(10),
+			},
+		}},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), Value: map[string]any{
+			"registered_country": map[string]any{"iso_code": "DE", "names": map[string]any{"en": "Germany"}},
+		}},
+	})
+	file, err := mmdb.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	db := New(file)
+
+	loc, ok, err := db.Lookup("81.2.69.160")
+	if err != nil || !ok {
+		t.Fatalf("Lookup failed: ok=%v err=%v", ok, err)
+	}
+	want := Location{
+		CountryCode: "GB", Country: "United Kingdom", Region: "England", City: "London",
+		Latitude: 51.5142, Longitude: -0.0931, TimeZone: "Europe/London", AccuracyRadius: 10,
+	}
+	if loc != want {
+		t.Errorf("got %+v, want %+v", loc, want)
+	}
+
+	loc, ok, _ = db.Lookup("2001:db8::1")
+	if !ok || loc.CountryCode != "DE" {
+		t.Errorf("expected registered country fallback, got %+v", loc)
+	}
+
+	if _, ok, _ := db.Lookup("192.0.2.1"); ok {
+		t.Error("expected no match for unknown address")
+	}
+}
@@ -0,0 +1,161 @@
+package hosting
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"myip/internal/netcalc"
+)
+
+type awsRanges struct {
+	Prefixes []struct {
+		IPPrefix string `json:"ip_prefix"`
+		Region   string `json:"region"`
+		Service  string `json:"service"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		IPv6Prefix string `json:"ipv6_prefix"`
+		Region     string `json:"region"`
+		Service    string `json:"service"`
+	} `json:"ipv6_prefixes"`
+}
+
+type gcpRanges struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+		Service    string `json:"service"`
+		Scope      string `json:"scope"`
+	} `json:"prefixes"`
+}
+
+type azureRanges struct {
+	Values []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			SystemService   string   `json:"systemService"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+func loadFile(path string) ([]Range, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseJSON(data)
+	}
+
+	provider := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return parseList(data, provider)
+}
+
+// parseJSON detects the provider format by its distinctive fields.
+func parseJSON(data []byte) ([]Range, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+
+	var ranges []Range
+	add := func(raw, provider, service, region string) error {
+		prefix, err := netcalc.ParsePrefix(raw)
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, Range{Prefix: prefix, Provider: provider, Service: service, Region: region})
+		return nil
+	}
+
+	switch {
+	case probe["values"] != nil:
+		var azure azureRanges
+		if err := json.Unmarshal(data, &azure); err != nil {
+			return nil, fmt.Errorf("decode azure ranges: %w", err)
+		}
+		for _, value := range azure.Values {
+			service := value.Properties.SystemService
+			if service == "" {
+				service = value.Name
+			}
+			for _, raw := range value.Properties.AddressPrefixes {
+				if err := add(raw, "Azure", service, value.Properties.Region); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case probe["syncToken"] != nil && probe["createDate"] != nil:
+		var aws awsRanges
+		if err := json.Unmarshal(data, &aws); err != nil {
+			return nil, fmt.Errorf("decode aws ranges: %w", err)
+		}
+		for _, p := range aws.Prefixes {
+			if err := add(p.IPPrefix, "AWS", p.Service, p.Region); err != nil {
+				return nil, err
+			}
+		}
+		for _, p := range aws.IPv6Prefixes {
+			if err := add(p.IPv6Prefix, "AWS", p.Service, p.Region); err != nil {
+				return nil, err
+			}
+		}
+	case probe["prefixes"] != nil:
+		var gcp gcpRanges
+		if err := json.Unmarshal(data, &gcp); err != nil {
+			return nil, fmt.Errorf("decode gcp ranges: %w", err)
+		}
+		for _, p := range gcp.Prefixes {
+			raw := p.IPv4Prefix
+			if raw == "" {
+				raw = p.IPv6Prefix
+			}
+			if err := add(raw, "Google Cloud", p.Service, p.Scope); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.New("unknown range file format")
+	}
+	return ranges, nil
+}
+
+// parseList reads one CIDR or address per line, optionally followed by a
+// service and a region. Empty lines and # comments are skipped.
+func parseList(data []byte, provider string) ([]Range, error) {
+	var ranges []Range
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		prefix, err := netcalc.ParsePrefix(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rng := Range{Prefix: prefix, Provider: provider}
+		if len(fields) > 1 {
+			rng.Service = fields[1]
+		}
+		if len(fields) > 2 {
+			rng.Region = fields[2]
+		}
+		ranges = append(ranges, rng)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan list: %w", err)
+	}
+	return ranges, nil
+}
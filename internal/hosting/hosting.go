@@ -0,0 +1,129 @@
+package hosting
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"myip/internal/filewatch"
+	"myip/internal/iptrie"
+)
+
+// Match describes the provider range an address belongs to.
+type Match struct {
+	Provider string `json:"provider"`
+	Service  string `json:"service,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Prefix   string `json:"prefix"`
+}
+
+// Range is a single provider range read from a file.
+type Range struct {
+	Prefix   netip.Prefix
+	Provider string
+	Service  string
+	Region   string
+}
+
+// Ranges matches addresses against the provider range files in a
+// directory. Supported files are AWS ip-ranges.json, GCP cloud.json,
+// Azure service tags JSON and plain CIDR lists, where the file name
+// names the provider.
+type Ranges struct {
+	dir     string
+	watcher *filewatch.Watcher[iptrie.Trie[Range]]
+}
+
+// OpenDir loads all range files in dir.
+func OpenDir(dir string) (*Ranges, error) {
+	r := &Ranges{dir: dir}
+	signature := func() (string, error) {
+		_, signature, err := r.scan()
+		return signature, err
+	}
+	watcher, err := filewatch.New(dir, signature, r.load)
+	if err != nil {
+		return nil, err
+	}
+	r.watcher = watcher
+	return r, nil
+}
+
+// Lookup returns the most specific provider range containing ip.
+func (r *Ranges) Lookup(ip string) (Match, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Match{}, false
+	}
+	rng, prefix, ok := r.watcher.Load().Lookup(addr)
+	if !ok {
+		return Match{}, false
+	}
+	return Match{Provider: rng.Provider, Service: rng.Service, Region: rng.Region, Prefix: prefix.String()}, true
+}
+
+// Len returns the number of loaded prefixes.
+func (r *Ranges) Len() int {
+	return r.watcher.Load().Len()
+}
+
+// Reload rebuilds the trie when a file in the directory was added,
+// removed or modified and reports whether it did.
+func (r *Ranges) Reload() (bool, error) {
+	return r.watcher.Reload()
+}
+
+// Watch polls the directory every interval until ctx is done. A broken
+// update keeps the previous ranges in use.
+func (r *Ranges) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	r.watcher.Watch(ctx, interval, onError)
+}
+
+// load builds a trie from all range files in the directory.
+func (r *Ranges) load() (*iptrie.Trie[Range], error) {
+	files, _, err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+	trie := &iptrie.Trie[Range]{}
+	for _, file := range files {
+		ranges, err := loadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("load %s: %w", file, err)
+		}
+		for _, rng := range ranges {
+			trie.Insert(rng.Prefix, rng)
+		}
+	}
+	return trie, nil
+}
+
+// scan lists range files in name order together with a signature of
+// their names, sizes and modification times.
+func (r *Ranges) scan() ([]string, string, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("read ranges dir: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var files []string
+	var signature strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, "", fmt.Errorf("stat %s: %w", entry.Name(), err)
+		}
+		files = append(files, filepath.Join(r.dir, entry.Name()))
+		fmt.Fprintf(&signature, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return files, signature.String(), nil
+}
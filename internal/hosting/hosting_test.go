@@ -0,0 +1,91 @@
+package hosting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRanges_Lookup(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "ip-ranges.json", `{
+		"syncToken": "1", "createDate": "2024-01-01-00-00-00",
+		"prefixes": [
+			{"ip_prefix": "3.0.0.0/9", "region": "GLOBAL", "service": "AMAZON"},
+			{"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "EC2"}
+		],
+		"ipv6_prefixes": [{"ipv6_prefix": "2600:1f00::/24", "region": "GLOBAL", "service": "AMAZON"}]
+	}`)
+	writeFile(t, dir, "cloud.json", `{
+		"syncToken": "1", "creationTime": "2024-01-01T00:00:00",
+		"prefixes": [{"ipv4Prefix": "34.1.208.0/20", "service": "Google Cloud", "scope": "africa-south1"}]
+	}`)
+	writeFile(t, dir, "azure.json", `{"values": [{"name": "AzureCloud.westeurope", "properties": {
+		"region": "westeurope", "systemService": "", "addressPrefixes": ["13.69.0.0/17"]
+	}}]}`)
+	writeFile(t, dir, "hetzner.txt", "# Hetzner Online\n5.9.0.0/16 dedicated fsn1\n2a01:4f8::/32\n")
+
+	r, err := OpenDir(dir)
+	if err != nil {
+		t.Fatalf("OpenDir failed: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want Match
+	}{
+		{"3.5.140.10", Match{Provider: "AWS", Service: "EC2", Region: "ap-northeast-2", Prefix: "3.5.140.0/22"}},
+		{"3.1.1.1", Match{Provider: "AWS", Service: "AMAZON", Region: "GLOBAL", Prefix: "3.0.0.0/9"}},
+		{"2600:1f00::1", Match{Provider: "AWS", Service: "AMAZON", Region: "GLOBAL", Prefix: "2600:1f00::/24"}},
+		{"34.1.210.1", Match{Provider: "Google Cloud", Service: "Google Cloud", Region: "africa-south1", Prefix: "34.1.208.0/20"}},
+		{"13.69.1.1", Match{Provider: "Azure", Service: "AzureCloud.westeurope", Region: "westeurope", Prefix: "13.69.0.0/17"}},
+		{"5.9.1.1", Match{Provider: "hetzner", Service: "dedicated", Region: "fsn1", Prefix: "5.9.0.0/16"}},
+		{"2a01:4f8:1::1", Match{Provider: "hetzner", Prefix: "2a01:4f8::/32"}},
+	}
+	for _, tt := range tests {
+		got, ok := r.Lookup(tt.ip)
+		if !ok || got != tt.want {
+			t.Errorf("Lookup(%s) = %+v %v, want %+v", tt.ip, got, ok, tt.want)
+		}
+	}
+	if _, ok := r.Lookup("192.0.2.1"); ok {
+		t.Error("expected no match for unlisted address")
+	}
+}
+
+func TestRanges_Reload(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "ovh.txt", "51.68.0.0/16\n")
+	r, err := OpenDir(dir)
+	if err != nil {
+		t.Fatalf("OpenDir failed: %v", err)
+	}
+	if reloaded, err := r.Reload(); err != nil || reloaded {
+		t.Errorf("expected no reload without changes, got %v %v", reloaded, err)
+	}
+
+	writeFile(t, dir, "digitalocean.txt", "104.131.0.0/16\n")
+	if reloaded, err := r.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected reload after adding a file, got %v %v", reloaded, err)
+	}
+	if m, ok := r.Lookup("104.131.1.1"); !ok || m.Provider != "digitalocean" {
+		t.Errorf("expected new provider after reload, got %+v", m)
+	}
+
+	writeFile(t, dir, "ovh.txt", "not a prefix\n")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "ovh.txt"), future, future)
+	if _, err := r.Reload(); err == nil {
+		t.Error("expected error for broken file")
+	}
+	if _, ok := r.Lookup("51.68.1.1"); !ok {
+		t.Error("expected previous ranges to stay loaded after a failed reload")
+	}
+}
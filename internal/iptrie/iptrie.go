@@ -0,0 +1,85 @@
+package iptrie
+
+import "net/netip"
+
+// Trie maps prefixes to values and finds the most specific prefix
+// containing an address. The zero value is an empty trie.
+type Trie[T any] struct {
+	v4   *node[T]
+	v6   *node[T]
+	size int
+}
+
+type node[T any] struct {
+	child  [2]*node[T]
+	prefix netip.Prefix
+	value  T
+	set    bool
+}
+
+// Insert stores value for prefix, replacing any previous value for the
+// same prefix.
+func (t *Trie[T]) Insert(prefix netip.Prefix, value T) {
+	prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()).Masked()
+	if !prefix.IsValid() {
+		return
+	}
+
+	root := &t.v6
+	if prefix.Addr().Is4() {
+		root = &t.v4
+	}
+	if *root == nil {
+		*root = &node[T]{}
+	}
+
+	n := *root
+	bytes := prefix.Addr().AsSlice()
+	for i := 0; i < prefix.Bits(); i++ {
+		bit := bitAt(bytes, i)
+		if n.child[bit] == nil {
+			n.child[bit] = &node[T]{}
+		}
+		n = n.child[bit]
+	}
+	if !n.set {
+		t.size++
+	}
+	n.prefix, n.value, n.set = prefix, value, true
+}
+
+// Lookup returns the value of the longest prefix containing addr.
+func (t *Trie[T]) Lookup(addr netip.Addr) (T, netip.Prefix, bool) {
+	addr = addr.Unmap()
+	n := t.v6
+	if addr.Is4() {
+		n = t.v4
+	}
+
+	var best *node[T]
+	bytes := addr.AsSlice()
+	for i := 0; n != nil; i++ {
+		if n.set {
+			best = n
+		}
+		if i == len(bytes)*8 {
+			break
+		}
+		n = n.child[bitAt(bytes, i)]
+	}
+
+	if best == nil {
+		var zero T
+		return zero, netip.Prefix{}, false
+	}
+	return best.value, best.prefix, true
+}
+
+// Len returns the number of stored prefixes.
+func (t *Trie[T]) Len() int {
+	return t.size
+}
+
+func bitAt(b []byte, i int) int {
+	return int(b[i/8]>>(7-uint(i%8))) & 1
+}
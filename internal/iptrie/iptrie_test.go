@@ -0,0 +1,51 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestTrie_Lookup(t *testing.T) {
+	var trie Trie[string]
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "wide")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "narrow")
+	trie.Insert(netip.MustParsePrefix("10.1.2.3/32"), "host")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "replaced")
+
+	if trie.Len() != 4 {
+		t.Errorf("expected 4 prefixes, got %d", trie.Len())
+	}
+
+	tests := []struct {
+		ip     string
+		value  string
+		prefix string
+		ok     bool
+	}{
+		{"10.9.9.9", "wide", "10.0.0.0/8", true},
+		{"10.1.200.1", "replaced", "10.1.0.0/16", true},
+		{"10.1.2.3", "host", "10.1.2.3/32", true},
+		{"::ffff:10.1.2.3", "host", "10.1.2.3/32", true},
+		{"2001:db8:1::1", "v6", "2001:db8::/32", true},
+		{"11.0.0.1", "", "", false},
+		{"2001:db9::1", "", "", false},
+	}
+	for _, tt := range tests {
+		value, prefix, ok := trie.Lookup(netip.MustParseAddr(tt.ip))
+		if ok != tt.ok || value != tt.value || (ok && prefix.String() != tt.prefix) {
+			t.Errorf("Lookup(%s) = %q %v %v, want %q %s %v", tt.ip, value, prefix, ok, tt.value, tt.prefix, tt.ok)
+		}
+	}
+}
+
+func TestTrie_DefaultRoute(t *testing.T) {
+	var trie Trie[int]
+	trie.Insert(netip.MustParsePrefix("0.0.0.0/0"), 1)
+	if value, _, ok := trie.Lookup(netip.MustParseAddr("203.0.113.7")); !ok || value != 1 {
+		t.Errorf("expected default route match, got %d %v", value, ok)
+	}
+	if _, _, ok := trie.Lookup(netip.MustParseAddr("::1")); ok {
+		t.Error("expected IPv4 default route not to match IPv6")
+	}
+}
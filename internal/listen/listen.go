@@ -0,0 +1,133 @@
+package listen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+const unixPrefix = "unix:"
+
+// Address families reported for accepted connections.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+	FamilyUnix = "unix"
+)
+
+// Info describes the listener a connection arrived on.
+type Info struct {
+	Name   string `json:"name"`
+	Family string `json:"family"`
+}
+
+type contextKey struct{}
+
+// Open binds a TCP host:port or a unix:/path socket. Unix sockets get the
+// given permissions; a stale socket file left by a previous run is removed.
+func Open(addr string, mode fs.FileMode) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixPrefix)
+	if !ok {
+		ln, err := net.Listen(tcpNetwork(addr), addr)
+		if err != nil {
+			return nil, fmt.Errorf("listen %s: %w", addr, err)
+		}
+		return ln, nil
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket %s: %w", path, err)
+		}
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("stat socket %s: %w", path, err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", addr, err)
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("chmod socket %s: %w", path, err)
+		}
+	}
+	return ln, nil
+}
+
+// tcpNetwork picks tcp4 or tcp6 for IP literals so that 0.0.0.0:port and
+// [::]:port can be bound side by side; the plain tcp wildcard is dual-stack
+// and would take both.
+func tcpNetwork(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "tcp"
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+// Tag wraps ln so every accepted connection remembers the listener name.
+func Tag(ln net.Listener, name string) net.Listener {
+	return &taggedListener{Listener: ln, name: name}
+}
+
+// ConnContext is meant for http.Server.ConnContext and stores the Info of
+// tagged connections in the request context.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	tc, ok := c.(*taggedConn)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, tc.info)
+}
+
+// FromContext returns the listener Info stored by ConnContext.
+func FromContext(ctx context.Context) (Info, bool) {
+	info, ok := ctx.Value(contextKey{}).(Info)
+	return info, ok
+}
+
+type taggedListener struct {
+	net.Listener
+	name string
+}
+
+func (l *taggedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &taggedConn{Conn: conn, info: Info{Name: l.name, Family: family(conn.LocalAddr())}}, nil
+}
+
+type taggedConn struct {
+	net.Conn
+	info Info
+}
+
+func family(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UnixAddr:
+		return FamilyUnix
+	case *net.TCPAddr:
+		if a.IP.To4() != nil {
+			return FamilyIPv4
+		}
+		return FamilyIPv6
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,112 @@
+package listen
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestOpen_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myip.sock")
+	ln, err := Open("unix:"+path, 0o600)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer ln.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected mode 0600, got %o", perm)
+	}
+
+	tagged := Tag(ln, "unix:"+path)
+	go func() {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := tagged.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	got, ok := FromContext(ConnContext(context.Background(), conn))
+	if !ok {
+		t.Fatal("expected listener info in context")
+	}
+	if got.Name != "unix:"+path || got.Family != FamilyUnix {
+		t.Errorf("unexpected info %+v", got)
+	}
+}
+
+func TestOpen_TCPFamily(t *testing.T) {
+	ln, err := Open("127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	tagged := Tag(ln, "v4")
+	defer tagged.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := tagged.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	got, _ := FromContext(ConnContext(context.Background(), conn))
+	if got.Family != FamilyIPv4 {
+		t.Errorf("expected family %s, got %s", FamilyIPv4, got.Family)
+	}
+}
+
+func TestFromContext_Untagged(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("expected no listener info")
+	}
+}
+
+func TestOpen_WildcardPerFamily(t *testing.T) {
+	v4, err := Open("0.0.0.0:0", 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer v4.Close()
+
+	port := v4.Addr().(*net.TCPAddr).Port
+	v6, err := Open(net.JoinHostPort("::", strconv.Itoa(port)), 0)
+	if err != nil {
+		t.Skipf("IPv6 unavailable: %v", err)
+	}
+	v6.Close()
+}
+
+func TestTCPNetwork(t *testing.T) {
+	tests := map[string]string{
+		"0.0.0.0:85":          "tcp4",
+		"[::]:85":             "tcp6",
+		"[::ffff:1.2.3.4]:85": "tcp4",
+		"localhost:85":        "tcp",
+		":85":                 "tcp",
+	}
+	for addr, expected := range tests {
+		if got := tcpNetwork(addr); got != expected {
+			t.Errorf("tcpNetwork(%s) = %s, want %s", addr, got, expected)
+		}
+	}
+}
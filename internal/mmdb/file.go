@@ -0,0 +1,42 @@
+package mmdb
+
+import (
+	"context"
+	"time"
+
+	"myip/internal/filewatch"
+)
+
+// File is a database on disk that can be reloaded when it changes, so
+// weekly GeoLite/DB-IP updates need no restart.
+type File struct {
+	watcher *filewatch.Watcher[Reader]
+}
+
+// OpenFile loads the database at path.
+func OpenFile(path string) (*File, error) {
+	watcher, err := filewatch.New(path, filewatch.FileSignature(path), func() (*Reader, error) {
+		return Open(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &File{watcher: watcher}, nil
+}
+
+// Reader returns the currently loaded database.
+func (f *File) Reader() *Reader {
+	return f.watcher.Load()
+}
+
+// Reload re-reads the file if its modification time or size changed and
+// reports whether a new database was loaded.
+func (f *File) Reload() (bool, error) {
+	return f.watcher.Reload()
+}
+
+// Watch polls the file every interval until ctx is done. A broken update
+// keeps the previous database in use.
+func (f *File) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	f.watcher.Watch(ctx, interval, onError)
+}
@@ -0,0 +1,55 @@
+package mmdb
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"myip/internal/mmdb/mmdbtest"
+)
+
+func TestFile_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	write := func(value string) {
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		entries := []mmdbtest.Entry{{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: value}}
+		if err := mmdbtest.Write(f, "Test", entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("old")
+	file, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if reloaded, err := file.Reload(); err != nil || reloaded {
+		t.Errorf("expected no reload for unchanged file, got %v %v", reloaded, err)
+	}
+
+	write("newer")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if reloaded, err := file.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected reload, got %v %v", reloaded, err)
+	}
+	value, _, _, _ := file.Reader().Lookup(netip.MustParseAddr("10.1.2.3"))
+	if value != "newer" {
+		t.Errorf("expected reloaded value, got %v", value)
+	}
+
+	os.WriteFile(path, []byte("garbage"), 0o644)
+	os.Chtimes(path, future.Add(time.Minute), future.Add(time.Minute))
+	if _, err := file.Reload(); err == nil {
+		t.Error("expected error for corrupt file")
+	}
+	if value, _, _, _ := file.Reader().Lookup(netip.MustParseAddr("10.1.2.3")); value != "newer" {
+		t.Errorf("expected previous database to stay loaded, got %v", value)
+	}
+}
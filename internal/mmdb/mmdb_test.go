@@ -0,0 +1,91 @@
+package mmdb
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"myip/internal/mmdb/mmdbtest"
+)
+
+func TestWriteAndLookup(t *testing.T) {
+	var buf bytes.Buffer
+	err := mmdbtest.Write(&buf, "Test-City", []mmdbtest.Entry{
+		{Prefix: netip.MustParsePrefix("81.2.69.0/24"), Value: map[string]any{
+			"country":  map[string]any{"iso_code": "GB", "names": map[string]any{"en": "United Kingdom"}},
+			"location": map[string]any{"latitude": 51.5142, "longitude": -0.0931, "accuracy_radius": uint16(100)},
+			"subdivisions": []any{
+				map[string]any{"names": map[string]any{"en": "England"}},
+			},
+		}},
+		{Prefix: netip.MustParsePrefix("81.2.69.160/27"), Value: map[string]any{"city": "London", "offset": -5}},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), Value: map[string]any{"long": string(bytes.Repeat([]byte("x"), 300))}},
+	})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	r, err := FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("FromBytes failed: %v", err)
+	}
+	if r.Metadata.DatabaseType != "Test-City" || r.Metadata.IPVersion != 6 {
+		t.Errorf("unexpected metadata %+v", r.Metadata)
+	}
+
+	record, prefixLen, ok, err := r.Lookup(netip.MustParseAddr("81.2.69.10"))
+	if err != nil || !ok {
+		t.Fatalf("Lookup failed: %v, %v", ok, err)
+	}
+	// The /27 splits the /24 in the tree, so the matched record is a /25.
+	if prefixLen != 25 {
+		t.Errorf("expected /25 match, got /%d", prefixLen)
+	}
+	if got := String(record, "country", "iso_code"); got != "GB" {
+		t.Errorf("expected GB, got %q", got)
+	}
+	if got := String(record, "subdivisions", 0, "names", "en"); got != "England" {
+		t.Errorf("expected England, got %q", got)
+	}
+	if got := Float(record, "location", "latitude"); got != 51.5142 {
+		t.Errorf("unexpected latitude %v", got)
+	}
+	if got := Uint(record, "location", "accuracy_radius"); got != 100 {
+		t.Errorf("unexpected accuracy radius %v", got)
+	}
+
+	record, prefixLen, ok, _ = r.Lookup(netip.MustParseAddr("::ffff:81.2.69.170"))
+	if !ok || prefixLen != 27 || String(record, "city") != "London" || Get(record, "offset") != int64(-5) {
+		t.Errorf("unexpected more specific match %v /%d", record, prefixLen)
+	}
+
+	record, _, ok, _ = r.Lookup(netip.MustParseAddr("2001:db8::1"))
+	if !ok || len(String(record, "long")) != 300 {
+		t.Errorf("unexpected IPv6 record %v", record)
+	}
+
+	if _, _, ok, err := r.Lookup(netip.MustParseAddr("8.8.8.8")); ok || err != nil {
+		t.Errorf("expected miss for 8.8.8.8, got %v, %v", ok, err)
+	}
+}
+
+func TestFromBytes_Invalid(t *testing.T) {
+	if _, err := FromBytes([]byte("not a database")); err == nil {
+		t.Error("expected error for missing metadata")
+	}
+
+	// Metadata longer than the tree it describes, with the tree cut off.
+	var buf bytes.Buffer
+	err := mmdbtest.Write(&buf, strings.Repeat("x", 4096), []mmdbtest.Entry{
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := buf.Bytes()
+	truncated := db[bytes.LastIndex(db, metadataMarker):]
+	if _, err := FromBytes(truncated); err == nil {
+		t.Error("expected error for a truncated search tree")
+	}
+}
@@ -0,0 +1,233 @@
+// Package mmdbtest writes small MaxMind DB files for tests of the readers
+// built on package mmdb.
+package mmdbtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"sort"
+)
+
+const writerRecordSize = 24
+
+// Data section types and layout from the MaxMind DB specification, as
+// read by package mmdb.
+const (
+	typeString        = 2
+	typeDouble        = 3
+	typeUint16        = 5
+	typeUint32        = 6
+	typeMap           = 7
+	typeInt32         = 8
+	typeUint64        = 9
+	typeArray         = 11
+	typeBool          = 14
+	dataSeparatorSize = 16
+)
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// Entry maps a network to the value stored for it. Values may be maps
+// with string keys, slices, strings, bools, float64 and unsigned or signed
+// integers.
+type Entry struct {
+	Prefix netip.Prefix
+	Value  any
+}
+
+type writerNode struct {
+	records [2]record
+}
+
+type record struct {
+	kind  int // 0 empty, 1 node, 2 data
+	value int
+}
+
+// Write encodes entries as an IPv6 MaxMind DB with 24 bit records. Later
+// entries override earlier ones where they overlap.
+func Write(w io.Writer, databaseType string, entries []Entry) error {
+	nodes := []writerNode{{}}
+	var data bytes.Buffer
+
+	for _, entry := range entries {
+		offset := data.Len()
+		if err := encodeValue(&data, entry.Value); err != nil {
+			return fmt.Errorf("mmdb: encode %s: %w", entry.Prefix, err)
+		}
+
+		addr := entry.Prefix.Addr().As16()
+		bits := entry.Prefix.Bits()
+		if entry.Prefix.Addr().Is4() {
+			// As16 yields the ::ffff: mapped form; the tree keeps IPv4
+			// under ::/96 instead.
+			addr[10], addr[11] = 0, 0
+			bits += 96
+		}
+		insert(&nodes, addr, bits, record{kind: 2, value: offset})
+	}
+
+	nodeCount := len(nodes)
+	if nodeCount >= 1<<writerRecordSize {
+		return fmt.Errorf("mmdb: too many nodes: %d", nodeCount)
+	}
+
+	var out bytes.Buffer
+	for _, node := range nodes {
+		for _, rec := range node.records {
+			var v int
+			switch rec.kind {
+			case 0:
+				v = nodeCount
+			case 1:
+				v = rec.value
+			default:
+				v = nodeCount + dataSeparatorSize + rec.value
+			}
+			out.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	out.Write(make([]byte, dataSeparatorSize))
+	out.Write(data.Bytes())
+	out.Write(metadataMarker)
+
+	meta := map[string]any{
+		"node_count":                  uint64(nodeCount),
+		"record_size":                 uint64(writerRecordSize),
+		"ip_version":                  uint64(6),
+		"database_type":               databaseType,
+		"binary_format_major_version": uint64(2),
+		"binary_format_minor_version": uint64(0),
+		"build_epoch":                 uint64(0),
+		"languages":                   []any{"en"},
+		"description":                 map[string]any{"en": databaseType},
+	}
+	if err := encodeValue(&out, meta); err != nil {
+		return fmt.Errorf("mmdb: encode metadata: %w", err)
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func insert(nodes *[]writerNode, addr [16]byte, bits int, leaf record) {
+	node := 0
+	for i := 0; i < bits; i++ {
+		bit := (addr[i/8] >> (7 - uint(i%8))) & 1
+		if i == bits-1 {
+			(*nodes)[node].records[bit] = leaf
+			return
+		}
+
+		rec := (*nodes)[node].records[bit]
+		if rec.kind != 1 {
+			// Push an existing shorter prefix down into both children.
+			*nodes = append(*nodes, writerNode{records: [2]record{rec, rec}})
+			rec = record{kind: 1, value: len(*nodes) - 1}
+			(*nodes)[node].records[bit] = rec
+		}
+		node = rec.value
+	}
+}
+
+func encodeValue(buf *bytes.Buffer, v any) error {
+	switch value := v.(type) {
+	case string:
+		writeControl(buf, typeString, len(value))
+		buf.WriteString(value)
+	case bool:
+		n := 0
+		if value {
+			n = 1
+		}
+		writeControl(buf, typeBool, n)
+	case float64:
+		writeControl(buf, typeDouble, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(value))
+	case int:
+		if value < 0 {
+			return encodeInt32(buf, int32(value))
+		}
+		encodeUint(buf, typeUint64, uint64(value))
+	case int32:
+		return encodeInt32(buf, value)
+	case uint16:
+		encodeUint(buf, typeUint16, uint64(value))
+	case uint32:
+		encodeUint(buf, typeUint32, uint64(value))
+	case uint64:
+		encodeUint(buf, typeUint64, value)
+	case []any:
+		writeControl(buf, typeArray, len(value))
+		for _, item := range value {
+			if err := encodeValue(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeControl(buf, typeMap, len(value))
+		for _, key := range keys {
+			if err := encodeValue(buf, key); err != nil {
+				return err
+			}
+			if err := encodeValue(buf, value[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+func encodeInt32(buf *bytes.Buffer, v int32) error {
+	writeControl(buf, typeInt32, 4)
+	return binary.Write(buf, binary.BigEndian, v)
+}
+
+func encodeUint(buf *bytes.Buffer, typ int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	trimmed := bytes.TrimLeft(b[:], "\x00")
+	writeControl(buf, typ, len(trimmed))
+	buf.Write(trimmed)
+}
+
+func writeControl(buf *bytes.Buffer, typ, size int) {
+	ctrl := byte(0)
+	if typ <= 7 {
+		ctrl = byte(typ) << 5
+	}
+
+	var extra []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		extra = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		n := size - 285
+		extra = []byte{byte(n >> 8), byte(n)}
+	default:
+		ctrl |= 31
+		n := size - 65821
+		extra = []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+
+	buf.WriteByte(ctrl)
+	if typ > 7 {
+		buf.WriteByte(byte(typ - 7))
+	}
+	buf.Write(extra)
+}
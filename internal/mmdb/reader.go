@@ -0,0 +1,387 @@
+package mmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"os"
+)
+
+// Data section types from the MaxMind DB specification.
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+const (
+	dataSeparatorSize = 16
+	maxMetadataSize   = 128 * 1024
+	maxDecodeDepth    = 32
+)
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// Metadata describes a database.
+type Metadata struct {
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	DatabaseType string
+	BuildEpoch   uint64
+}
+
+// Reader looks up addresses in an in-memory MaxMind DB file.
+type Reader struct {
+	Metadata Metadata
+	buf      []byte
+	data     []byte
+	ipv4Root uint
+}
+
+// Open reads the database at path into memory.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mmdb: %w", err)
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes parses a database held in memory.
+func FromBytes(buf []byte) (*Reader, error) {
+	searchFrom := max(0, len(buf)-maxMetadataSize)
+	idx := bytes.LastIndex(buf[searchFrom:], metadataMarker)
+	if idx < 0 {
+		return nil, errors.New("mmdb: metadata not found")
+	}
+	metaStart := searchFrom + idx + len(metadataMarker)
+
+	raw, _, err := decode(buf[metaStart:], 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("mmdb: decode metadata: %w", err)
+	}
+	fields, ok := raw.(map[string]any)
+	if !ok {
+		return nil, errors.New("mmdb: metadata is not a map")
+	}
+
+	meta := Metadata{
+		NodeCount:    uint(asUint(fields["node_count"])),
+		RecordSize:   uint(asUint(fields["record_size"])),
+		IPVersion:    uint(asUint(fields["ip_version"])),
+		BuildEpoch:   asUint(fields["build_epoch"]),
+		DatabaseType: asString(fields["database_type"]),
+	}
+	switch meta.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("mmdb: unsupported record size %d", meta.RecordSize)
+	}
+
+	// The tree and the separator must end before the metadata marker, or
+	// the data section slice below would be inverted.
+	dataEnd := metaStart - len(metadataMarker)
+	treeSize := meta.RecordSize * 2 / 8 * meta.NodeCount
+	if meta.NodeCount > uint(dataEnd) || treeSize+dataSeparatorSize > uint(dataEnd) {
+		return nil, errors.New("mmdb: search tree exceeds file size")
+	}
+
+	r := &Reader{
+		Metadata: meta,
+		buf:      buf,
+		data:     buf[treeSize+dataSeparatorSize : dataEnd],
+	}
+	if meta.IPVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < meta.NodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Root = node
+	}
+	return r, nil
+}
+
+// Lookup returns the decoded record for addr and the length of the
+// network it matched. ok is false when the address is not in the database.
+func (r *Reader) Lookup(addr netip.Addr) (any, int, bool, error) {
+	addr = addr.Unmap()
+	node := uint(0)
+	bits := addr.AsSlice()
+	if addr.Is4() {
+		if r.Metadata.IPVersion == 6 {
+			node = r.ipv4Root
+		}
+	} else if r.Metadata.IPVersion == 4 {
+		return nil, 0, false, nil
+	}
+
+	bitLen := len(bits) * 8
+	i := 0
+	for ; i < bitLen && node < r.Metadata.NodeCount; i++ {
+		bit := (bits[i/8] >> (7 - uint(i%8))) & 1
+		node = r.record(node, uint(bit))
+	}
+
+	switch {
+	case node == r.Metadata.NodeCount:
+		return nil, 0, false, nil
+	case node < r.Metadata.NodeCount:
+		return nil, 0, false, errors.New("mmdb: invalid search tree")
+	}
+
+	offset := node - r.Metadata.NodeCount - dataSeparatorSize
+	if offset >= uint(len(r.data)) {
+		return nil, 0, false, errors.New("mmdb: data pointer out of range")
+	}
+	value, _, err := decode(r.data, offset, 0)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("mmdb: decode record: %w", err)
+	}
+	return value, i, true, nil
+}
+
+func (r *Reader) record(node, side uint) uint {
+	b := r.buf
+	switch r.Metadata.RecordSize {
+	case 24:
+		off := node*6 + side*3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+	case 28:
+		off := node * 7
+		if side == 0 {
+			return uint(b[off+3]&0xf0)<<20 | uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+		}
+		return uint(b[off+3]&0x0f)<<24 | uint(b[off+4])<<16 | uint(b[off+5])<<8 | uint(b[off+6])
+	default:
+		off := node*8 + side*4
+		return uint(binary.BigEndian.Uint32(b[off:]))
+	}
+}
+
+var errTruncated = errors.New("truncated data")
+
+// decode reads the value at offset and returns it with the offset of the
+// next value.
+func decode(data []byte, offset uint, depth int) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("data nested too deeply")
+	}
+	if offset >= uint(len(data)) {
+		return nil, 0, errTruncated
+	}
+
+	ctrl := data[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+
+	if typ == typePointer {
+		pointer, next, err := readPointer(data, ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := decode(data, pointer, depth+1)
+		return value, next, err
+	}
+
+	if typ == typeExtended {
+		if offset >= uint(len(data)) {
+			return nil, 0, errTruncated
+		}
+		typ = 7 + uint(data[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		extra := size - 28
+		if offset+extra > uint(len(data)) {
+			return nil, 0, errTruncated
+		}
+		n := uint(0)
+		for _, b := range data[offset : offset+extra] {
+			n = n<<8 | uint(b)
+		}
+		switch size {
+		case 29:
+			size = 29 + n
+		case 30:
+			size = 285 + n
+		default:
+			size = 65821 + n
+		}
+		offset += extra
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := decode(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			value, after, err := decode(data, next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[k] = value
+			offset = after
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := decode(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEnd:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(data)) {
+		return nil, 0, errTruncated
+	}
+	raw := data[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case typeString:
+		return string(raw), next, nil
+	case typeBytes:
+		return append([]byte(nil), raw...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		var n uint64
+		for _, b := range raw {
+			n = n<<8 | uint64(b)
+		}
+		return n, next, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(raw), next, nil
+	case typeInt32:
+		var n uint32
+		for _, b := range raw {
+			n = n<<8 | uint32(b)
+		}
+		return int64(int32(n)), next, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown data type %d", typ)
+	}
+}
+
+func readPointer(data []byte, ctrl byte, offset uint) (uint, uint, error) {
+	ss := uint(ctrl>>3) & 0x3
+	vvv := uint(ctrl & 0x7)
+	n := ss + 1
+	if offset+n > uint(len(data)) {
+		return 0, 0, errTruncated
+	}
+	b := data[offset : offset+n]
+	var pointer uint
+	switch ss {
+	case 0:
+		pointer = vvv<<8 | uint(b[0])
+	case 1:
+		pointer = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 2:
+		pointer = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		pointer = uint(binary.BigEndian.Uint32(b))
+	}
+	return pointer, offset + n, nil
+}
+
+// Get walks decoded maps and arrays by string keys and int indices and
+// returns nil when the path does not exist.
+func Get(v any, path ...any) any {
+	for _, step := range path {
+		switch key := step.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil
+			}
+			v = m[key]
+		case int:
+			a, ok := v.([]any)
+			if !ok || key < 0 || key >= len(a) {
+				return nil
+			}
+			v = a[key]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// String returns the string at path or "".
+func String(v any, path ...any) string {
+	return asString(Get(v, path...))
+}
+
+// Uint returns the unsigned integer at path or 0.
+func Uint(v any, path ...any) uint64 {
+	return asUint(Get(v, path...))
+}
+
+// Float returns the floating point number at path or 0.
+func Float(v any, path ...any) float64 {
+	f, _ := Get(v, path...).(float64)
+	return f
+}
+
+func asUint(v any) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		if n > 0 {
+			return uint64(n)
+		}
+	}
+	return 0
+}
+
+func asString(v any) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,236 @@
+package netcalc
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// MaxSplit bounds the number of subnets Split may return.
+const MaxSplit = 1 << maxSplitBits
+
+// maxSplitBits is log2(MaxSplit), the most bits Split may add to a prefix.
+const maxSplitBits = 12
+
+// Subnet describes a prefix the way a subnet calculator shows it.
+type Subnet struct {
+	Prefix       string    `json:"prefix"`
+	Version      int       `json:"version"`
+	PrefixLength int       `json:"prefix_length"`
+	Network      string    `json:"network"`
+	Broadcast    string    `json:"broadcast,omitempty"`
+	FirstUsable  string    `json:"first_usable"`
+	LastUsable   string    `json:"last_usable"`
+	Addresses    string    `json:"addresses"`
+	Hosts        string    `json:"hosts"`
+	Netmask      string    `json:"netmask"`
+	Wildcard     string    `json:"wildcard"`
+	Special      []Special `json:"special,omitempty"`
+}
+
+// ParsePrefix accepts a CIDR or a bare address (treated as a host prefix)
+// and returns it masked to its network.
+func ParsePrefix(raw string) (netip.Prefix, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "/") {
+		addr, err := netip.ParseAddr(raw)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid prefix %q", raw)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(raw)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix %q", raw)
+	}
+	if prefix.Addr().Is4In6() {
+		bits := prefix.Bits() - 96
+		if bits < 0 {
+			return netip.Prefix{}, fmt.Errorf("invalid prefix %q", raw)
+		}
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), bits)
+	}
+	return prefix.Masked(), nil
+}
+
+// Describe computes the calculator view of a prefix.
+func Describe(prefix netip.Prefix) Subnet {
+	prefix = prefix.Masked()
+	bits := prefix.Addr().BitLen()
+	hostBits := bits - prefix.Bits()
+
+	network := prefix.Addr()
+	last := lastAddr(prefix)
+	addresses := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+
+	subnet := Subnet{
+		Prefix:       prefix.String(),
+		Version:      6,
+		PrefixLength: prefix.Bits(),
+		Network:      network.String(),
+		FirstUsable:  network.String(),
+		LastUsable:   last.String(),
+		Addresses:    addresses.String(),
+		Hosts:        addresses.String(),
+		Netmask:      maskAddr(bits, prefix.Bits(), false).String(),
+		Wildcard:     maskAddr(bits, prefix.Bits(), true).String(),
+		Special:      Classify(prefix),
+	}
+
+	if network.Is4() {
+		subnet.Version = 4
+		subnet.Broadcast = last.String()
+		// RFC 3021 point-to-point /31 and single host /32 have no
+		// network or broadcast address to exclude.
+		if hostBits >= 2 {
+			subnet.FirstUsable = network.Next().String()
+			subnet.LastUsable = last.Prev().String()
+			subnet.Hosts = new(big.Int).Sub(addresses, big.NewInt(2)).String()
+		}
+	}
+	return subnet
+}
+
+// Split divides prefix into subnets of the given length.
+func Split(prefix netip.Prefix, length int) ([]netip.Prefix, error) {
+	prefix = prefix.Masked()
+	if length < prefix.Bits() || length > prefix.Addr().BitLen() {
+		return nil, fmt.Errorf("cannot split /%d into /%d", prefix.Bits(), length)
+	}
+	if length-prefix.Bits() > maxSplitBits {
+		return nil, fmt.Errorf("split would produce more than %d subnets", MaxSplit)
+	}
+
+	count := 1 << (length - prefix.Bits())
+	step := new(big.Int).Lsh(big.NewInt(1), uint(prefix.Addr().BitLen()-length))
+	current := toInt(prefix.Addr())
+	subnets := make([]netip.Prefix, 0, count)
+	for i := 0; i < count; i++ {
+		subnets = append(subnets, netip.PrefixFrom(fromInt(current, prefix.Addr().Is4()), length))
+		current.Add(current, step)
+	}
+	return subnets, nil
+}
+
+// Summarize aggregates prefixes into the minimal covering set without
+// including any address that was not in the input.
+func Summarize(prefixes []netip.Prefix) []netip.Prefix {
+	var v4, v6 []addrRange
+	for _, prefix := range prefixes {
+		prefix = prefix.Masked()
+		r := addrRange{start: toInt(prefix.Addr()), end: toInt(lastAddr(prefix))}
+		if prefix.Addr().Is4() {
+			v4 = append(v4, r)
+		} else {
+			v6 = append(v6, r)
+		}
+	}
+
+	var result []netip.Prefix
+	result = append(result, rangesToPrefixes(mergeRanges(v4), true)...)
+	result = append(result, rangesToPrefixes(mergeRanges(v6), false)...)
+	return result
+}
+
+// RangePrefixes returns the minimal set of prefixes covering first..last.
+// Both addresses must belong to the same family.
+func RangePrefixes(first, last netip.Addr) []netip.Prefix {
+	first, last = first.Unmap(), last.Unmap()
+	if first.Is4() != last.Is4() || last.Less(first) {
+		return nil
+	}
+	return rangesToPrefixes([]addrRange{{start: toInt(first), end: toInt(last)}}, first.Is4())
+}
+
+type addrRange struct {
+	start *big.Int
+	end   *big.Int
+}
+
+func mergeRanges(ranges []addrRange) []addrRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Cmp(ranges[j].start) < 0 })
+
+	var merged []addrRange
+	one := big.NewInt(1)
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			adjacent := new(big.Int).Add(merged[n-1].end, one)
+			if r.start.Cmp(adjacent) <= 0 {
+				if r.end.Cmp(merged[n-1].end) > 0 {
+					merged[n-1].end = r.end
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func rangesToPrefixes(ranges []addrRange, is4 bool) []netip.Prefix {
+	bits := 128
+	if is4 {
+		bits = 32
+	}
+
+	var prefixes []netip.Prefix
+	one := big.NewInt(1)
+	for _, r := range ranges {
+		start := new(big.Int).Set(r.start)
+		for start.Cmp(r.end) <= 0 {
+			// Grow the block while start stays aligned and it fits the range.
+			size := 0
+			for size < bits {
+				next := size + 1
+				if start.TrailingZeroBits() < uint(next) && start.Sign() != 0 {
+					break
+				}
+				end := new(big.Int).Add(start, new(big.Int).Lsh(one, uint(next)))
+				end.Sub(end, one)
+				if end.Cmp(r.end) > 0 {
+					break
+				}
+				size = next
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(fromInt(start, is4), bits-size))
+			start.Add(start, new(big.Int).Lsh(one, uint(size)))
+		}
+	}
+	return prefixes
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bits := prefix.Addr().BitLen()
+	wildcard := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefix.Bits()))
+	wildcard.Sub(wildcard, big.NewInt(1))
+	return fromInt(new(big.Int).Or(toInt(prefix.Addr()), wildcard), prefix.Addr().Is4())
+}
+
+func maskAddr(bits, ones int, invert bool) netip.Addr {
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	mask.Sub(mask, big.NewInt(1))
+	if !invert {
+		all := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		all.Sub(all, big.NewInt(1))
+		mask.Xor(mask, all)
+	}
+	return fromInt(mask, bits == 32)
+}
+
+func toInt(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+func fromInt(n *big.Int, is4 bool) netip.Addr {
+	if is4 {
+		var b [4]byte
+		n.FillBytes(b[:])
+		return netip.AddrFrom4(b)
+	}
+	var b [16]byte
+	n.FillBytes(b[:])
+	return netip.AddrFrom16(b)
+}
@@ -0,0 +1,138 @@
+package netcalc
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		expected Subnet
+	}{
+		{
+			prefix: "192.168.1.77/22",
+			expected: Subnet{
+				Prefix: "192.168.0.0/22", Version: 4, PrefixLength: 22,
+				Network: "192.168.0.0", Broadcast: "192.168.3.255",
+				FirstUsable: "192.168.0.1", LastUsable: "192.168.3.254",
+				Addresses: "1024", Hosts: "1022",
+				Netmask: "255.255.252.0", Wildcard: "0.0.3.255",
+			},
+		},
+		{
+			prefix: "10.0.0.0/31",
+			expected: Subnet{
+				Prefix: "10.0.0.0/31", Version: 4, PrefixLength: 31,
+				Network: "10.0.0.0", Broadcast: "10.0.0.1",
+				FirstUsable: "10.0.0.0", LastUsable: "10.0.0.1",
+				Addresses: "2", Hosts: "2",
+				Netmask: "255.255.255.254", Wildcard: "0.0.0.1",
+			},
+		},
+		{
+			prefix: "2001:db8:abcd:12::1/64",
+			expected: Subnet{
+				Prefix: "2001:db8:abcd:12::/64", Version: 6, PrefixLength: 64,
+				Network: "2001:db8:abcd:12::", FirstUsable: "2001:db8:abcd:12::",
+				LastUsable: "2001:db8:abcd:12:ffff:ffff:ffff:ffff",
+				Addresses:  "18446744073709551616", Hosts: "18446744073709551616",
+				Netmask: "ffff:ffff:ffff:ffff::", Wildcard: "::ffff:ffff:ffff:ffff",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			prefix, err := ParsePrefix(tt.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Describe(prefix)
+			got.Special = nil
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Describe(%s) =\n%+v\nwant\n%+v", tt.prefix, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	subnets, err := Split(netip.MustParsePrefix("10.0.0.0/22"), 24)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	expected := []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"}
+	if got := toStrings(subnets); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Split = %v, want %v", got, expected)
+	}
+
+	if _, err := Split(netip.MustParsePrefix("10.0.0.0/24"), 22); err == nil {
+		t.Error("expected error splitting into a shorter prefix")
+	}
+	if _, err := Split(netip.MustParsePrefix("2001:db8::/32"), 64); err == nil {
+		t.Error("expected error for too many subnets")
+	}
+	if subnets, err := Split(netip.MustParsePrefix("10.0.0.0/16"), 16+maxSplitBits); err != nil || len(subnets) != MaxSplit {
+		t.Errorf("expected exactly %d subnets, got %d, %v", MaxSplit, len(subnets), err)
+	}
+	if _, err := Split(netip.MustParsePrefix("10.0.0.0/16"), 17+maxSplitBits); err == nil {
+		t.Error("expected error for one bit above the limit")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var input []netip.Prefix
+	for _, raw := range []string{
+		"10.0.1.0/24", "10.0.0.0/24", "10.0.2.0/23", "10.0.2.128/25",
+		"192.168.0.1/32", "192.168.0.2/32",
+		"2001:db8::/33", "2001:db8:8000::/33",
+	} {
+		input = append(input, netip.MustParsePrefix(raw))
+	}
+
+	expected := []string{"10.0.0.0/22", "192.168.0.1/32", "192.168.0.2/32", "2001:db8::/32"}
+	if got := toStrings(Summarize(input)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Summarize = %v, want %v", got, expected)
+	}
+}
+
+func TestRangePrefixes(t *testing.T) {
+	got := toStrings(RangePrefixes(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.6")))
+	expected := []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("RangePrefixes = %v, want %v", got, expected)
+	}
+	if got := RangePrefixes(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::1")); got != nil {
+		t.Errorf("expected nil for mixed families, got %v", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	special := Classify(netip.MustParsePrefix("10.1.0.0/16"))
+	if len(special) != 1 || special[0].Name != "Private-Use" {
+		t.Errorf("unexpected classification %+v", special)
+	}
+	if got := Classify(netip.MustParsePrefix("8.8.8.0/24")); len(got) != 0 {
+		t.Errorf("expected no special ranges, got %+v", got)
+	}
+
+	if IsGlobal(netip.MustParseAddr("192.168.1.1")) {
+		t.Error("expected private address to be non-global")
+	}
+	if !IsGlobal(netip.MustParseAddr("2001:0:1::1")) {
+		t.Error("expected TEREDO address to be global")
+	}
+	if !IsGlobal(netip.MustParseAddr("8.8.8.8")) {
+		t.Error("expected 8.8.8.8 to be global")
+	}
+}
+
+func toStrings(prefixes []netip.Prefix) []string {
+	result := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		result = append(result, prefix.String())
+	}
+	return result
+}
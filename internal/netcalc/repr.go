@@ -0,0 +1,140 @@
+package netcalc
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Interface identifier kinds guessed for IPv6 addresses.
+const (
+	IIDEUI64      = "eui-64"
+	IIDLowByte    = "low-byte"
+	IIDEmbedded   = "embedded-ipv4"
+	IIDRandomized = "randomized"
+)
+
+// lowByteLimit is the largest interface ID still treated as manually
+// assigned (::1, ::53, ::beef and so on).
+const lowByteLimit = 0xffff
+
+// Representation lists alternative notations of a single address.
+type Representation struct {
+	Canonical      string `json:"canonical"`
+	Expanded       string `json:"expanded"`
+	Decimal        string `json:"decimal"`
+	Hex            string `json:"hex"`
+	Octal          string `json:"octal,omitempty"`
+	Binary         string `json:"binary,omitempty"`
+	PTR            string `json:"ptr"`
+	IPv4Mapped     string `json:"ipv4_mapped,omitempty"`
+	IPv4Compatible string `json:"ipv4_compatible,omitempty"`
+	EmbeddedIPv4   string `json:"embedded_ipv4,omitempty"`
+	InterfaceID    string `json:"interface_id,omitempty"`
+	InterfaceType  string `json:"interface_id_type,omitempty"`
+	MAC            string `json:"mac,omitempty"`
+	Privacy        bool   `json:"privacy_extension,omitempty"`
+}
+
+// Represent computes the notations of addr. IPv4-mapped IPv6 addresses are
+// described as their IPv4 address with the mapped form kept.
+func Represent(addr netip.Addr) Representation {
+	addr = addr.WithZone("")
+	mapped := addr.Is4In6()
+	addr = addr.Unmap()
+	value := toInt(addr)
+
+	repr := Representation{
+		Canonical: addr.String(),
+		Decimal:   value.String(),
+		PTR:       PTRName(addr),
+	}
+
+	if addr.Is4() {
+		b := addr.As4()
+		repr.Expanded = fmt.Sprintf("%03d.%03d.%03d.%03d", b[0], b[1], b[2], b[3])
+		repr.Hex = fmt.Sprintf("0x%08X", value.Uint64())
+		repr.Octal = "0" + strconv.FormatUint(value.Uint64(), 8)
+		repr.Binary = fmt.Sprintf("%08b.%08b.%08b.%08b", b[0], b[1], b[2], b[3])
+		repr.IPv4Mapped = "::ffff:" + addr.String()
+		repr.IPv4Compatible = "::" + addr.String()
+		if mapped {
+			repr.EmbeddedIPv4 = addr.String()
+		}
+		return repr
+	}
+
+	repr.Expanded = addr.StringExpanded()
+	repr.Hex = "0x" + fmt.Sprintf("%032X", value)
+	if embedded, ok := embeddedIPv4(addr); ok {
+		repr.EmbeddedIPv4 = embedded.String()
+	}
+
+	b := addr.As16()
+	iid := b[8:]
+	repr.InterfaceID = fmt.Sprintf("%02x%02x:%02x%02x:%02x%02x:%02x%02x", iid[0], iid[1], iid[2], iid[3], iid[4], iid[5], iid[6], iid[7])
+	repr.InterfaceType = interfaceType(iid)
+	if repr.InterfaceType == IIDEUI64 {
+		repr.MAC = eui64MAC(iid)
+	}
+	// Temporary (RFC 8981) and stable-opaque (RFC 7217) identifiers are
+	// indistinguishable from outside; both look random on a global address.
+	repr.Privacy = repr.InterfaceType == IIDRandomized && addr.IsGlobalUnicast() && IsGlobal(addr)
+	return repr
+}
+
+// PTRName returns the in-addr.arpa or ip6.arpa name for reverse lookups.
+func PTRName(addr netip.Addr) string {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		b := addr.As4()
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", b[3], b[2], b[1], b[0])
+	}
+
+	b := addr.As16()
+	var sb strings.Builder
+	const hexDigits = "0123456789abcdef"
+	for i := len(b) - 1; i >= 0; i-- {
+		sb.WriteByte(hexDigits[b[i]&0x0f])
+		sb.WriteByte('.')
+		sb.WriteByte(hexDigits[b[i]>>4])
+		sb.WriteByte('.')
+	}
+	sb.WriteString("ip6.arpa.")
+	return sb.String()
+}
+
+// embeddedIPv4 extracts IPv4 addresses from IPv4-compatible, 6to4 and
+// NAT64 well-known prefix addresses.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	b := addr.As16()
+	switch {
+	case netip.MustParsePrefix("2002::/16").Contains(addr):
+		return netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}), true
+	case netip.MustParsePrefix("64:ff9b::/96").Contains(addr):
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), true
+	case netip.MustParsePrefix("::/96").Contains(addr) && new(big.Int).SetBytes(b[:]).Cmp(big.NewInt(lowByteLimit)) > 0:
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), true
+	}
+	return netip.Addr{}, false
+}
+
+func interfaceType(iid []byte) string {
+	if iid[3] == 0xff && iid[4] == 0xfe {
+		return IIDEUI64
+	}
+	if new(big.Int).SetBytes(iid).Cmp(big.NewInt(lowByteLimit)) <= 0 {
+		return IIDLowByte
+	}
+	if iid[0] == 0 && iid[1] == 0 && iid[2] == 0 && iid[3] == 0 {
+		return IIDEmbedded
+	}
+	return IIDRandomized
+}
+
+// eui64MAC reverses modified EUI-64: drop ff:fe and flip the U/L bit.
+func eui64MAC(iid []byte) string {
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", iid[0]^0x02, iid[1], iid[2], iid[5], iid[6], iid[7])
+}
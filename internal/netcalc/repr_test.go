@@ -0,0 +1,71 @@
+package netcalc
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestRepresent_IPv4(t *testing.T) {
+	repr := Represent(netip.MustParseAddr("::ffff:192.168.1.1"))
+
+	expected := Representation{
+		Canonical:      "192.168.1.1",
+		Expanded:       "192.168.001.001",
+		Decimal:        "3232235777",
+		Hex:            "0xC0A80101",
+		Octal:          "030052000401",
+		Binary:         "11000000.10101000.00000001.00000001",
+		PTR:            "1.1.168.192.in-addr.arpa.",
+		IPv4Mapped:     "::ffff:192.168.1.1",
+		IPv4Compatible: "::192.168.1.1",
+		EmbeddedIPv4:   "192.168.1.1",
+	}
+	if repr != expected {
+		t.Errorf("Represent =\n%+v\nwant\n%+v", repr, expected)
+	}
+}
+
+func TestRepresent_IPv6(t *testing.T) {
+	tests := []struct {
+		addr     string
+		iidType  string
+		mac      string
+		privacy  bool
+		embedded string
+	}{
+		{addr: "2001:db8::1", iidType: IIDLowByte},
+		{addr: "2a00:1450:4001:82a::200e", iidType: IIDLowByte},
+		{addr: "2a02:8108::0211:22ff:fe33:4455", iidType: IIDEUI64, mac: "00:11:22:33:44:55"},
+		{addr: "2a02:8108::3c5d:9a1e:47b2:c0de", iidType: IIDRandomized, privacy: true},
+		{addr: "2002:c000:0204::1", iidType: IIDLowByte, embedded: "192.0.2.4"},
+		{addr: "64:ff9b::808:808", iidType: IIDEmbedded, embedded: "8.8.8.8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			repr := Represent(netip.MustParseAddr(tt.addr))
+			if repr.InterfaceType != tt.iidType {
+				t.Errorf("interface type = %s, want %s", repr.InterfaceType, tt.iidType)
+			}
+			if repr.MAC != tt.mac {
+				t.Errorf("mac = %q, want %q", repr.MAC, tt.mac)
+			}
+			if repr.Privacy != tt.privacy {
+				t.Errorf("privacy = %v, want %v", repr.Privacy, tt.privacy)
+			}
+			if repr.EmbeddedIPv4 != tt.embedded {
+				t.Errorf("embedded = %q, want %q", repr.EmbeddedIPv4, tt.embedded)
+			}
+		})
+	}
+}
+
+func TestPTRName_IPv6(t *testing.T) {
+	expected := "b.a.9.8.7.6.5.0.4.0.0.0.3.0.0.0.2.0.0.0.1.0.0.0.0.0.0.0.1.2.3.4.ip6.arpa."
+	if got := PTRName(netip.MustParseAddr("4321:0:1:2:3:4:567:89ab")); got != expected {
+		t.Errorf("PTRName = %s, want %s", got, expected)
+	}
+	if got := Represent(netip.MustParseAddr("2001:db8::1")).Expanded; got != "2001:0db8:0000:0000:0000:0000:0000:0001" {
+		t.Errorf("unexpected expanded form %s", got)
+	}
+}
@@ -0,0 +1,89 @@
+package netcalc
+
+import "net/netip"
+
+// Special is an entry of the IANA special-purpose address registries
+// (RFC 6890 and updates).
+type Special struct {
+	Prefix string `json:"prefix"`
+	Name   string `json:"name"`
+	RFC    string `json:"rfc"`
+	Global bool   `json:"global"`
+}
+
+type specialRange struct {
+	prefix netip.Prefix
+	info   Special
+}
+
+var specialRanges = buildSpecial([]Special{
+	{Prefix: "0.0.0.0/8", Name: "This network", RFC: "RFC 791"},
+	{Prefix: "10.0.0.0/8", Name: "Private-Use", RFC: "RFC 1918"},
+	{Prefix: "100.64.0.0/10", Name: "Shared Address Space", RFC: "RFC 6598"},
+	{Prefix: "127.0.0.0/8", Name: "Loopback", RFC: "RFC 1122"},
+	{Prefix: "169.254.0.0/16", Name: "Link Local", RFC: "RFC 3927"},
+	{Prefix: "172.16.0.0/12", Name: "Private-Use", RFC: "RFC 1918"},
+	{Prefix: "192.0.0.0/24", Name: "IETF Protocol Assignments", RFC: "RFC 6890"},
+	{Prefix: "192.0.2.0/24", Name: "Documentation (TEST-NET-1)", RFC: "RFC 5737"},
+	{Prefix: "192.31.196.0/24", Name: "AS112-v4", RFC: "RFC 7535", Global: true},
+	{Prefix: "192.52.193.0/24", Name: "AMT", RFC: "RFC 7450", Global: true},
+	{Prefix: "192.88.99.0/24", Name: "Deprecated 6to4 Relay Anycast", RFC: "RFC 7526"},
+	{Prefix: "192.168.0.0/16", Name: "Private-Use", RFC: "RFC 1918"},
+	{Prefix: "192.175.48.0/24", Name: "Direct Delegation AS112 Service", RFC: "RFC 7534", Global: true},
+	{Prefix: "198.18.0.0/15", Name: "Benchmarking", RFC: "RFC 2544"},
+	{Prefix: "198.51.100.0/24", Name: "Documentation (TEST-NET-2)", RFC: "RFC 5737"},
+	{Prefix: "203.0.113.0/24", Name: "Documentation (TEST-NET-3)", RFC: "RFC 5737"},
+	{Prefix: "224.0.0.0/4", Name: "Multicast", RFC: "RFC 5771"},
+	{Prefix: "240.0.0.0/4", Name: "Reserved", RFC: "RFC 1112"},
+	{Prefix: "255.255.255.255/32", Name: "Limited Broadcast", RFC: "RFC 919"},
+	{Prefix: "::/128", Name: "Unspecified Address", RFC: "RFC 4291"},
+	{Prefix: "::1/128", Name: "Loopback Address", RFC: "RFC 4291"},
+	{Prefix: "::ffff:0:0/96", Name: "IPv4-mapped Address", RFC: "RFC 4291"},
+	{Prefix: "64:ff9b::/96", Name: "IPv4-IPv6 Translation", RFC: "RFC 6052", Global: true},
+	{Prefix: "64:ff9b:1::/48", Name: "IPv4-IPv6 Local-Use Translation", RFC: "RFC 8215"},
+	{Prefix: "100::/64", Name: "Discard-Only Address Block", RFC: "RFC 6666"},
+	{Prefix: "2001::/23", Name: "IETF Protocol Assignments", RFC: "RFC 2928"},
+	{Prefix: "2001::/32", Name: "TEREDO", RFC: "RFC 4380", Global: true},
+	{Prefix: "2001:2::/48", Name: "Benchmarking", RFC: "RFC 5180"},
+	{Prefix: "2001:db8::/32", Name: "Documentation", RFC: "RFC 3849"},
+	{Prefix: "2001:20::/28", Name: "ORCHIDv2", RFC: "RFC 7343", Global: true},
+	{Prefix: "2002::/16", Name: "6to4", RFC: "RFC 3056", Global: true},
+	{Prefix: "3fff::/20", Name: "Documentation", RFC: "RFC 9637"},
+	{Prefix: "5f00::/16", Name: "Segment Routing (SRv6) SIDs", RFC: "RFC 9602"},
+	{Prefix: "fc00::/7", Name: "Unique-Local", RFC: "RFC 4193"},
+	{Prefix: "fe80::/10", Name: "Link-Local Unicast", RFC: "RFC 4291"},
+	{Prefix: "ff00::/8", Name: "Multicast", RFC: "RFC 4291"},
+})
+
+func buildSpecial(entries []Special) []specialRange {
+	ranges := make([]specialRange, 0, len(entries))
+	for _, entry := range entries {
+		ranges = append(ranges, specialRange{prefix: netip.MustParsePrefix(entry.Prefix), info: entry})
+	}
+	return ranges
+}
+
+// Classify returns the special-purpose ranges that overlap prefix, either
+// containing it or lying inside it.
+func Classify(prefix netip.Prefix) []Special {
+	var matches []Special
+	for _, r := range specialRanges {
+		if r.prefix.Overlaps(prefix) {
+			matches = append(matches, r.info)
+		}
+	}
+	return matches
+}
+
+// IsGlobal reports whether addr is globally routable according to the most
+// specific special-purpose range containing it.
+func IsGlobal(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	global, bits := true, -1
+	for _, r := range specialRanges {
+		if r.prefix.Contains(addr) && r.prefix.Bits() > bits {
+			global, bits = r.info.Global, r.prefix.Bits()
+		}
+	}
+	return global
+}
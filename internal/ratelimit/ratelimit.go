@@ -0,0 +1,249 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Class separates request kinds that have their own limits.
+type Class string
+
+// Request classes with independent buckets.
+const (
+	ClassPage   Class = "page"
+	ClassAPI    Class = "api"
+	ClassLookup Class = "lookup"
+)
+
+const ipv6PrefixBits = 64
+
+// Limit is a token bucket: Burst requests per Period, refilled evenly.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Rate returns the refill rate in tokens per second.
+func (l Limit) Rate() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// Enabled reports whether the limit is configured.
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Period > 0
+}
+
+// ParseLimit parses values like "60/m", "10/s" or "1000/h". An empty value
+// disables the limit.
+func ParseLimit(raw string) (Limit, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Limit{}, nil
+	}
+
+	count, unit, ok := strings.Cut(raw, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected N/s, N/m or N/h", raw)
+	}
+	burst, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || burst <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit count %q", count)
+	}
+
+	var period time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	case "d":
+		period = 24 * time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid limit unit %q", unit)
+	}
+	return Limit{Burst: burst, Period: period}, nil
+}
+
+// ParseAllowlist parses IP addresses and CIDR prefixes.
+func ParseAllowlist(items []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("parse allowlist entry %q: %w", item, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("parse allowlist entry %q: %w", item, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Backend stores token buckets shared across instances.
+type Backend interface {
+	TakeToken(ctx context.Context, key string, rate float64, burst, n int) (bool, float64, error)
+}
+
+// Rules configures the limits per request class.
+type Rules struct {
+	Limits    map[Class]Limit
+	Allowlist []netip.Prefix
+}
+
+// Result is the outcome of a single limit check.
+type Result struct {
+	Limited    bool
+	Allowed    bool
+	Limit      Limit
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter checks requests against per-client token buckets.
+type Limiter struct {
+	backend Backend
+	rules   Rules
+}
+
+// New creates a Limiter.
+func New(backend Backend, rules Rules) *Limiter {
+	return &Limiter{backend: backend, rules: rules}
+}
+
+// Allow takes a token for the client IP in the given class. Allowlisted
+// clients and classes without a limit are always allowed and report
+// Limited=false.
+func (l *Limiter) Allow(ctx context.Context, class Class, ip string) (Result, error) {
+	return l.AllowN(ctx, class, ip, 1)
+}
+
+// AllowN takes n tokens at once, for requests that cost several lookups.
+// Nothing is taken when fewer than n tokens are left; n above the burst is
+// never allowed, so check Capacity first.
+func (l *Limiter) AllowN(ctx context.Context, class Class, ip string, n int) (Result, error) {
+	limit, addr, ok := l.limited(class, ip)
+	if !ok {
+		return Result{Allowed: true}, nil
+	}
+	return l.take(ctx, limit, string(class)+":"+ClientKey(addr), n)
+}
+
+// AllowKey is AllowN for a caller authenticated with an API key: the
+// tokens come from the bucket of key ID id, shared by every address the
+// key is used from, instead of the address bucket. Allowlisted addresses
+// are still not limited.
+func (l *Limiter) AllowKey(ctx context.Context, class Class, ip, id string, n int) (Result, error) {
+	limit, _, ok := l.limited(class, ip)
+	if !ok {
+		return Result{Allowed: true}, nil
+	}
+	return l.take(ctx, limit, string(class)+":key:"+id, n)
+}
+
+// take takes n tokens from bucket.
+func (l *Limiter) take(ctx context.Context, limit Limit, bucket string, n int) (Result, error) {
+	rate := limit.Rate()
+	allowed, tokens, err := l.backend.TakeToken(ctx, bucket, rate, limit.Burst, n)
+	if err != nil {
+		return Result{Allowed: true}, err
+	}
+
+	result := Result{
+		Limited:   true,
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((float64(n) - tokens) / rate)
+	}
+	return result, nil
+}
+
+// Capacity returns the most tokens a single AllowN for ip in class can
+// take. ok is false when ip is not limited in class at all.
+func (l *Limiter) Capacity(class Class, ip string) (int, bool) {
+	limit, _, ok := l.limited(class, ip)
+	return limit.Burst, ok
+}
+
+// limited returns the limit that applies to ip in class, if any.
+func (l *Limiter) limited(class Class, ip string) (Limit, netip.Addr, bool) {
+	limit := l.rules.Limits[class]
+	if !limit.Enabled() {
+		return Limit{}, netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Limit{}, netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+	if l.allowlisted(addr) {
+		return Limit{}, netip.Addr{}, false
+	}
+	return limit, addr, true
+}
+
+func (l *Limiter) allowlisted(addr netip.Addr) bool {
+	for _, prefix := range l.rules.Allowlist {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientKey groups IPv6 clients by their /64 since a single host usually
+// controls the whole prefix.
+func ClientKey(addr netip.Addr) string {
+	if addr.Is6() {
+		prefix, err := addr.Prefix(ipv6PrefixBits)
+		if err == nil {
+			return prefix.String()
+		}
+	}
+	return addr.String()
+}
+
+// SetHeaders writes RateLimit-* and, for rejected requests, Retry-After.
+func (r Result) SetHeaders(h http.Header) {
+	if !r.Limited {
+		return
+	}
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", r.Limit.Burst, int(r.Limit.Period.Seconds())))
+	h.Set("RateLimit-Limit", strconv.Itoa(r.Limit.Burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(int(r.Reset.Seconds())))
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.Itoa(int(r.RetryAfter.Seconds())))
+	}
+}
+
+// seconds rounds up to whole seconds so clients never retry too early.
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(s)) * time.Second
+}
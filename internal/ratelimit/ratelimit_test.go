@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+type memoryBackend struct {
+	tokens map[string]float64
+}
+
+func (m *memoryBackend) TakeToken(ctx context.Context, key string, rate float64, burst, n int) (bool, float64, error) {
+	tokens, ok := m.tokens[key]
+	if !ok {
+		tokens = float64(burst)
+	}
+	if tokens < float64(n) {
+		return false, tokens, nil
+	}
+	m.tokens[key] = tokens - float64(n)
+	return true, tokens - float64(n), nil
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("60/m")
+	if err != nil {
+		t.Fatalf("ParseLimit failed: %v", err)
+	}
+	if limit.Burst != 60 || limit.Period != time.Minute {
+		t.Errorf("unexpected limit %+v", limit)
+	}
+	if limit.Rate() != 1 {
+		t.Errorf("expected rate 1/s, got %v", limit.Rate())
+	}
+
+	if limit, err := ParseLimit(""); err != nil || limit.Enabled() {
+		t.Errorf("expected empty limit to be disabled, got %+v, %v", limit, err)
+	}
+	for _, raw := range []string{"60", "x/m", "10/w", "0/s"} {
+		if _, err := ParseLimit(raw); err == nil {
+			t.Errorf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	backend := &memoryBackend{tokens: map[string]float64{}}
+	allowlist, err := ParseAllowlist([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := New(backend, Rules{
+		Limits:    map[Class]Limit{ClassLookup: {Burst: 2, Period: time.Minute}},
+		Allowlist: allowlist,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		result, err := limiter.Allow(ctx, ClassLookup, "1.2.3.4")
+		if err != nil || !result.Allowed {
+			t.Fatalf("request %d: expected allowed, got %+v, %v", i, result, err)
+		}
+	}
+	result, _ := limiter.Allow(ctx, ClassLookup, "1.2.3.4")
+	if result.Allowed {
+		t.Fatal("expected third request to be limited")
+	}
+	if result.RetryAfter != 30*time.Second {
+		t.Errorf("expected Retry-After 30s, got %v", result.RetryAfter)
+	}
+
+	if result, _ := limiter.AllowN(ctx, ClassLookup, "5.6.7.8", 3); result.Allowed {
+		t.Error("expected a cost above the burst to be limited")
+	}
+	if result, _ := limiter.AllowN(ctx, ClassLookup, "5.6.7.8", 2); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected a cost within the burst to take every token, got %+v", result)
+	}
+
+	if burst, ok := limiter.Capacity(ClassLookup, "5.6.7.8"); !ok || burst != 2 {
+		t.Errorf("expected lookup capacity 2, got %d %v", burst, ok)
+	}
+	if _, ok := limiter.Capacity(ClassLookup, "10.1.2.3"); ok {
+		t.Error("expected no capacity limit for an allowlisted client")
+	}
+
+	if result, _ := limiter.AllowKey(ctx, ClassLookup, "1.2.3.4", "abc", 2); !result.Allowed {
+		t.Errorf("expected a key to have its own bucket, got %+v", result)
+	}
+	if result, _ := limiter.AllowKey(ctx, ClassLookup, "9.9.9.9", "abc", 1); result.Allowed {
+		t.Error("expected the key bucket to be shared across addresses")
+	}
+	if result, _ := limiter.AllowKey(ctx, ClassLookup, "10.1.2.3", "abc", 1); !result.Allowed || result.Limited {
+		t.Errorf("expected an allowlisted address not to be limited, got %+v", result)
+	}
+
+	if result, _ := limiter.Allow(ctx, ClassPage, "1.2.3.4"); !result.Allowed || result.Limited {
+		t.Errorf("expected unlimited page class, got %+v", result)
+	}
+	for i := 0; i < 5; i++ {
+		if result, _ := limiter.Allow(ctx, ClassLookup, "10.1.2.3"); !result.Allowed {
+			t.Fatal("expected allowlisted client to pass")
+		}
+	}
+}
+
+func TestClientKey_IPv6Prefix(t *testing.T) {
+	a := ClientKey(netip.MustParseAddr("2001:db8:1:2::1"))
+	b := ClientKey(netip.MustParseAddr("2001:db8:1:2:ffff::1"))
+	if a != b || a != "2001:db8:1:2::/64" {
+		t.Errorf("expected shared /64 key, got %s and %s", a, b)
+	}
+	if got := ClientKey(netip.MustParseAddr("1.2.3.4")); got != "1.2.3.4" {
+		t.Errorf("expected IPv4 key 1.2.3.4, got %s", got)
+	}
+}
+
+func TestResult_SetHeaders(t *testing.T) {
+	h := http.Header{}
+	Result{
+		Limited:    true,
+		Limit:      Limit{Burst: 10, Period: time.Minute},
+		Remaining:  0,
+		Reset:      60 * time.Second,
+		RetryAfter: 6 * time.Second,
+	}.SetHeaders(h)
+
+	expected := map[string]string{
+		"RateLimit-Policy":    "10;w=60",
+		"RateLimit-Limit":     "10",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+		"Retry-After":         "6",
+	}
+	for key, value := range expected {
+		if got := h.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Autnum represents selected fields of an RDAP autnum object.
+type Autnum struct {
+	Handle  string `json:"handle"`
+	Name    string `json:"name"`
+	Org     string `json:"org"`
+	Country string `json:"country"`
+}
+
+type autnumResponse struct {
+	Handle   string       `json:"handle"`
+	Name     string       `json:"name"`
+	Country  string       `json:"country"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+type rdapEntity struct {
+	Roles      []string          `json:"roles"`
+	VCardArray []json.RawMessage `json:"vcardArray"`
+}
+
+// AutnumClient fetches RDAP information for autonomous systems.
+type AutnumClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewAutnumClient creates a client using a URL template containing {ASN}.
+func NewAutnumClient(baseURL string) *AutnumClient {
+	return &AutnumClient{
+		baseURL: baseURL,
+		http: &http.Client{
+			Timeout: requestTimeout,
+		},
+	}
+}
+
+// Lookup fetches RDAP information for the given AS number.
+func (c *AutnumClient) Lookup(ctx context.Context, asn uint32) (Autnum, error) {
+	url := strings.ReplaceAll(c.baseURL, "{ASN}", strconv.FormatUint(uint64(asn), 10))
+	if url == c.baseURL {
+		return Autnum{}, fmt.Errorf("RDAP autnum template missing {ASN}")
+	}
+
+	var payload autnumResponse
+	if err := fetchJSON(ctx, c.http, url, &payload); err != nil {
+		return Autnum{}, err
+	}
+
+	info := Autnum{Handle: payload.Handle, Name: payload.Name, Country: payload.Country}
+	for _, entity := range payload.Entities {
+		if slices.Contains(entity.Roles, "registrant") {
+			info.Org = entity.fullName()
+			break
+		}
+	}
+	return info, nil
+}
+
+// fullName returns the "fn" property of the entity's jCard.
+func (e rdapEntity) fullName() string {
+	if len(e.VCardArray) != 2 {
+		return ""
+	}
+	var properties [][]json.RawMessage
+	if err := json.Unmarshal(e.VCardArray[1], &properties); err != nil {
+		return ""
+	}
+	for _, property := range properties {
+		if len(property) < 4 {
+			continue
+		}
+		var name, value string
+		if json.Unmarshal(property[0], &name) != nil || name != "fn" {
+			continue
+		}
+		if json.Unmarshal(property[3], &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
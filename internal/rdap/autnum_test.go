@@ -0,0 +1,37 @@
+package rdap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAutnumClient_Lookup(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/autnum/15169" {
+			t.Errorf("expected path /autnum/15169, got %s", r.URL.Path)
+		}
+		w.Write([]byte(`{
+			"handle": "AS15169",
+			"name": "GOOGLE",
+			"entities": [
+				{"roles": ["technical"], "vcardArray": ["vcard", [["fn", {}, "text", "Google NOC"]]]},
+				{"roles": ["registrant"], "vcardArray": ["vcard", [
+					["version", {}, "text", "4.0"],
+					["fn", {}, "text", "Google LLC"]
+				]]}
+			]
+		}`))
+	}))
+	defer ts.Close()
+
+	client := NewAutnumClient(ts.URL + "/autnum/{ASN}")
+	info, err := client.Lookup(context.Background(), 15169)
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if info.Handle != "AS15169" || info.Name != "GOOGLE" || info.Org != "Google LLC" {
+		t.Errorf("unexpected autnum %+v", info)
+	}
+}
@@ -62,24 +62,9 @@ func (c *Client) Lookup(ctx context.Context, ip string) (Info, error) {
 		return Info{}, fmt.Errorf("RDAP API template missing {REMOTE_IP}")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return Info{}, fmt.Errorf("create request: %w", err)
-	}
-
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return Info{}, fmt.Errorf("do request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return Info{}, fmt.Errorf("unexpected status: %s", resp.Status)
-	}
-
 	var payload rdapResponse
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return Info{}, fmt.Errorf("decode response: %w", err)
+	if err := fetchJSON(ctx, c.http, url, &payload); err != nil {
+		return Info{}, err
 	}
 
 	info := Info{
@@ -100,3 +85,26 @@ func (c *Client) Lookup(ctx context.Context, ip string) (Info, error) {
 
 	return info, nil
 }
+
+// fetchJSON performs a GET request and decodes the JSON body into v.
+func fetchJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
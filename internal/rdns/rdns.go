@@ -0,0 +1,155 @@
+package rdns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"myip/internal/dns"
+	"myip/internal/netcalc"
+)
+
+const (
+	minTTL      = time.Minute
+	maxTTL      = 24 * time.Hour
+	negativeTTL = 5 * time.Minute
+	// maxNames bounds forward lookups for hosts with many PTR records.
+	maxNames = 3
+)
+
+// Result is the reverse DNS view of an address.
+type Result struct {
+	Hostname string   `json:"hostname,omitempty"`
+	Names    []string `json:"names,omitempty"`
+	FCrDNS   bool     `json:"fcrdns"`
+}
+
+// Querier sends a single DNS query.
+type Querier interface {
+	Query(ctx context.Context, name string, qtype uint16) (*dns.Message, error)
+}
+
+// Cache stores results as JSON values with a TTL.
+type Cache interface {
+	GetValue(ctx context.Context, key string, v any) (bool, error)
+	SetValue(ctx context.Context, key string, v any, ttl time.Duration) error
+}
+
+// Resolver performs PTR and forward-confirmed reverse DNS lookups.
+type Resolver struct {
+	client Querier
+	cache  Cache
+}
+
+// NewResolver creates a resolver. cache may be nil.
+func NewResolver(client Querier, cache Cache) *Resolver {
+	return &Resolver{client: client, cache: cache}
+}
+
+// Lookup returns the PTR names of ip and whether one of them resolves back
+// to ip. Results are cached for the smallest TTL seen in the answers. A
+// cache error is returned together with a valid result.
+func (r *Resolver) Lookup(ctx context.Context, ip string) (Result, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Result{}, fmt.Errorf("rdns: invalid address %q", ip)
+	}
+	addr = addr.Unmap()
+
+	key := cacheKey(addr)
+	var cacheErr error
+	if r.cache != nil {
+		var cached Result
+		ok, err := r.cache.GetValue(ctx, key, &cached)
+		if err != nil {
+			cacheErr = err
+		} else if ok {
+			return cached, nil
+		}
+	}
+
+	result, ttl, err := r.resolve(ctx, addr)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if r.cache != nil && cacheErr == nil {
+		cacheErr = r.cache.SetValue(ctx, key, result, clampTTL(ttl))
+	}
+	// A cache failure still yields a usable result.
+	return result, cacheErr
+}
+
+func (r *Resolver) resolve(ctx context.Context, addr netip.Addr) (Result, time.Duration, error) {
+	resp, err := r.client.Query(ctx, netcalc.PTRName(addr), dns.TypePTR)
+	if err != nil {
+		return Result{}, 0, fmt.Errorf("rdns: ptr query: %w", err)
+	}
+	if resp.Rcode == dns.RcodeNXDomain {
+		return Result{}, negativeCacheTTL(resp), nil
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return Result{}, 0, fmt.Errorf("rdns: ptr query rcode %d", resp.Rcode)
+	}
+
+	var result Result
+	ttl := maxTTL
+	for _, rr := range resp.Answers {
+		if rr.Type == dns.TypePTR {
+			result.Names = append(result.Names, rr.Target)
+			ttl = min(ttl, time.Duration(rr.TTL)*time.Second)
+		}
+	}
+	if len(result.Names) == 0 {
+		return result, negativeCacheTTL(resp), nil
+	}
+	result.Hostname = result.Names[0]
+
+	qtype := dns.TypeAAAA
+	if addr.Is4() {
+		qtype = dns.TypeA
+	}
+	for i, name := range result.Names {
+		if i >= maxNames {
+			break
+		}
+		forward, err := r.client.Query(ctx, name, qtype)
+		if err != nil {
+			continue
+		}
+		for _, rr := range forward.Answers {
+			if rr.Type != qtype {
+				continue
+			}
+			ttl = min(ttl, time.Duration(rr.TTL)*time.Second)
+			if rr.Addr.Unmap() == addr {
+				result.FCrDNS = true
+				result.Hostname = name
+			}
+		}
+		if result.FCrDNS {
+			break
+		}
+	}
+	return result, ttl, nil
+}
+
+// negativeCacheTTL follows RFC 2308: the SOA minimum from the authority
+// section, capped by the SOA record TTL.
+func negativeCacheTTL(resp *dns.Message) time.Duration {
+	for _, rr := range resp.Authority {
+		if rr.Type == dns.TypeSOA && rr.SOA != nil {
+			return time.Duration(min(rr.TTL, rr.SOA.Minimum)) * time.Second
+		}
+	}
+	return negativeTTL
+}
+
+func clampTTL(ttl time.Duration) time.Duration {
+	return max(minTTL, min(ttl, maxTTL))
+}
+
+func cacheKey(addr netip.Addr) string {
+	return "rdns:" + addr.String()
+}
@@ -0,0 +1,118 @@
+package rdns
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"myip/internal/dns"
+)
+
+type memoryCache struct {
+	values map[string][]byte
+	ttls   map[string]time.Duration
+}
+
+func (m *memoryCache) GetValue(ctx context.Context, key string, v any) (bool, error) {
+	data, ok := m.values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (m *memoryCache) SetValue(ctx context.Context, key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	m.values[key] = data
+	m.ttls[key] = ttl
+	return err
+}
+
+// serveDNS answers queries from a fixed record set until the test ends.
+func serveDNS(t *testing.T, records map[dns.Question][]dns.RR) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query, err := dns.Unpack(buf[:n])
+			if err != nil {
+				continue
+			}
+			q := query.Questions[0]
+			resp := &dns.Message{Header: dns.Header{ID: query.ID, Response: true}, Questions: query.Questions}
+			answers, ok := records[q]
+			if !ok {
+				resp.Rcode = dns.RcodeNXDomain
+			}
+			resp.Answers = answers
+			packed, _ := resp.Pack()
+			conn.WriteTo(packed, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestResolver_Lookup(t *testing.T) {
+	ptr := func(name, target string, ttl uint32) dns.RR {
+		return dns.RR{Name: name, Type: dns.TypePTR, Class: dns.ClassINET, TTL: ttl, Target: target}
+	}
+	a := func(name, ip string, ttl uint32) dns.RR {
+		return dns.RR{Name: name, Type: dns.TypeA, Class: dns.ClassINET, TTL: ttl, Addr: netip.MustParseAddr(ip)}
+	}
+	q := func(name string, qtype uint16) dns.Question {
+		return dns.Question{Name: name, Type: qtype, Class: dns.ClassINET}
+	}
+
+	server := serveDNS(t, map[dns.Question][]dns.RR{
+		q("4.3.2.1.in-addr.arpa.", dns.TypePTR): {ptr("4.3.2.1.in-addr.arpa.", "host.example.", 3600)},
+		q("host.example.", dns.TypeA):           {a("host.example.", "1.2.3.4", 600)},
+		q("8.8.8.8.in-addr.arpa.", dns.TypePTR): {ptr("8.8.8.8.in-addr.arpa.", "spoofed.example.", 3600)},
+		q("spoofed.example.", dns.TypeA):        {a("spoofed.example.", "5.6.7.8", 3600)},
+	})
+
+	cache := &memoryCache{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+	resolver := NewResolver(dns.NewClient(server), cache)
+	ctx := context.Background()
+
+	result, err := resolver.Lookup(ctx, "1.2.3.4")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if result.Hostname != "host.example." || !result.FCrDNS {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if ttl := cache.ttls["rdns:1.2.3.4"]; ttl != 10*time.Minute {
+		t.Errorf("expected cache TTL 10m from the A record, got %v", ttl)
+	}
+
+	result, err = resolver.Lookup(ctx, "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if result.Hostname != "spoofed.example." || result.FCrDNS {
+		t.Errorf("expected unconfirmed hostname, got %+v", result)
+	}
+
+	result, err = resolver.Lookup(ctx, "9.9.9.9")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if result.Hostname != "" {
+		t.Errorf("expected no hostname for NXDOMAIN, got %+v", result)
+	}
+	if ttl := cache.ttls["rdns:9.9.9.9"]; ttl != negativeTTL {
+		t.Errorf("expected negative TTL %v, got %v", negativeTTL, ttl)
+	}
+}
@@ -0,0 +1,144 @@
+package risk
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Signals are the inputs of the risk rules. Server-side fields come from
+// the request and the IP enrichment, client-side fields are reported by
+// the page.
+type Signals struct {
+	ClientIP string
+	PeerIP   string
+	// PeerTrusted is set when the connection comes from a configured
+	// proxy. ProxyHops are the forwarding hops no trusted proxy vouches
+	// for.
+	PeerTrusted  bool
+	ProxyHops    []string
+	ForwardedFor []string
+	Via          string
+	Forwarded    string
+
+	RDAPName        string
+	RDAPType        string
+	HostingProvider string
+	Tor             bool
+	Blocklisted     bool
+	IPCountry       string
+	IPTimeZone      string
+
+	BrowserTimeZone string
+	Languages       []string
+	WebRTCIPs       []string
+}
+
+// Rule is a single weighted check. Check reports whether the rule fired
+// and a human readable explanation either way.
+type Rule struct {
+	Name   string
+	Weight int
+	Check  func(Signals) (bool, string)
+}
+
+// Finding is the outcome of one rule.
+type Finding struct {
+	Rule      string `json:"rule"`
+	Weight    int    `json:"weight"`
+	Triggered bool   `json:"triggered"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// Report is the combined score with its explanation.
+type Report struct {
+	Score    int       `json:"score"`
+	Level    string    `json:"level"`
+	Findings []Finding `json:"signals"`
+}
+
+// Engine evaluates a set of rules.
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine creates an engine with the default rules, overriding their
+// weights (0-100) by rule name. A weight of 0 disables a rule.
+func NewEngine(weights map[string]int) (*Engine, error) {
+	rules := DefaultRules()
+	index := make(map[string]int, len(rules))
+	for i, rule := range rules {
+		index[rule.Name] = i
+	}
+	for name, weight := range weights {
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown risk rule %q", name)
+		}
+		if weight < 0 || weight > 100 {
+			return nil, fmt.Errorf("risk weight for %s must be between 0 and 100", name)
+		}
+		rules[i].Weight = weight
+	}
+	return &Engine{rules: rules}, nil
+}
+
+// ParseWeights converts "rule=weight" pairs into weights.
+func ParseWeights(pairs map[string]string) (map[string]int, error) {
+	weights := make(map[string]int, len(pairs))
+	for name, raw := range pairs {
+		weight, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("risk weight for %s: %w", name, err)
+		}
+		weights[name] = weight
+	}
+	return weights, nil
+}
+
+// Evaluate runs all enabled rules. Weights are treated as independent
+// probabilities, so the score grows with every triggered rule but never
+// exceeds 100.
+func (e *Engine) Evaluate(s Signals) Report {
+	report := Report{Findings: make([]Finding, 0, len(e.rules))}
+	clean := 1.0
+	for _, rule := range e.rules {
+		if rule.Weight == 0 {
+			continue
+		}
+		triggered, detail := rule.Check(s)
+		report.Findings = append(report.Findings, Finding{
+			Rule: rule.Name, Weight: rule.Weight, Triggered: triggered, Detail: detail,
+		})
+		if triggered {
+			clean *= 1 - float64(rule.Weight)/100
+		}
+	}
+
+	// Show the strongest reasons first.
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Triggered != b.Triggered {
+			return a.Triggered
+		}
+		return a.Weight > b.Weight
+	})
+
+	report.Score = int(math.Round(100 * (1 - clean)))
+	report.Level = level(report.Score)
+	return report
+}
+
+func level(score int) string {
+	switch {
+	case score >= 75:
+		return "high"
+	case score >= 40:
+		return "medium"
+	case score > 0:
+		return "low"
+	default:
+		return "none"
+	}
+}
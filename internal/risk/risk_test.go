@@ -0,0 +1,59 @@
+package risk
+
+import "testing"
+
+func TestEngine_Evaluate(t *testing.T) {
+	engine, err := NewEngine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := engine.Evaluate(Signals{ClientIP: "203.0.113.1"})
+	if report.Score != 0 || report.Level != "none" {
+		t.Errorf("expected clean report, got %+v", report)
+	}
+
+	report = engine.Evaluate(Signals{ClientIP: "203.0.113.1", HostingProvider: "AWS", Blocklisted: true})
+	// 1 - (1-0.45)*(1-0.20) = 0.56
+	if report.Score != 56 || report.Level != "medium" {
+		t.Errorf("expected score 56, got %+v", report)
+	}
+	if first := report.Findings[0]; first.Rule != "hosting_range" || !first.Triggered {
+		t.Errorf("expected strongest triggered rule first, got %+v", first)
+	}
+
+	report = engine.Evaluate(Signals{Tor: true, HostingProvider: "AWS", Via: "1.1 proxy"})
+	if report.Score > 100 || report.Level != "high" {
+		t.Errorf("expected capped high score, got %+v", report)
+	}
+}
+
+func TestNewEngine_Weights(t *testing.T) {
+	weights, err := ParseWeights(map[string]string{"tor": "100", "dnsbl": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewEngine(weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := engine.Evaluate(Signals{Tor: true, Blocklisted: true})
+	if report.Score != 100 {
+		t.Errorf("expected score 100, got %d", report.Score)
+	}
+	for _, f := range report.Findings {
+		if f.Rule == "dnsbl" {
+			t.Error("expected disabled rule to be skipped")
+		}
+	}
+
+	if _, err := NewEngine(map[string]int{"nope": 10}); err == nil {
+		t.Error("expected error for unknown rule")
+	}
+	if _, err := NewEngine(map[string]int{"tor": 120}); err == nil {
+		t.Error("expected error for out of range weight")
+	}
+	if _, err := ParseWeights(map[string]string{"tor": "high"}); err == nil {
+		t.Error("expected error for non-numeric weight")
+	}
+}
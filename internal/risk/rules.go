@@ -0,0 +1,180 @@
+package risk
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+	// Browsers report IANA zone names; embed the database so comparisons
+	// work on hosts without /usr/share/zoneinfo.
+	_ "time/tzdata"
+)
+
+// hostingKeywords mark RDAP network names and types of hosting, cloud and
+// VPN operators.
+var hostingKeywords = []string{
+	"hosting", "datacenter", "data center", "data-center", "server", "cloud",
+	"vps", "colo", "dedicated", "vpn", "proxy",
+}
+
+// DefaultRules returns the built-in rules with their default weights.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "tor", Weight: 90, Check: checkTor},
+		{Name: "webrtc_leak", Weight: 60, Check: checkWebRTCLeak},
+		{Name: "hosting_range", Weight: 45, Check: checkHostingRange},
+		{Name: "peer_mismatch", Weight: 40, Check: checkPeerMismatch},
+		{Name: "rdap_keywords", Weight: 30, Check: checkRDAPKeywords},
+		{Name: "forwarding_headers", Weight: 30, Check: checkForwardingHeaders},
+		{Name: "timezone_mismatch", Weight: 30, Check: checkTimeZone},
+		{Name: "dnsbl", Weight: 20, Check: checkBlocklisted},
+		{Name: "language_mismatch", Weight: 15, Check: checkLanguage},
+	}
+}
+
+func checkTor(s Signals) (bool, string) {
+	if s.Tor {
+		return true, "address is a Tor exit node"
+	}
+	return false, "not a known Tor exit"
+}
+
+func checkHostingRange(s Signals) (bool, string) {
+	if s.HostingProvider != "" {
+		return true, "address belongs to " + s.HostingProvider
+	}
+	return false, "not in a known cloud or hosting range"
+}
+
+func checkBlocklisted(s Signals) (bool, string) {
+	if s.Blocklisted {
+		return true, "address is on a DNS blocklist"
+	}
+	return false, "not blocklisted"
+}
+
+func checkRDAPKeywords(s Signals) (bool, string) {
+	text := strings.ToLower(s.RDAPName + " " + s.RDAPType)
+	for _, keyword := range hostingKeywords {
+		if strings.Contains(text, keyword) {
+			return true, fmt.Sprintf("RDAP network %q mentions %q", strings.TrimSpace(s.RDAPName+" "+s.RDAPType), keyword)
+		}
+	}
+	return false, "RDAP network looks like an access provider"
+}
+
+// checkForwardingHeaders looks for proxies in front of the client. A single
+// X-Forwarded-For entry is what our own reverse proxy adds.
+func checkForwardingHeaders(s Signals) (bool, string) {
+	var found []string
+	if len(s.ForwardedFor) > 1 {
+		found = append(found, fmt.Sprintf("X-Forwarded-For with %d hops", len(s.ForwardedFor)))
+	}
+	if s.Via != "" {
+		found = append(found, "Via: "+s.Via)
+	}
+	if s.Forwarded != "" {
+		found = append(found, "Forwarded: "+s.Forwarded)
+	}
+	if len(found) > 0 {
+		return true, strings.Join(found, "; ")
+	}
+	return false, "no proxy headers"
+}
+
+// checkPeerMismatch fires when the request passed through a proxy that is
+// not configured as trusted: an untrusted peer forwarding for someone, or
+// hops a trusted proxy received from further upstream. A trusted proxy
+// such as the site's own CDN never fires it by itself.
+func checkPeerMismatch(s Signals) (bool, string) {
+	if len(s.ProxyHops) == 0 {
+		if s.PeerTrusted {
+			return false, "request arrived through a trusted proxy"
+		}
+		return false, "connection comes directly from the client"
+	}
+	hops := strings.Join(s.ProxyHops, ", ")
+	if s.PeerTrusted {
+		return true, fmt.Sprintf("client %s was forwarded for %s by an unknown proxy", s.ClientIP, hops)
+	}
+	return true, fmt.Sprintf("connection from %s forwards for %s", s.PeerIP, hops)
+}
+
+func checkTimeZone(s Signals) (bool, string) {
+	if s.BrowserTimeZone == "" || s.IPTimeZone == "" {
+		return false, "timezone not available"
+	}
+	if s.BrowserTimeZone == s.IPTimeZone {
+		return false, "browser timezone matches IP location"
+	}
+	browser, err := time.LoadLocation(s.BrowserTimeZone)
+	if err != nil {
+		return true, fmt.Sprintf("unknown browser timezone %q", s.BrowserTimeZone)
+	}
+	ip, err := time.LoadLocation(s.IPTimeZone)
+	if err != nil {
+		return false, "IP timezone unknown"
+	}
+	now := time.Now()
+	_, browserOffset := now.In(browser).Zone()
+	_, ipOffset := now.In(ip).Zone()
+	if browserOffset == ipOffset {
+		return false, fmt.Sprintf("browser timezone %s has the same offset as %s", s.BrowserTimeZone, s.IPTimeZone)
+	}
+	return true, fmt.Sprintf("browser timezone %s, IP located in %s", s.BrowserTimeZone, s.IPTimeZone)
+}
+
+// checkWebRTCLeak compares public ICE candidates of the client's address
+// family with the address the request came from.
+func checkWebRTCLeak(s Signals) (bool, string) {
+	client, err := netip.ParseAddr(s.ClientIP)
+	if err != nil {
+		return false, "client address unknown"
+	}
+	client = client.Unmap()
+
+	var other []string
+	for _, raw := range s.WebRTCIPs {
+		addr, err := netip.ParseAddr(raw)
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap()
+		if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.Is4() != client.Is4() {
+			continue
+		}
+		if addr == client {
+			return false, "WebRTC address matches the request address"
+		}
+		other = append(other, addr.String())
+	}
+	if len(other) > 0 {
+		return true, "WebRTC exposes " + strings.Join(other, ", ")
+	}
+	return false, "no public WebRTC address"
+}
+
+// checkLanguage compares region subtags of the browser languages with the
+// IP country. Languages without a region say nothing about location.
+func checkLanguage(s Signals) (bool, string) {
+	if s.IPCountry == "" {
+		return false, "IP country unknown"
+	}
+	var regions []string
+	for _, tag := range s.Languages {
+		parts := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+		for _, part := range parts[min(1, len(parts)):] {
+			if len(part) == 2 {
+				region := strings.ToUpper(part)
+				if region == strings.ToUpper(s.IPCountry) {
+					return false, "browser language region matches IP country " + region
+				}
+				regions = append(regions, region)
+			}
+		}
+	}
+	if len(regions) == 0 {
+		return false, "browser languages carry no region"
+	}
+	return true, fmt.Sprintf("browser language regions %s, IP country %s", strings.Join(regions, ", "), s.IPCountry)
+}
@@ -0,0 +1,99 @@
+package risk
+
+import "testing"
+
+type ruleCase struct {
+	name    string
+	signals Signals
+	want    bool
+}
+
+func runRule(t *testing.T, check func(Signals) (bool, string), cases []ruleCase) {
+	t.Helper()
+	for _, tc := range cases {
+		got, detail := check(tc.signals)
+		if got != tc.want {
+			t.Errorf("%s: got %v (%s), want %v", tc.name, got, detail, tc.want)
+		}
+		if detail == "" {
+			t.Errorf("%s: expected an explanation", tc.name)
+		}
+	}
+}
+
+func TestCheckTor(t *testing.T) {
+	runRule(t, checkTor, []ruleCase{
+		{"exit", Signals{Tor: true}, true},
+		{"regular", Signals{}, false},
+	})
+}
+
+func TestCheckHostingRange(t *testing.T) {
+	runRule(t, checkHostingRange, []ruleCase{
+		{"aws", Signals{HostingProvider: "AWS"}, true},
+		{"none", Signals{}, false},
+	})
+}
+
+func TestCheckBlocklisted(t *testing.T) {
+	runRule(t, checkBlocklisted, []ruleCase{
+		{"listed", Signals{Blocklisted: true}, true},
+		{"clean", Signals{}, false},
+	})
+}
+
+func TestCheckRDAPKeywords(t *testing.T) {
+	runRule(t, checkRDAPKeywords, []ruleCase{
+		{"hosting name", Signals{RDAPName: "HETZNER-HOSTING-NET"}, true},
+		{"datacenter type", Signals{RDAPName: "NET-1", RDAPType: "Data Center"}, true},
+		{"vpn", Signals{RDAPName: "M247-VPN-POOL"}, true},
+		{"isp", Signals{RDAPName: "DTAG-DIAL", RDAPType: "ASSIGNED PA"}, false},
+	})
+}
+
+func TestCheckForwardingHeaders(t *testing.T) {
+	runRule(t, checkForwardingHeaders, []ruleCase{
+		{"own proxy", Signals{ForwardedFor: []string{"203.0.113.1"}}, false},
+		{"chain", Signals{ForwardedFor: []string{"203.0.113.1", "198.51.100.7"}}, true},
+		{"via", Signals{Via: "1.1 squid"}, true},
+		{"forwarded", Signals{Forwarded: "for=203.0.113.1"}, true},
+		{"none", Signals{}, false},
+	})
+}
+
+func TestCheckPeerMismatch(t *testing.T) {
+	runRule(t, checkPeerMismatch, []ruleCase{
+		{"direct", Signals{PeerIP: "203.0.113.1", ClientIP: "203.0.113.1"}, false},
+		{"trusted proxy", Signals{PeerIP: "198.51.100.7", ClientIP: "203.0.113.1", PeerTrusted: true}, false},
+		{"untrusted proxy", Signals{PeerIP: "198.51.100.7", ClientIP: "198.51.100.7", ProxyHops: []string{"203.0.113.1"}}, true},
+		{"upstream proxy", Signals{PeerIP: "10.0.0.5", ClientIP: "198.51.100.7", PeerTrusted: true, ProxyHops: []string{"203.0.113.1"}}, true},
+	})
+}
+
+func TestCheckTimeZone(t *testing.T) {
+	runRule(t, checkTimeZone, []ruleCase{
+		{"same", Signals{BrowserTimeZone: "Europe/Berlin", IPTimeZone: "Europe/Berlin"}, false},
+		{"alias offset", Signals{BrowserTimeZone: "Europe/Kiev", IPTimeZone: "Europe/Kyiv"}, false},
+		{"different", Signals{BrowserTimeZone: "America/New_York", IPTimeZone: "Asia/Tokyo"}, true},
+		{"missing", Signals{BrowserTimeZone: "Asia/Tokyo"}, false},
+	})
+}
+
+func TestCheckWebRTCLeak(t *testing.T) {
+	runRule(t, checkWebRTCLeak, []ruleCase{
+		{"match", Signals{ClientIP: "203.0.113.1", WebRTCIPs: []string{"192.168.1.2", "203.0.113.1"}}, false},
+		{"leak", Signals{ClientIP: "203.0.113.1", WebRTCIPs: []string{"198.51.100.7"}}, true},
+		{"other family", Signals{ClientIP: "203.0.113.1", WebRTCIPs: []string{"2001:4860::1"}}, false},
+		{"private only", Signals{ClientIP: "203.0.113.1", WebRTCIPs: []string{"10.0.0.2", "abc.local"}}, false},
+	})
+}
+
+func TestCheckLanguage(t *testing.T) {
+	runRule(t, checkLanguage, []ruleCase{
+		{"match", Signals{IPCountry: "DE", Languages: []string{"en-US", "de-DE"}}, false},
+		{"mismatch", Signals{IPCountry: "NL", Languages: []string{"ru-RU", "ru"}}, true},
+		{"script subtag", Signals{IPCountry: "TW", Languages: []string{"zh-Hant-TW"}}, false},
+		{"no region", Signals{IPCountry: "FR", Languages: []string{"en"}}, false},
+		{"no country", Signals{Languages: []string{"en-US"}}, false},
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -14,6 +16,14 @@ import (
 const (
 	cacheTTL     = 7 * 24 * time.Hour
 	refreshAfter = 24 * time.Hour
+	usageTTL     = 35 * 24 * time.Hour
+
+	fingerprintTTL = 30 * 24 * time.Hour
+	// maxFingerprints bounds the per-IP index.
+	maxFingerprints = 20
+
+	// maxDNSLeakQueries bounds the queries kept per DNS leak visit.
+	maxDNSLeakQueries = 100
 )
 
 type cachedRDAP struct {
@@ -77,6 +87,34 @@ func (s *RedisStore) SetCached(ctx context.Context, ip string, info rdap.Info, f
 	return nil
 }
 
+// GetValue decodes the JSON value stored under key into v and reports
+// whether it was present.
+func (s *RedisStore) GetValue(ctx context.Context, key string, v any) (bool, error) {
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("get %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// SetValue stores v as JSON under key with the given TTL.
+func (s *RedisStore) SetValue(ctx context.Context, key string, v any, ttl time.Duration) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	if err := s.client.Set(ctx, key, payload, ttl).Err(); err != nil {
+		return fmt.Errorf("set %s: %w", key, err)
+	}
+	return nil
+}
+
 // NeedsRefresh reports whether cached data should be refreshed.
 func NeedsRefresh(fetchedAt time.Time) bool {
 	if fetchedAt.IsZero() {
@@ -94,6 +132,416 @@ func (s *RedisStore) IncrementCount(ctx context.Context, ip string) (int64, erro
 	return count, nil
 }
 
+// tokenBucketScript refills the bucket by elapsed time, takes n tokens if
+// available and returns {allowed, tokens left}. Tokens are returned as a
+// string because Lua numbers are truncated to integers on conversion.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= n then
+  tokens = tokens - n
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// TakeToken takes n tokens from the bucket stored under key, refilling at
+// rate tokens per second up to burst. It reports whether the tokens were
+// available and how many remain.
+func (s *RedisStore) TakeToken(ctx context.Context, key string, rate float64, burst, n int) (bool, float64, error) {
+	now := time.Now().UnixMilli()
+	result, err := tokenBucketScript.Run(ctx, s.client, []string{rateKey(key)}, rate, burst, now, n).Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("take token: %w", err)
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("take token: unexpected reply %v", result)
+	}
+
+	allowed, _ := result[0].(int64)
+	raw, _ := result[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("take token: parse tokens: %w", err)
+	}
+	return allowed == 1, tokens, nil
+}
+
+// FindAPIKey returns the key stored under the SHA-256 hash of its secret.
+func (s *RedisStore) FindAPIKey(ctx context.Context, hash string) (string, int64, bool, error) {
+	fields, err := s.client.HGetAll(ctx, apiKeyKey(hash)).Result()
+	if err != nil {
+		return "", 0, false, fmt.Errorf("get api key: %w", err)
+	}
+	if len(fields) == 0 {
+		return "", 0, false, nil
+	}
+
+	quota, err := strconv.ParseInt(fields["quota"], 10, 64)
+	if err != nil {
+		return "", 0, false, fmt.Errorf("parse api key quota: %w", err)
+	}
+	return fields["name"], quota, true, nil
+}
+
+// usageScript adds ARGV[1] to the usage counter KEYS[1] unless that would
+// take it above the quota ARGV[2] (0 is unlimited) and returns
+// {added, used}.
+var usageScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+local quota = tonumber(ARGV[2])
+local used = tonumber(redis.call('GET', KEYS[1]) or 0)
+if quota > 0 and used + n > quota then
+  return {0, used}
+end
+used = redis.call('INCRBY', KEYS[1], n)
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+return {1, used}
+`)
+
+// IncrementUsage increases the daily usage counter for an API key by n
+// unless that would exceed quota. It returns the resulting count and
+// whether n was added; a zero quota never refuses.
+func (s *RedisStore) IncrementUsage(ctx context.Context, id, day string, n, quota int64) (int64, bool, error) {
+	ttl := int64(usageTTL / time.Second)
+	result, err := usageScript.Run(ctx, s.client, []string{usageKey(id, day)}, n, quota, ttl).Int64Slice()
+	if err != nil {
+		return 0, false, fmt.Errorf("increment usage: %w", err)
+	}
+	if len(result) != 2 {
+		return 0, false, fmt.Errorf("increment usage: unexpected reply %v", result)
+	}
+	return result[1], result[0] == 1, nil
+}
+
+// GetUsage returns the usage counters of an API key for the given days.
+func (s *RedisStore) GetUsage(ctx context.Context, id string, days []string) ([]int64, error) {
+	keys := make([]string, 0, len(days))
+	for _, day := range days {
+		keys = append(keys, usageKey(id, day))
+	}
+
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get usage: %w", err)
+	}
+
+	usage := make([]int64, len(values))
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		usage[i], _ = strconv.ParseInt(raw, 10, 64)
+	}
+	return usage, nil
+}
+
+// SaveFingerprint stores an encoded fingerprint and indexes it under scope
+// by time. Only the newest maxFingerprints entries are kept in the index. It
+// reports whether id was not in the index yet.
+func (s *RedisStore) SaveFingerprint(ctx context.Context, scope, id string, data []byte, seenAt time.Time) (bool, error) {
+	index := fingerprintIndexKey(scope)
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, fingerprintKey(scope, id), data, fingerprintTTL)
+	added := pipe.ZAdd(ctx, index, redis.Z{Score: float64(seenAt.Unix()), Member: id})
+	pipe.ZRemRangeByRank(ctx, index, 0, -maxFingerprints-1)
+	pipe.Expire(ctx, index, fingerprintTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("save fingerprint: %w", err)
+	}
+	return added.Val() > 0, nil
+}
+
+// Fingerprints returns up to limit encoded fingerprints of scope, newest
+// first. Expired entries are skipped.
+func (s *RedisStore) Fingerprints(ctx context.Context, scope string, limit int) ([][]byte, error) {
+	ids, err := s.client.ZRevRange(ctx, fingerprintIndexKey(scope), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list fingerprints: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, fingerprintKey(scope, id))
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get fingerprints: %w", err)
+	}
+
+	items := make([][]byte, 0, len(values))
+	for _, value := range values {
+		if raw, ok := value.(string); ok {
+			items = append(items, []byte(raw))
+		}
+	}
+	return items, nil
+}
+
+// fingerprintStatsScript counts one value per attribute. KEYS[1] is the
+// totals hash and KEYS[2..] the per attribute sorted sets; ARGV[1] is the
+// limit of values per set followed by attribute/value pairs. Besides the
+// total it keeps sum(c*log2(c)) per attribute, adjusting it for evicted
+// values, and returns {total, count, sum, count, sum, ...}.
+var fingerprintStatsScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local function clogc(c)
+  if c <= 1 then return 0 end
+  return c * math.log(c) / math.log(2)
+end
+local reply = {redis.call('HINCRBY', KEYS[1], 'total', 1)}
+for i = 2, #KEYS do
+  local attr = ARGV[2 * i - 2]
+  local count = tonumber(redis.call('ZINCRBY', KEYS[i], 1, ARGV[2 * i - 1]))
+  local delta = clogc(count) - clogc(count - 1)
+  local overflow = redis.call('ZCARD', KEYS[i]) - limit
+  if overflow > 0 then
+    local popped = redis.call('ZPOPMIN', KEYS[i], overflow)
+    for j = 2, #popped, 2 do
+      delta = delta - clogc(tonumber(popped[j]))
+    end
+  end
+  table.insert(reply, count)
+  table.insert(reply, redis.call('HINCRBYFLOAT', KEYS[1], attr, delta))
+end
+return reply
+`)
+
+// AddFingerprintStats counts the value of every attribute and returns the
+// total, the new counts and the c*log2(c) sums. Each attribute keeps at
+// most limit values; the least common are evicted.
+func (s *RedisStore) AddFingerprintStats(ctx context.Context, values map[string]string, limit int) (int64, map[string]int64, map[string]float64, error) {
+	attrs := sortedKeys(values)
+	keys := []string{fingerprintStatsKey()}
+	args := []any{limit}
+	for _, attr := range attrs {
+		keys = append(keys, fingerprintValuesKey(attr))
+		args = append(args, attr, values[attr])
+	}
+
+	result, err := fingerprintStatsScript.Run(ctx, s.client, keys, args...).Slice()
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("add fingerprint stats: %w", err)
+	}
+	if len(result) != 1+2*len(attrs) {
+		return 0, nil, nil, fmt.Errorf("add fingerprint stats: unexpected reply %v", result)
+	}
+
+	total, _ := result[0].(int64)
+	counts := make(map[string]int64, len(attrs))
+	sums := make(map[string]float64, len(attrs))
+	for i, attr := range attrs {
+		counts[attr], _ = result[1+2*i].(int64)
+		raw, _ := result[2+2*i].(string)
+		if sums[attr], err = strconv.ParseFloat(raw, 64); err != nil {
+			return 0, nil, nil, fmt.Errorf("add fingerprint stats: parse sum: %w", err)
+		}
+	}
+	return total, counts, sums, nil
+}
+
+// FingerprintStats returns the same figures as AddFingerprintStats without
+// counting the values. Unknown values have a count of 0.
+func (s *RedisStore) FingerprintStats(ctx context.Context, values map[string]string) (int64, map[string]int64, map[string]float64, error) {
+	attrs := sortedKeys(values)
+	fields := append([]string{"total"}, attrs...)
+	pipe := s.client.Pipeline()
+	totals := pipe.HMGet(ctx, fingerprintStatsKey(), fields...)
+	scores := make([]*redis.FloatCmd, len(attrs))
+	for i, attr := range attrs {
+		scores[i] = pipe.ZScore(ctx, fingerprintValuesKey(attr), values[attr])
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, nil, nil, fmt.Errorf("get fingerprint stats: %w", err)
+	}
+
+	parse := func(v any) float64 {
+		raw, _ := v.(string)
+		f, _ := strconv.ParseFloat(raw, 64)
+		return f
+	}
+	row := totals.Val()
+	counts := make(map[string]int64, len(attrs))
+	sums := make(map[string]float64, len(attrs))
+	for i, attr := range attrs {
+		counts[attr] = int64(scores[i].Val())
+		sums[attr] = parse(row[i+1])
+	}
+	return int64(parse(row[0])), counts, sums, nil
+}
+
+// ResetFingerprintStats deletes the counters of the given attributes.
+func (s *RedisStore) ResetFingerprintStats(ctx context.Context, attributes []string) error {
+	keys := []string{fingerprintStatsKey()}
+	for _, attr := range attributes {
+		keys = append(keys, fingerprintValuesKey(attr))
+	}
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("reset fingerprint stats: %w", err)
+	}
+	return nil
+}
+
+// StartDNSLeak marks the DNS leak visit id as issued for ttl.
+func (s *RedisStore) StartDNSLeak(ctx context.Context, id string, ttl time.Duration) error {
+	if err := s.client.Set(ctx, dnsLeakKey(id), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("start dns leak: %w", err)
+	}
+	return nil
+}
+
+// dnsLeakQueryScript appends ARGV[1] to the query list KEYS[2] when the
+// visit KEYS[1] was issued and the list is not full; the list expires
+// with the visit. It returns 1 when the query was stored.
+var dnsLeakQueryScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl <= 0 then
+  return 0
+end
+if redis.call('LLEN', KEYS[2]) >= tonumber(ARGV[2]) then
+  return 0
+end
+redis.call('RPUSH', KEYS[2], ARGV[1])
+redis.call('PEXPIRE', KEYS[2], ttl)
+return 1
+`)
+
+// AddDNSLeakQuery stores an encoded query of the visit id. Queries for
+// unknown or expired visits and beyond maxDNSLeakQueries are ignored;
+// the result reports whether data was stored.
+func (s *RedisStore) AddDNSLeakQuery(ctx context.Context, id string, data []byte) (bool, error) {
+	keys := []string{dnsLeakKey(id), dnsLeakQueriesKey(id)}
+	added, err := dnsLeakQueryScript.Run(ctx, s.client, keys, data, maxDNSLeakQueries).Int()
+	if err != nil {
+		return false, fmt.Errorf("add dns leak query: %w", err)
+	}
+	return added == 1, nil
+}
+
+// DNSLeakQueries returns the encoded queries of the visit id in arrival
+// order. It reports false when the visit is unknown or expired.
+func (s *RedisStore) DNSLeakQueries(ctx context.Context, id string) ([][]byte, bool, error) {
+	pipe := s.client.Pipeline()
+	exists := pipe.Exists(ctx, dnsLeakKey(id))
+	queries := pipe.LRange(ctx, dnsLeakQueriesKey(id), 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, false, fmt.Errorf("dns leak queries: %w", err)
+	}
+	if exists.Val() == 0 {
+		return nil, false, nil
+	}
+
+	items := make([][]byte, 0, len(queries.Val()))
+	for _, raw := range queries.Val() {
+		items = append(items, []byte(raw))
+	}
+	return items, true, nil
+}
+
+// DNSLeakEnrichments returns the encoded enrichment of each resolver of the
+// visit id, keyed by resolver address.
+func (s *RedisStore) DNSLeakEnrichments(ctx context.Context, id string) (map[string][]byte, error) {
+	fields, err := s.client.HGetAll(ctx, dnsLeakEnrichedKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("dns leak enrichments: %w", err)
+	}
+	items := make(map[string][]byte, len(fields))
+	for ip, raw := range fields {
+		items[ip] = []byte(raw)
+	}
+	return items, nil
+}
+
+// SaveDNSLeakEnrichment stores the encoded enrichment of resolver ip for
+// the visit id; the hash expires ttl after the last write.
+func (s *RedisStore) SaveDNSLeakEnrichment(ctx context.Context, id, ip string, data []byte, ttl time.Duration) error {
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, dnsLeakEnrichedKey(id), ip, data)
+	pipe.Expire(ctx, dnsLeakEnrichedKey(id), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("save dns leak enrichment: %w", err)
+	}
+	return nil
+}
+
+// dualStackStartField marks an issued dual-stack visit, so the hash exists
+// before any probe arrives.
+const dualStackStartField = "_started"
+
+// StartDualStack marks the dual-stack visit id as issued for ttl.
+func (s *RedisStore) StartDualStack(ctx context.Context, id string, ttl time.Duration) error {
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, dualStackKey(id), dualStackStartField, 1)
+	pipe.Expire(ctx, dualStackKey(id), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("start dual stack: %w", err)
+	}
+	return nil
+}
+
+// hashIfExistsScript sets field ARGV[1] of hash KEYS[1] to ARGV[2] only if
+// the hash exists, keeping its expiry. It returns 1 when the field was set.
+var hashIfExistsScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// AddDualStackProbe stores the encoded observation of probe for the visit
+// id, replacing an earlier one. Probes of unknown or expired visits are
+// ignored; the result reports whether data was stored.
+func (s *RedisStore) AddDualStackProbe(ctx context.Context, id, probe string, data []byte) (bool, error) {
+	added, err := hashIfExistsScript.Run(ctx, s.client, []string{dualStackKey(id)}, probe, data).Int()
+	if err != nil {
+		return false, fmt.Errorf("add dual stack probe: %w", err)
+	}
+	return added == 1, nil
+}
+
+// DualStackProbes returns the encoded observations of the visit id by
+// probe name. It reports false when the visit is unknown or expired.
+func (s *RedisStore) DualStackProbes(ctx context.Context, id string) (map[string][]byte, bool, error) {
+	fields, err := s.client.HGetAll(ctx, dualStackKey(id)).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("dual stack probes: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, false, nil
+	}
+
+	probes := make(map[string][]byte, len(fields))
+	for probe, raw := range fields {
+		if probe != dualStackStartField {
+			probes[probe] = []byte(raw)
+		}
+	}
+	return probes, true, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func cacheKey(ip string) string {
 	return "rdap:" + ip
 }
@@ -101,3 +549,47 @@ func cacheKey(ip string) string {
 func countKey(ip string) string {
 	return "count:" + ip
 }
+
+func rateKey(key string) string {
+	return "ratelimit:" + key
+}
+
+func apiKeyKey(hash string) string {
+	return "apikey:" + hash
+}
+
+func usageKey(id, day string) string {
+	return "apikey_usage:" + id + ":" + day
+}
+
+func fingerprintKey(scope, id string) string {
+	return "fingerprint:" + scope + ":" + id
+}
+
+func fingerprintStatsKey() string {
+	return "fpstats"
+}
+
+func fingerprintValuesKey(attr string) string {
+	return "fpstats:" + attr
+}
+
+func fingerprintIndexKey(scope string) string {
+	return "fingerprints:" + scope
+}
+
+func dnsLeakKey(id string) string {
+	return "dnsleak:" + id
+}
+
+func dnsLeakQueriesKey(id string) string {
+	return "dnsleak:" + id + ":queries"
+}
+
+func dnsLeakEnrichedKey(id string) string {
+	return "dnsleak:" + id + ":enriched"
+}
+
+func dualStackKey(id string) string {
+	return "dualstack:" + id
+}
@@ -0,0 +1,52 @@
+package stun
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	countQueueSize = 1024
+	countTimeout   = 2 * time.Second
+)
+
+// Counter counts requests per client address.
+type Counter interface {
+	Count(ctx context.Context, ip string) (int64, error)
+}
+
+// CountBindings returns an onBinding callback that counts TCP connections
+// through counter, once each on their first binding. UDP bindings are not
+// counted: their source address is unauthenticated, so anyone could raise
+// another address's count, and browsers send several per page view. A
+// single worker does the counting until ctx is done so a flood of
+// connections cannot pile up store calls; bindings arriving while the
+// queue is full are not counted.
+func CountBindings(ctx context.Context, counter Counter, onError func(error)) func(Binding) {
+	queue := make(chan string, countQueueSize)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ip := <-queue:
+				countCtx, cancel := context.WithTimeout(ctx, countTimeout)
+				if _, err := counter.Count(countCtx, ip); err != nil {
+					onError(fmt.Errorf("stun count: %w", err))
+				}
+				cancel()
+			}
+		}
+	}()
+
+	return func(b Binding) {
+		if b.Transport != "tcp" || b.Seq != 1 {
+			return
+		}
+		select {
+		case queue <- b.Addr.Addr().String():
+		default:
+		}
+	}
+}
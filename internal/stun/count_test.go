@@ -0,0 +1,35 @@
+package stun
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+type chanCounter chan string
+
+func (c chanCounter) Count(ctx context.Context, ip string) (int64, error) {
+	c <- ip
+	return 1, nil
+}
+
+func TestCountBindings(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	counted := make(chanCounter, 1)
+	onBinding := CountBindings(ctx, counted, func(err error) { t.Error(err) })
+	onBinding(Binding{Addr: netip.MustParseAddrPort("198.51.100.1:1234"), Transport: "udp", Seq: 1})
+	onBinding(Binding{Addr: netip.MustParseAddrPort("198.51.100.2:1234"), Transport: "tcp", Seq: 2})
+	onBinding(Binding{Addr: netip.MustParseAddrPort("203.0.113.5:1234"), Transport: "tcp", Seq: 1})
+
+	select {
+	case ip := <-counted:
+		if ip != "203.0.113.5" {
+			t.Errorf("expected the client IP to be counted, got %q", ip)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("binding was not counted")
+	}
+}
@@ -0,0 +1,247 @@
+package stun
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/netip"
+)
+
+// Message types.
+const (
+	TypeBindingRequest  uint16 = 0x0001
+	TypeBindingSuccess  uint16 = 0x0101
+	TypeBindingError    uint16 = 0x0111
+	TypeBindingIndicate uint16 = 0x0011
+)
+
+// Attribute types.
+const (
+	AttrMappedAddress     uint16 = 0x0001
+	AttrChangeRequest     uint16 = 0x0003
+	AttrUsername          uint16 = 0x0006
+	AttrMessageIntegrity  uint16 = 0x0008
+	AttrErrorCode         uint16 = 0x0009
+	AttrUnknownAttributes uint16 = 0x000a
+	AttrXORMappedAddress  uint16 = 0x0020
+	AttrSoftware          uint16 = 0x8022
+	AttrFingerprint       uint16 = 0x8028
+	AttrResponseOrigin    uint16 = 0x802b
+	AttrOtherAddress      uint16 = 0x802c
+)
+
+const (
+	magicCookie    uint32 = 0x2112a442
+	fingerprintXOR uint32 = 0x5354554e
+	headerSize            = 20
+	familyIPv4            = 0x01
+	familyIPv6            = 0x02
+)
+
+var errShortMessage = errors.New("stun: message too short")
+
+// Attribute is a raw message attribute.
+type Attribute struct {
+	Type  uint16
+	Value []byte
+}
+
+// Message is a STUN message as defined by RFC 5389.
+type Message struct {
+	Type          uint16
+	TransactionID [12]byte
+	Attributes    []Attribute
+}
+
+// NewBindingRequest returns a request with a random transaction ID.
+func NewBindingRequest() *Message {
+	m := &Message{Type: TypeBindingRequest}
+	rand.Read(m.TransactionID[:])
+	return m
+}
+
+// IsMessage reports whether b looks like a STUN message: the two top bits
+// are zero and the magic cookie is present. It lets STUN share a port with
+// other protocols.
+func IsMessage(b []byte) bool {
+	return len(b) >= headerSize && b[0]&0xc0 == 0 && binary.BigEndian.Uint32(b[4:8]) == magicCookie
+}
+
+// Parse decodes a message and verifies its FINGERPRINT when present.
+func Parse(b []byte) (*Message, error) {
+	if len(b) < headerSize {
+		return nil, errShortMessage
+	}
+	if !IsMessage(b) {
+		return nil, errors.New("stun: not a STUN message")
+	}
+	length := int(binary.BigEndian.Uint16(b[2:4]))
+	if length%4 != 0 || headerSize+length > len(b) {
+		return nil, fmt.Errorf("stun: invalid message length %d", length)
+	}
+	b = b[:headerSize+length]
+
+	m := &Message{Type: binary.BigEndian.Uint16(b[0:2])}
+	copy(m.TransactionID[:], b[8:20])
+
+	for off := headerSize; off < len(b); {
+		if off+4 > len(b) {
+			return nil, errShortMessage
+		}
+		typ := binary.BigEndian.Uint16(b[off:])
+		size := int(binary.BigEndian.Uint16(b[off+2:]))
+		start := off + 4
+		if start+size > len(b) {
+			return nil, fmt.Errorf("stun: attribute 0x%04x exceeds message", typ)
+		}
+		value := b[start : start+size]
+
+		if typ == AttrFingerprint {
+			if size != 4 || start+size != len(b) {
+				return nil, errors.New("stun: misplaced FINGERPRINT")
+			}
+			if binary.BigEndian.Uint32(value) != fingerprint(b[:off]) {
+				return nil, errors.New("stun: FINGERPRINT mismatch")
+			}
+		}
+		m.Attributes = append(m.Attributes, Attribute{Type: typ, Value: append([]byte(nil), value...)})
+		off = start + (size+3)&^3
+	}
+	return m, nil
+}
+
+// Encode serializes the message and appends a FINGERPRINT attribute.
+func (m *Message) Encode() []byte {
+	b := make([]byte, headerSize, 128)
+	binary.BigEndian.PutUint16(b[0:2], m.Type)
+	binary.BigEndian.PutUint32(b[4:8], magicCookie)
+	copy(b[8:20], m.TransactionID[:])
+
+	for _, attr := range m.Attributes {
+		if attr.Type == AttrFingerprint {
+			continue
+		}
+		b = appendAttribute(b, attr.Type, attr.Value)
+	}
+
+	// The length must already cover FINGERPRINT when its CRC is computed.
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)-headerSize+8))
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, fingerprint(b))
+	return appendAttribute(b, AttrFingerprint, crc)
+}
+
+// Get returns the value of the first attribute of type typ.
+func (m *Message) Get(typ uint16) ([]byte, bool) {
+	for _, attr := range m.Attributes {
+		if attr.Type == typ {
+			return attr.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Add appends an attribute.
+func (m *Message) Add(typ uint16, value []byte) {
+	m.Attributes = append(m.Attributes, Attribute{Type: typ, Value: value})
+}
+
+// AddXORMappedAddress appends addr as XOR-MAPPED-ADDRESS.
+func (m *Message) AddXORMappedAddress(addr netip.AddrPort) {
+	m.Add(AttrXORMappedAddress, m.xorAddress(encodeAddress(addr)))
+}
+
+// AddMappedAddress appends addr as the RFC 3489 MAPPED-ADDRESS, which old
+// clients read instead of XOR-MAPPED-ADDRESS.
+func (m *Message) AddMappedAddress(addr netip.AddrPort) {
+	m.AddAddress(AttrMappedAddress, addr)
+}
+
+// AddAddress appends an attribute in the MAPPED-ADDRESS format, such as
+// RESPONSE-ORIGIN or OTHER-ADDRESS.
+func (m *Message) AddAddress(typ uint16, addr netip.AddrPort) {
+	m.Add(typ, encodeAddress(addr))
+}
+
+// Address decodes an attribute in the MAPPED-ADDRESS format.
+func (m *Message) Address(typ uint16) (netip.AddrPort, bool) {
+	value, ok := m.Get(typ)
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+	return decodeAddress(value)
+}
+
+// AddErrorCode appends an ERROR-CODE attribute.
+func (m *Message) AddErrorCode(code int, reason string) {
+	value := []byte{0, 0, byte(code / 100), byte(code % 100)}
+	m.Add(AttrErrorCode, append(value, reason...))
+}
+
+// MappedAddress returns XOR-MAPPED-ADDRESS, falling back to
+// MAPPED-ADDRESS.
+func (m *Message) MappedAddress() (netip.AddrPort, bool) {
+	if value, ok := m.Get(AttrXORMappedAddress); ok {
+		return decodeAddress(m.xorAddress(value))
+	}
+	return m.Address(AttrMappedAddress)
+}
+
+// xorAddress applies the XOR-MAPPED-ADDRESS mask; it is its own inverse.
+func (m *Message) xorAddress(value []byte) []byte {
+	if len(value) < 4 {
+		return value
+	}
+	out := append([]byte(nil), value...)
+	var mask [16]byte
+	binary.BigEndian.PutUint32(mask[:4], magicCookie)
+	copy(mask[4:], m.TransactionID[:])
+	out[2] ^= mask[0]
+	out[3] ^= mask[1]
+	for i := 4; i < len(out) && i-4 < len(mask); i++ {
+		out[i] ^= mask[i-4]
+	}
+	return out
+}
+
+func encodeAddress(addr netip.AddrPort) []byte {
+	ip := addr.Addr().Unmap()
+	family := byte(familyIPv6)
+	if ip.Is4() {
+		family = familyIPv4
+	}
+	b := []byte{0, family, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], addr.Port())
+	return append(b, ip.AsSlice()...)
+}
+
+func decodeAddress(b []byte) (netip.AddrPort, bool) {
+	if len(b) < 4 {
+		return netip.AddrPort{}, false
+	}
+	port := binary.BigEndian.Uint16(b[2:4])
+	switch {
+	case b[1] == familyIPv4 && len(b) == 8:
+		return netip.AddrPortFrom(netip.AddrFrom4([4]byte(b[4:8])), port), true
+	case b[1] == familyIPv6 && len(b) == 20:
+		return netip.AddrPortFrom(netip.AddrFrom16([16]byte(b[4:20])), port), true
+	}
+	return netip.AddrPort{}, false
+}
+
+func appendAttribute(b []byte, typ uint16, value []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)-headerSize))
+	return b
+}
+
+func fingerprint(b []byte) uint32 {
+	return crc32.ChecksumIEEE(b) ^ fingerprintXOR
+}
@@ -0,0 +1,70 @@
+package stun
+
+import (
+	"encoding/hex"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParse_RFC5769Response(t *testing.T) {
+	// Sample IPv4 response from RFC 5769 section 2.2.
+	raw, err := hex.DecodeString(strings.Join([]string{
+		"0101003c2112a442b7e7a701bc34d686fa87dfae",
+		"8022000b7465737420766563746f7220",
+		"00200008" + "0001a147e112a643",
+		"00080014" + "2b91f599fd9e90c38c7489f92af9ba53f06be7d7",
+		"80280004" + "c07d4c96",
+	}, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if msg.Type != TypeBindingSuccess {
+		t.Errorf("expected binding success, got 0x%04x", msg.Type)
+	}
+	addr, ok := msg.MappedAddress()
+	if !ok || addr != netip.MustParseAddrPort("192.0.2.1:32853") {
+		t.Errorf("unexpected mapped address %v", addr)
+	}
+	if software, _ := msg.Get(AttrSoftware); string(software) != "test vector" {
+		t.Errorf("unexpected software %q", software)
+	}
+
+	raw[len(raw)-1] ^= 1
+	if _, err := Parse(raw); err == nil {
+		t.Error("expected FINGERPRINT mismatch to be rejected")
+	}
+}
+
+func TestMessage_RoundTrip(t *testing.T) {
+	for _, addr := range []string{"203.0.113.7:50000", "[2001:db8::42]:3478"} {
+		msg := &Message{Type: TypeBindingSuccess, TransactionID: NewBindingRequest().TransactionID}
+		msg.AddXORMappedAddress(netip.MustParseAddrPort(addr))
+		msg.Add(AttrSoftware, []byte("abc"))
+
+		got, err := Parse(msg.Encode())
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", addr, err)
+		}
+		if mapped, ok := got.MappedAddress(); !ok || mapped.String() != addr {
+			t.Errorf("%s: got mapped address %v", addr, mapped)
+		}
+		if value, _ := got.Get(AttrSoftware); string(value) != "abc" {
+			t.Errorf("%s: expected padded attribute to survive, got %q", addr, value)
+		}
+	}
+}
+
+func TestIsMessage(t *testing.T) {
+	if !IsMessage(NewBindingRequest().Encode()) {
+		t.Error("expected binding request to be recognised")
+	}
+	if IsMessage([]byte("GET / HTTP/1.1\r\nHost: example\r\n")) {
+		t.Error("expected HTTP not to be recognised as STUN")
+	}
+}
@@ -0,0 +1,120 @@
+package stun
+
+import (
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Observations remembers recently answered addresses so that candidates a
+// page reports can be checked against what the server actually saw. ICE
+// connectivity checks are also kept by the ufrag the page chose, so a
+// visit can ask which addresses its own checks came from. Only the newest
+// maxEntries addresses and ufrags within ttl are kept.
+type Observations struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	seen   map[netip.AddrPort]time.Time
+	ufrags map[string]*ufragChecks
+}
+
+type ufragChecks struct {
+	addrs []netip.AddrPort
+	at    time.Time
+}
+
+// maxAddrsPerUfrag bounds the sources kept for one ufrag; a browser checks
+// from one address per local interface.
+const maxAddrsPerUfrag = 16
+
+// NewObservations creates an empty set.
+func NewObservations(ttl time.Duration, maxEntries int) *Observations {
+	return &Observations{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		seen:       map[netip.AddrPort]time.Time{},
+		ufrags:     map[string]*ufragChecks{},
+	}
+}
+
+// Record stores a binding. It matches the onBinding signature of Server.
+func (o *Observations) Record(b Binding) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.seen[b.Addr]; !ok && len(o.seen) >= o.maxEntries {
+		prune(o.seen, o.now(), o.ttl, o.maxEntries, func(at time.Time) time.Time { return at })
+	}
+	o.seen[b.Addr] = o.now()
+
+	// The server is the remote peer of the check, so its ufrag comes first.
+	ufrag, _, ok := strings.Cut(b.Username, ":")
+	if !ok || ufrag == "" {
+		return
+	}
+	checks, ok := o.ufrags[ufrag]
+	if !ok {
+		if len(o.ufrags) >= o.maxEntries {
+			prune(o.ufrags, o.now(), o.ttl, o.maxEntries, func(c *ufragChecks) time.Time { return c.at })
+		}
+		checks = &ufragChecks{}
+		o.ufrags[ufrag] = checks
+	}
+	checks.at = o.now()
+	if !containsAddr(checks.addrs, b.Addr) && len(checks.addrs) < maxAddrsPerUfrag {
+		checks.addrs = append(checks.addrs, b.Addr)
+	}
+}
+
+// Seen reports whether a binding from addr was answered within ttl.
+func (o *Observations) Seen(addr netip.AddrPort) bool {
+	addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	at, ok := o.seen[addr]
+	return ok && o.now().Sub(at) <= o.ttl
+}
+
+// Checks returns the addresses ICE connectivity checks for ufrag came
+// from within ttl.
+func (o *Observations) Checks(ufrag string) []netip.AddrPort {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	checks, ok := o.ufrags[ufrag]
+	if !ok || o.now().Sub(checks.at) > o.ttl {
+		return nil
+	}
+	return append([]netip.AddrPort(nil), checks.addrs...)
+}
+
+// prune drops expired entries, or the oldest one when none expired.
+func prune[K comparable, V any](entries map[K]V, now time.Time, ttl time.Duration, maxEntries int, at func(V) time.Time) {
+	var oldest K
+	var oldestAt time.Time
+	for key, value := range entries {
+		if now.Sub(at(value)) > ttl {
+			delete(entries, key)
+			continue
+		}
+		if oldestAt.IsZero() || at(value).Before(oldestAt) {
+			oldest, oldestAt = key, at(value)
+		}
+	}
+	if len(entries) >= maxEntries {
+		delete(entries, oldest)
+	}
+}
+
+func containsAddr(addrs []netip.AddrPort, addr netip.AddrPort) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
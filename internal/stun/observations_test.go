@@ -0,0 +1,63 @@
+package stun
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestObservations(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	obs := NewObservations(time.Minute, 2)
+	obs.now = func() time.Time { return now }
+
+	a := netip.MustParseAddrPort("203.0.113.1:1000")
+	b := netip.MustParseAddrPort("203.0.113.2:2000")
+	c := netip.MustParseAddrPort("203.0.113.3:3000")
+
+	obs.Record(Binding{Addr: a})
+	now = now.Add(time.Second)
+	obs.Record(Binding{Addr: b})
+	if !obs.Seen(a) || !obs.Seen(b) {
+		t.Fatal("expected recorded addresses to be seen")
+	}
+	if !obs.Seen(netip.MustParseAddrPort("[::ffff:203.0.113.1]:1000")) {
+		t.Error("expected IPv4-mapped lookup to match")
+	}
+
+	obs.Record(Binding{Addr: c})
+	if obs.Seen(a) || !obs.Seen(c) {
+		t.Error("expected the oldest address to be evicted when full")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if obs.Seen(c) {
+		t.Error("expected observation to expire")
+	}
+}
+
+func TestObservations_Checks(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	obs := NewObservations(time.Minute, 10)
+	obs.now = func() time.Time { return now }
+
+	a := netip.MustParseAddrPort("203.0.113.1:1000")
+	b := netip.MustParseAddrPort("198.51.100.7:2000")
+	obs.Record(Binding{Addr: a, Username: "visit1:Ab3d"})
+	obs.Record(Binding{Addr: b, Username: "visit1:Ab3d"})
+	obs.Record(Binding{Addr: a, Username: "visit1:Ab3d"})
+	obs.Record(Binding{Addr: b, Username: "other:Ab3d"})
+	obs.Record(Binding{Addr: b})
+
+	if got := obs.Checks("visit1"); len(got) != 2 || got[0] != a || got[1] != b {
+		t.Errorf("unexpected checks %v", got)
+	}
+	if got := obs.Checks("missing"); got != nil {
+		t.Errorf("expected no checks, got %v", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got := obs.Checks("visit1"); got != nil {
+		t.Errorf("expected checks to expire, got %v", got)
+	}
+}
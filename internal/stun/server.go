@@ -0,0 +1,301 @@
+package stun
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const (
+	maxPacketSize  = 1500
+	maxStreamConns = 1024
+	streamIdle     = 30 * time.Second
+	software       = "myip"
+)
+
+// CHANGE-REQUEST flags from RFC 5780.
+const (
+	changeIP   = 0x04
+	changePort = 0x02
+)
+
+// Binding is a binding request that was answered. Username is the
+// USERNAME of ICE connectivity checks, "<remote ufrag>:<local ufrag>".
+// Seq numbers the messages of a TCP connection from 1; it is always 1
+// over UDP.
+type Binding struct {
+	Addr      netip.AddrPort
+	Transport string
+	Username  string
+	Seq       int
+	At        time.Time
+}
+
+// Server answers binding requests with the address they came from.
+type Server struct {
+	onBinding func(Binding)
+
+	// primary and alternate enable RFC 5780 NAT behaviour discovery: they
+	// span the four UDP sockets primary/alternate IP x primary/alternate
+	// port.
+	primary, alternate netip.AddrPort
+
+	mu    sync.RWMutex
+	conns map[netip.AddrPort]net.PacketConn
+}
+
+// NewServer creates a responder. onBinding, when not nil, is called for
+// every answered request.
+func NewServer(onBinding func(Binding)) *Server {
+	return &Server{onBinding: onBinding, conns: map[netip.AddrPort]net.PacketConn{}}
+}
+
+// SetAlternate enables OTHER-ADDRESS and CHANGE-REQUEST. The server must
+// also serve UDP on all four combinations of the IPs and ports of primary
+// and alternate; AlternateAddrs lists them.
+func (s *Server) SetAlternate(primary, alternate netip.AddrPort) {
+	s.primary, s.alternate = primary, alternate
+}
+
+// AlternateAddrs returns the four UDP addresses needed for RFC 5780, the
+// primary first.
+func AlternateAddrs(primary, alternate netip.AddrPort) []netip.AddrPort {
+	return []netip.AddrPort{
+		primary,
+		netip.AddrPortFrom(primary.Addr(), alternate.Port()),
+		netip.AddrPortFrom(alternate.Addr(), primary.Port()),
+		alternate,
+	}
+}
+
+// Respond returns the reply to packet b received from addr on local over
+// transport and the local address it must be sent from, or nil when the
+// packet gets no reply.
+func (s *Server) Respond(b []byte, from, local netip.AddrPort, transport string) ([]byte, netip.AddrPort) {
+	return s.respond(b, from, local, transport, 1)
+}
+
+func (s *Server) respond(b []byte, from, local netip.AddrPort, transport string, seq int) ([]byte, netip.AddrPort) {
+	req, err := Parse(b)
+	if err != nil || req.Type != TypeBindingRequest {
+		return nil, local
+	}
+	from = unmap(from)
+	local = unmap(local)
+
+	resp := &Message{Type: TypeBindingSuccess, TransactionID: req.TransactionID}
+	reject := func(code int, reason string, unknown []byte) ([]byte, netip.AddrPort) {
+		resp.Type = TypeBindingError
+		resp.AddErrorCode(code, reason)
+		if unknown != nil {
+			resp.Add(AttrUnknownAttributes, unknown)
+		}
+		return resp.Encode(), local
+	}
+
+	if unknown := unknownAttributes(req); len(unknown) > 0 {
+		return reject(420, "Unknown Attribute", unknown)
+	}
+
+	source := local
+	if value, ok := req.Get(AttrChangeRequest); ok {
+		if len(value) != 4 {
+			return reject(400, "Bad Request", nil)
+		}
+		changed, ok := s.changedSource(local, value[3], transport)
+		if !ok {
+			return reject(420, "Unknown Attribute", []byte{byte(AttrChangeRequest >> 8), byte(AttrChangeRequest)})
+		}
+		source = changed
+	}
+
+	resp.AddXORMappedAddress(from)
+	resp.AddMappedAddress(from)
+	if source.Addr().IsValid() && !source.Addr().IsUnspecified() {
+		resp.AddAddress(AttrResponseOrigin, source)
+	}
+	if other, ok := s.otherAddress(local); ok && transport == "udp" {
+		resp.AddAddress(AttrOtherAddress, other)
+	}
+	resp.Add(AttrSoftware, []byte(software))
+	if s.onBinding != nil {
+		username, _ := req.Get(AttrUsername)
+		s.onBinding(Binding{Addr: from, Transport: transport, Username: string(username), Seq: seq, At: time.Now()})
+	}
+	return resp.Encode(), source
+}
+
+// otherAddress is the address differing from local in both IP and port.
+func (s *Server) otherAddress(local netip.AddrPort) (netip.AddrPort, bool) {
+	if !s.alternate.IsValid() {
+		return netip.AddrPort{}, false
+	}
+	return s.swap(local, true, true), true
+}
+
+// changedSource applies CHANGE-REQUEST flags to local. Only UDP with an
+// alternate address configured can honour them.
+func (s *Server) changedSource(local netip.AddrPort, flags byte, transport string) (netip.AddrPort, bool) {
+	if flags&(changeIP|changePort) == 0 {
+		return local, true
+	}
+	if !s.alternate.IsValid() || transport != "udp" {
+		return netip.AddrPort{}, false
+	}
+	source := s.swap(local, flags&changeIP != 0, flags&changePort != 0)
+	s.mu.RLock()
+	_, ok := s.conns[source]
+	s.mu.RUnlock()
+	return source, ok
+}
+
+func (s *Server) swap(local netip.AddrPort, ip, port bool) netip.AddrPort {
+	addr, p := local.Addr(), local.Port()
+	if ip {
+		addr = s.alternate.Addr()
+		if addr == local.Addr() {
+			addr = s.primary.Addr()
+		}
+	}
+	if port {
+		p = s.alternate.Port()
+		if p == local.Port() {
+			p = s.primary.Port()
+		}
+	}
+	return netip.AddrPortFrom(addr, p)
+}
+
+// ServePacket answers requests on conn until ctx is done or conn fails.
+func (s *Server) ServePacket(ctx context.Context, conn net.PacketConn) error {
+	local := unmap(conn.LocalAddr().(*net.UDPAddr).AddrPort())
+	s.mu.Lock()
+	s.conns[local] = conn
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		udp, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		reply, source := s.Respond(buf[:n], udp.AddrPort(), local, "udp")
+		if reply == nil {
+			continue
+		}
+		out := conn
+		if source != local {
+			s.mu.RLock()
+			out = s.conns[source]
+			s.mu.RUnlock()
+		}
+		out.WriteTo(reply, addr)
+	}
+}
+
+// ServeStream answers requests framed on TCP connections accepted from ln
+// until ctx is done.
+func (s *Server) ServeStream(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	slots := make(chan struct{}, maxStreamConns)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer func() { <-slots }()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	from, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil {
+		return
+	}
+	local, _ := netip.ParseAddrPort(conn.LocalAddr().String())
+
+	buf := make([]byte, maxPacketSize)
+	for seq := 1; ; seq++ {
+		conn.SetReadDeadline(time.Now().Add(streamIdle))
+		if _, err := io.ReadFull(conn, buf[:headerSize]); err != nil {
+			return
+		}
+		if !IsMessage(buf[:headerSize]) {
+			return
+		}
+		n := headerSize + int(binary.BigEndian.Uint16(buf[2:4]))
+		if n > len(buf) {
+			return
+		}
+		if _, err := io.ReadFull(conn, buf[headerSize:n]); err != nil {
+			return
+		}
+		if reply, _ := s.respond(buf[:n], from, local, "tcp", seq); reply != nil {
+			conn.SetWriteDeadline(time.Now().Add(streamIdle))
+			if _, err := conn.Write(reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// knownAttributes are the comprehension-required attributes a binding
+// request may carry, including the ICE ones browsers add.
+var knownAttributes = map[uint16]bool{
+	AttrMappedAddress:    true,
+	AttrUsername:         true,
+	AttrMessageIntegrity: true,
+	AttrXORMappedAddress: true,
+	AttrChangeRequest:    true,
+	0x0024:               true, // PRIORITY
+	0x0025:               true, // USE-CANDIDATE
+}
+
+// unknownAttributes returns the UNKNOWN-ATTRIBUTES value listing the
+// comprehension-required attributes we do not understand.
+func unknownAttributes(m *Message) []byte {
+	var out []byte
+	for _, attr := range m.Attributes {
+		if attr.Type < 0x8000 && !knownAttributes[attr.Type] {
+			out = append(out, byte(attr.Type>>8), byte(attr.Type))
+		}
+	}
+	return out
+}
+
+func unmap(addr netip.AddrPort) netip.AddrPort {
+	return netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+}
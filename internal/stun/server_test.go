@@ -0,0 +1,232 @@
+package stun
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestServer_ServePacket(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bindings := make(chan Binding, 1)
+	server := NewServer(func(b Binding) { bindings <- b })
+	go server.ServePacket(ctx, conn)
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	req := NewBindingRequest()
+	if _, err := client.Write(req.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, maxPacketSize)
+	n, err := client.Read(buf)
+	if err != nil {
+		t.Fatalf("no response: %v", err)
+	}
+
+	resp, err := Parse(buf[:n])
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if resp.Type != TypeBindingSuccess || resp.TransactionID != req.TransactionID {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	local := client.LocalAddr().(*net.UDPAddr).AddrPort()
+	if mapped, _ := resp.MappedAddress(); mapped != local {
+		t.Errorf("expected mapped address %v, got %v", local, mapped)
+	}
+	if b := <-bindings; b.Addr != local || b.Transport != "udp" {
+		t.Errorf("unexpected binding %+v", b)
+	}
+}
+
+func TestServer_Respond(t *testing.T) {
+	server := NewServer(nil)
+	from := netip.MustParseAddrPort("198.51.100.1:4000")
+	local := netip.MustParseAddrPort("192.0.2.10:3478")
+
+	if reply, _ := server.Respond([]byte("not stun"), from, local, "udp"); reply != nil {
+		t.Error("expected no reply to garbage")
+	}
+
+	success := &Message{Type: TypeBindingSuccess}
+	if reply, _ := server.Respond(success.Encode(), from, local, "udp"); reply != nil {
+		t.Error("expected no reply to a response")
+	}
+
+	req := NewBindingRequest()
+	reply, source := server.Respond(req.Encode(), from, local, "udp")
+	resp, err := Parse(reply)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if origin, _ := resp.Address(AttrResponseOrigin); origin != local || source != local {
+		t.Errorf("expected RESPONSE-ORIGIN %v, got %v from %v", local, origin, source)
+	}
+	if _, ok := resp.Get(AttrOtherAddress); ok {
+		t.Error("expected no OTHER-ADDRESS without an alternate address")
+	}
+
+	// Without an alternate address CHANGE-REQUEST cannot be honoured.
+	req.Add(AttrChangeRequest, []byte{0, 0, 0, changeIP})
+	reply, _ = server.Respond(req.Encode(), from, local, "udp")
+	resp, _ = Parse(reply)
+	unknown, _ := resp.Get(AttrUnknownAttributes)
+	if resp.Type != TypeBindingError || string(unknown) != "\x00\x03" {
+		t.Errorf("expected 420 listing CHANGE-REQUEST, got %+v", resp)
+	}
+
+	req = NewBindingRequest()
+	req.Add(0x0004, []byte{0, 0, 0, 0}) // RESPONSE-ADDRESS, RFC 3489 only
+	reply, _ = server.Respond(req.Encode(), from, local, "udp")
+	if resp, _ = Parse(reply); resp.Type != TypeBindingError {
+		t.Errorf("expected unknown attribute to be rejected, got %+v", resp)
+	}
+}
+
+func TestServer_RespondICECheck(t *testing.T) {
+	var got Binding
+	server := NewServer(func(b Binding) { got = b })
+	from := netip.MustParseAddrPort("198.51.100.1:4000")
+	local := netip.MustParseAddrPort("192.0.2.10:3478")
+
+	// A browser connectivity check toward an ICE-lite peer.
+	req := NewBindingRequest()
+	req.Add(AttrUsername, []byte("visit1:Ab3d"))
+	req.Add(0x0024, []byte{0x6e, 0x7f, 0x1e, 0xff}) // PRIORITY
+	req.Add(0x802a, make([]byte, 8))                // ICE-CONTROLLING
+	req.Add(AttrMessageIntegrity, make([]byte, 20))
+	reply, _ := server.Respond(req.Encode(), from, local, "udp")
+	if resp, err := Parse(reply); err != nil || resp.Type != TypeBindingSuccess {
+		t.Fatalf("expected success, got %+v (%v)", resp, err)
+	}
+	if got.Username != "visit1:Ab3d" || got.Addr != from {
+		t.Errorf("unexpected binding %+v", got)
+	}
+}
+
+func TestServer_ServeStream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bindings := make(chan Binding, 2)
+	go NewServer(func(b Binding) { bindings <- b }).ServeStream(ctx, ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// Two requests on one connection, the first split across writes.
+	for i := 0; i < 2; i++ {
+		packet := NewBindingRequest().Encode()
+		conn.Write(packet[:7])
+		conn.Write(packet[7:])
+
+		header := make([]byte, headerSize)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			t.Fatalf("read header: %v", err)
+		}
+		body := make([]byte, int(header[2])<<8|int(header[3]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		resp, err := Parse(append(header, body...))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		local := conn.LocalAddr().(*net.TCPAddr).AddrPort()
+		if mapped, _ := resp.MappedAddress(); mapped != local {
+			t.Errorf("expected mapped address %v, got %v", local, mapped)
+		}
+		if b := <-bindings; b.Transport != "tcp" || b.Seq != i+1 {
+			t.Errorf("expected tcp binding %d, got %+v", i+1, b)
+		}
+	}
+}
+
+func TestServer_ChangeRequest(t *testing.T) {
+	primary := netip.MustParseAddrPort("127.0.0.1:0")
+	conn, err := net.ListenPacket("udp", primary.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary = conn.LocalAddr().(*net.UDPAddr).AddrPort()
+	altConn, err := net.ListenPacket("udp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("no second loopback address: %v", err)
+	}
+	alternate := altConn.LocalAddr().(*net.UDPAddr).AddrPort()
+	altConn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := NewServer(nil)
+	server.SetAlternate(primary, alternate)
+	go server.ServePacket(ctx, conn)
+	for _, addr := range AlternateAddrs(primary, alternate)[1:] {
+		c, err := net.ListenPacket("udp", addr.String())
+		if err != nil {
+			t.Skipf("listen %v: %v", addr, err)
+		}
+		go server.ServePacket(ctx, c)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		flags byte
+		want  netip.AddrPort
+	}{
+		{0, primary},
+		{changePort, netip.AddrPortFrom(primary.Addr(), alternate.Port())},
+		{changeIP, netip.AddrPortFrom(alternate.Addr(), primary.Port())},
+		{changeIP | changePort, alternate},
+	}
+	for _, tt := range tests {
+		req := NewBindingRequest()
+		req.Add(AttrChangeRequest, []byte{0, 0, 0, tt.flags})
+		client.WriteTo(req.Encode(), net.UDPAddrFromAddrPort(primary))
+
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, maxPacketSize)
+		n, from, err := client.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("flags %#x: no response: %v", tt.flags, err)
+		}
+		if got := from.(*net.UDPAddr).AddrPort(); got != tt.want {
+			t.Errorf("flags %#x: expected reply from %v, got %v", tt.flags, tt.want, got)
+		}
+		resp, _ := Parse(buf[:n])
+		if other, _ := resp.Address(AttrOtherAddress); other != alternate {
+			t.Errorf("flags %#x: expected OTHER-ADDRESS %v, got %v", tt.flags, alternate, other)
+		}
+		if origin, _ := resp.Address(AttrResponseOrigin); origin != tt.want {
+			t.Errorf("flags %#x: expected RESPONSE-ORIGIN %v, got %v", tt.flags, tt.want, origin)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation.
+const listenFDsStart = 3
+
+// Notify sends a state string such as "READY=1" to the service manager.
+// It is a no-op when the process was not started with NOTIFY_SOCKET.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("dial notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("write notify socket: %w", err)
+	}
+	return nil
+}
+
+// Status reports a human readable status line shown by systemctl status.
+func Status(format string, args ...any) error {
+	return Notify("STATUS=" + fmt.Sprintf(format, args...))
+}
+
+// WatchdogInterval returns how often WATCHDOG=1 must be sent, or zero when
+// the watchdog is disabled for this process.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog sends keepalives at half the configured interval for as long as
+// check succeeds, so a wedged dependency lets systemd restart the service.
+func Watchdog(ctx context.Context, check func(context.Context) error, onError func(error)) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, interval/2)
+		err := check(checkCtx)
+		cancel()
+		if err != nil {
+			onError(fmt.Errorf("watchdog health check: %w", err))
+			Status("unhealthy: %v", err)
+			continue
+		}
+		if err := Notify("WATCHDOG=1"); err != nil {
+			onError(err)
+		}
+	}
+}
+
+// Listeners returns the sockets passed via LISTEN_FDS, keyed by the names
+// from LISTEN_FDNAMES when present. The environment is cleared so child
+// processes do not inherit them.
+func Listeners() ([]net.Listener, []string, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	if pid := os.Getenv("LISTEN_PID"); pid == "" || pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	listeners := make([]net.Listener, 0, count)
+	listenerNames := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		fd := listenFDsStart + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		file := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, nil, fmt.Errorf("inherit listener %s: %w", name, err)
+		}
+		listeners = append(listeners, ln)
+		listenerNames = append(listenerNames, name)
+	}
+	return listeners, listenerNames, nil
+}
@@ -0,0 +1,175 @@
+package tor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Exit is a Tor exit relay address.
+type Exit struct {
+	Fingerprint string
+	Policy      Policy
+}
+
+// Policy is an Onionoo exit policy summary: either accepted or rejected
+// port ranges. The zero value allows every port.
+type Policy struct {
+	Accept []PortRange
+	Reject []PortRange
+}
+
+// PortRange is an inclusive range of ports.
+type PortRange struct {
+	From, To uint16
+}
+
+// Allows reports whether the policy permits connections to port.
+func (p Policy) Allows(port uint16) bool {
+	if p.Accept != nil {
+		return inRanges(p.Accept, port)
+	}
+	return !inRanges(p.Reject, port)
+}
+
+func inRanges(ranges []PortRange, port uint16) bool {
+	for _, r := range ranges {
+		if port >= r.From && port <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+type onionooDetails struct {
+	Relays []struct {
+		Fingerprint   string   `json:"fingerprint"`
+		ORAddresses   []string `json:"or_addresses"`
+		ExitAddresses []string `json:"exit_addresses"`
+		PolicySummary struct {
+			Accept []string `json:"accept"`
+			Reject []string `json:"reject"`
+		} `json:"exit_policy_summary"`
+	} `json:"relays"`
+}
+
+// maxListBytes bounds a downloaded list; an Onionoo document with every
+// exit is a few megabytes.
+const maxListBytes = 64 << 20
+
+// Parse reads an exit list in any of the supported formats.
+func Parse(r io.Reader) (map[netip.Addr]Exit, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxListBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxListBytes {
+		return nil, fmt.Errorf("exit list exceeds %d bytes", maxListBytes)
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseOnionoo(trimmed)
+	}
+	return parseLines(data)
+}
+
+// parseLines handles both the bulk exit list (one address per line) and
+// the exit-addresses file with ExitNode/ExitAddress records.
+func parseLines(data []byte) (map[netip.Addr]Exit, error) {
+	exits := map[netip.Addr]Exit{}
+	var fingerprint string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "ExitNode":
+			fingerprint = ""
+			if len(fields) > 1 {
+				fingerprint = fields[1]
+			}
+		case "ExitAddress":
+			if len(fields) < 2 {
+				continue
+			}
+			addr, err := netip.ParseAddr(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid exit address %q", fields[1])
+			}
+			exits[addr.Unmap()] = Exit{Fingerprint: fingerprint}
+		case "Published", "LastStatus":
+		default:
+			addr, err := netip.ParseAddr(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid line %q", scanner.Text())
+			}
+			exits[addr.Unmap()] = Exit{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return exits, nil
+}
+
+func parseOnionoo(data []byte) (map[netip.Addr]Exit, error) {
+	var details onionooDetails
+	if err := json.Unmarshal(data, &details); err != nil {
+		return nil, err
+	}
+
+	exits := map[netip.Addr]Exit{}
+	for _, relay := range details.Relays {
+		policy := Policy{}
+		var err error
+		if relay.PolicySummary.Accept != nil {
+			if policy.Accept, err = parsePorts(relay.PolicySummary.Accept); err != nil {
+				return nil, fmt.Errorf("relay %s: %w", relay.Fingerprint, err)
+			}
+		} else if policy.Reject, err = parsePorts(relay.PolicySummary.Reject); err != nil {
+			return nil, fmt.Errorf("relay %s: %w", relay.Fingerprint, err)
+		}
+
+		exit := Exit{Fingerprint: relay.Fingerprint, Policy: policy}
+		// exit_addresses only lists addresses that differ from the OR
+		// addresses, so both are exits.
+		for _, raw := range relay.ExitAddresses {
+			if addr, err := netip.ParseAddr(raw); err == nil {
+				exits[addr.Unmap()] = exit
+			}
+		}
+		for _, raw := range relay.ORAddresses {
+			if addrPort, err := netip.ParseAddrPort(raw); err == nil {
+				exits[addrPort.Addr().Unmap()] = exit
+			}
+		}
+	}
+	return exits, nil
+}
+
+// parsePorts parses policy items such as "80", "443" or "1000-2000".
+func parsePorts(items []string) ([]PortRange, error) {
+	ranges := make([]PortRange, 0, len(items))
+	for _, item := range items {
+		from, to, found := strings.Cut(item, "-")
+		if !found {
+			to = from
+		}
+		start, err := strconv.ParseUint(from, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", item)
+		}
+		end, err := strconv.ParseUint(to, 10, 16)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid port %q", item)
+		}
+		ranges = append(ranges, PortRange{From: uint16(start), To: uint16(end)})
+	}
+	return ranges, nil
+}
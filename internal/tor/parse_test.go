@@ -0,0 +1,84 @@
+package tor
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParse_ExitAddresses(t *testing.T) {
+	input := `ExitNode 0011BD2485AD45D984EC4159C88FC066E5E3300E
+Published 2024-01-01 12:00:00
+LastStatus 2024-01-01 13:00:00
+ExitAddress 162.247.74.201 2024-01-01 13:09:54
+ExitNode 00FAB1AC9F8D2A8B7A1F7CB4A52F6F7B0D1A5E0B
+Published 2024-01-01 12:00:00
+LastStatus 2024-01-01 13:00:00
+ExitAddress 185.220.101.1 2024-01-01 13:10:00
+ExitAddress 185.220.101.2 2024-01-01 13:10:00
+`
+	exits, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(exits) != 3 {
+		t.Fatalf("expected 3 exits, got %d", len(exits))
+	}
+	if exit := exits[netip.MustParseAddr("185.220.101.2")]; exit.Fingerprint != "00FAB1AC9F8D2A8B7A1F7CB4A52F6F7B0D1A5E0B" {
+		t.Errorf("unexpected fingerprint %q", exit.Fingerprint)
+	}
+}
+
+func TestParse_BulkList(t *testing.T) {
+	exits, err := Parse(strings.NewReader("# comment\n162.247.74.201\n2a0b:f4c2::1\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, ok := exits[netip.MustParseAddr("2a0b:f4c2::1")]; !ok || len(exits) != 2 {
+		t.Errorf("unexpected exits %v", exits)
+	}
+
+	if _, err := Parse(strings.NewReader("not an address\n")); err == nil {
+		t.Error("expected error for garbage line")
+	}
+}
+
+func TestParse_Onionoo(t *testing.T) {
+	input := `{"relays": [
+		{"fingerprint": "AAAA", "or_addresses": ["10.0.0.1:9001", "[2001:db8::1]:9001"],
+		 "exit_addresses": ["10.0.0.2"], "exit_policy_summary": {"accept": ["80", "443", "6660-6669"]}},
+		{"fingerprint": "BBBB", "or_addresses": ["10.0.0.3:443"], "exit_policy_summary": {"reject": ["25", "119"]}}
+	]}`
+	exits, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(exits) != 4 {
+		t.Fatalf("expected 4 exit addresses, got %d", len(exits))
+	}
+
+	a := exits[netip.MustParseAddr("10.0.0.2")]
+	if a.Fingerprint != "AAAA" || !a.Policy.Allows(443) || !a.Policy.Allows(6665) || a.Policy.Allows(8080) {
+		t.Errorf("unexpected accept policy %+v", a)
+	}
+	b := exits[netip.MustParseAddr("10.0.0.3")]
+	if b.Policy.Allows(25) || !b.Policy.Allows(443) {
+		t.Errorf("unexpected reject policy %+v", b)
+	}
+}
+
+// newlines is an endless exit list of empty lines.
+type newlines struct{}
+
+func (newlines) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '\n'
+	}
+	return len(p), nil
+}
+
+func TestParse_TooLarge(t *testing.T) {
+	if _, err := Parse(newlines{}); err == nil {
+		t.Error("expected an error for an unbounded list")
+	}
+}
@@ -0,0 +1,114 @@
+package tor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const fetchTimeout = 30 * time.Second
+
+// Match is the Tor view of an address.
+type Match struct {
+	IsTor       bool   `json:"is_tor"`
+	Fingerprint string `json:"tor_fingerprint,omitempty"`
+}
+
+// List holds the current set of Tor exit addresses loaded from a file or
+// URL. The source may be the bulk exit list, the exit-addresses file or an
+// Onionoo details document, which also carries exit policies.
+type List struct {
+	source string
+	port   uint16
+	http   *http.Client
+	exits  atomic.Pointer[map[netip.Addr]Exit]
+}
+
+// NewList creates a list for source, a path or an http(s) URL. When port
+// is not zero, exits whose known policy rejects it are not reported.
+func NewList(source string, port uint16) *List {
+	l := &List{source: source, port: port, http: &http.Client{Timeout: fetchTimeout}}
+	l.exits.Store(&map[netip.Addr]Exit{})
+	return l
+}
+
+// Lookup reports whether ip is a Tor exit able to reach our port.
+func (l *List) Lookup(ip string) Match {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Match{}
+	}
+	exit, ok := (*l.exits.Load())[addr.Unmap()]
+	if !ok || (l.port != 0 && !exit.Policy.Allows(l.port)) {
+		return Match{}
+	}
+	return Match{IsTor: true, Fingerprint: exit.Fingerprint}
+}
+
+// Len returns the number of known exit addresses.
+func (l *List) Len() int {
+	return len(*l.exits.Load())
+}
+
+// Refresh reloads the list from its source. On error the previous list
+// stays in use.
+func (l *List) Refresh(ctx context.Context) error {
+	body, err := l.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	exits, err := Parse(body)
+	if err != nil {
+		return fmt.Errorf("parse tor exit list: %w", err)
+	}
+	l.exits.Store(&exits)
+	return nil
+}
+
+// Watch refreshes the list every interval until ctx is done.
+func (l *List) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Refresh(ctx); err != nil && onError != nil {
+				onError(fmt.Errorf("refresh tor exit list: %w", err))
+			}
+		}
+	}
+}
+
+func (l *List) open(ctx context.Context) (io.ReadCloser, error) {
+	if !strings.HasPrefix(l.source, "http://") && !strings.HasPrefix(l.source, "https://") {
+		file, err := os.Open(l.source)
+		if err != nil {
+			return nil, fmt.Errorf("open tor exit list: %w", err)
+		}
+		return file, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := l.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch tor exit list: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch tor exit list: unexpected status: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
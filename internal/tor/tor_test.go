@@ -0,0 +1,58 @@
+package tor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestList_RefreshFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exit-addresses")
+	os.WriteFile(path, []byte("ExitNode ABCD\nExitAddress 162.247.74.201 2024-01-01 13:09:54\n"), 0o644)
+
+	list := NewList(path, 0)
+	if err := list.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if got := list.Lookup("162.247.74.201"); !got.IsTor || got.Fingerprint != "ABCD" {
+		t.Errorf("expected tor exit, got %+v", got)
+	}
+	if got := list.Lookup("192.0.2.1"); got.IsTor {
+		t.Errorf("expected non-exit, got %+v", got)
+	}
+
+	os.WriteFile(path, []byte("garbage\n"), 0o644)
+	if err := list.Refresh(context.Background()); err == nil {
+		t.Error("expected error for broken list")
+	}
+	if !list.Lookup("162.247.74.201").IsTor {
+		t.Error("expected previous list to stay in use")
+	}
+}
+
+func TestList_RefreshURLWithPolicy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"relays": [
+			{"fingerprint": "WEB", "or_addresses": ["10.0.0.1:9001"], "exit_policy_summary": {"accept": ["80", "443"]}},
+			{"fingerprint": "IRC", "or_addresses": ["10.0.0.2:9001"], "exit_policy_summary": {"accept": ["6667"]}}
+		]}`))
+	}))
+	defer ts.Close()
+
+	list := NewList(ts.URL, 443)
+	if err := list.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if list.Len() != 2 {
+		t.Errorf("expected 2 exits, got %d", list.Len())
+	}
+	if !list.Lookup("10.0.0.1").IsTor {
+		t.Error("expected exit allowing port 443 to match")
+	}
+	if list.Lookup("10.0.0.2").IsTor {
+		t.Error("expected exit rejecting port 443 not to match")
+	}
+}
@@ -0,0 +1,202 @@
+package useragent
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rule is one entry of a uap-core parser list. replacements are indexed
+// by the keys of its list, e.g. family, v1, v2 and v3 for user agents.
+type rule struct {
+	re           *regexp.Regexp
+	replacements []string
+}
+
+var (
+	uaKeys     = []string{"family_replacement", "v1_replacement", "v2_replacement", "v3_replacement"}
+	osKeys     = []string{"os_replacement", "os_v1_replacement", "os_v2_replacement", "os_v3_replacement"}
+	deviceKeys = []string{"device_replacement", "brand_replacement", "model_replacement"}
+)
+
+// rules are the three parser lists of a regexes.yaml file.
+type rules struct {
+	ua, os, device []rule
+	// skipped counts patterns Go's RE2 engine cannot compile, such as
+	// lookarounds.
+	skipped int
+}
+
+// parseRegexes reads the subset of YAML used by uap-core's regexes.yaml:
+// top level lists of flat mappings with plain, single or double quoted
+// scalar values.
+func parseRegexes(data []byte) (rules, error) {
+	var parsed rules
+	var section string
+	var entry map[string]string
+	flush := func() {
+		if entry == nil {
+			return
+		}
+		var keys []string
+		var target *[]rule
+		switch section {
+		case "user_agent_parsers":
+			keys, target = uaKeys, &parsed.ua
+		case "os_parsers":
+			keys, target = osKeys, &parsed.os
+		case "device_parsers":
+			keys, target = deviceKeys, &parsed.device
+		default:
+			entry = nil
+			return
+		}
+		pattern := entry["regex"]
+		if entry["regex_flag"] == "i" {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			parsed.skipped++
+			entry = nil
+			return
+		}
+		r := rule{re: re, replacements: make([]string, len(keys))}
+		for i, key := range keys {
+			r.replacements[i] = entry[key]
+		}
+		*target = append(*target, r)
+		entry = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if trimmed == line {
+			name, rest, ok := strings.Cut(line, ":")
+			if !ok || strings.TrimSpace(rest) != "" {
+				return rules{}, fmt.Errorf("line %d: expected a section name", n)
+			}
+			flush()
+			section = name
+			continue
+		}
+
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok {
+			flush()
+			entry = map[string]string{}
+			trimmed = strings.TrimLeft(item, " ")
+		}
+		if entry == nil {
+			return rules{}, fmt.Errorf("line %d: value outside a list entry", n)
+		}
+		key, raw, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return rules{}, fmt.Errorf("line %d: expected key: value", n)
+		}
+		value, err := parseScalar(strings.TrimSpace(raw))
+		if err != nil {
+			return rules{}, fmt.Errorf("line %d: %w", n, err)
+		}
+		entry[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return rules{}, err
+	}
+	flush()
+
+	if len(parsed.ua) == 0 && len(parsed.os) == 0 && len(parsed.device) == 0 {
+		return rules{}, fmt.Errorf("no parsers found")
+	}
+	return parsed, nil
+}
+
+func parseScalar(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		var b strings.Builder
+		for i := 1; i < len(raw); i++ {
+			if raw[i] != '\'' {
+				b.WriteByte(raw[i])
+				continue
+			}
+			if i+1 < len(raw) && raw[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), nil
+		}
+		return "", fmt.Errorf("unterminated single quoted string")
+	case strings.HasPrefix(raw, `"`):
+		end := 1
+		for ; end < len(raw); end++ {
+			if raw[end] == '\\' {
+				end++
+			} else if raw[end] == '"' {
+				break
+			}
+		}
+		if end >= len(raw) {
+			return "", fmt.Errorf("unterminated double quoted string")
+		}
+		return strconv.Unquote(raw[:end+1])
+	default:
+		value, _, _ := strings.Cut(raw, " #")
+		return strings.TrimSpace(value), nil
+	}
+}
+
+// match applies the first rule of list matching s. Replacement i defaults
+// to capture group i+1, or to fallback[i] when set; "$n" in replacements
+// is substituted with group n.
+func match(list []rule, s string, fallback map[int]int) ([]string, bool) {
+	for _, r := range list {
+		groups := r.re.FindStringSubmatch(s)
+		if groups == nil {
+			continue
+		}
+		values := make([]string, len(r.replacements))
+		for i, replacement := range r.replacements {
+			group := i + 1
+			if g, ok := fallback[i]; ok {
+				group = g
+			}
+			switch {
+			case replacement != "":
+				values[i] = strings.TrimSpace(substitute(replacement, groups))
+			case group > 0 && group < len(groups):
+				values[i] = groups[group]
+			}
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+func substitute(replacement string, groups []string) string {
+	if !strings.Contains(replacement, "$") {
+		return replacement
+	}
+	var b strings.Builder
+	for i := 0; i < len(replacement); i++ {
+		c := replacement[i]
+		if c == '$' && i+1 < len(replacement) && replacement[i+1] >= '1' && replacement[i+1] <= '9' {
+			if n := int(replacement[i+1] - '0'); n < len(groups) {
+				b.WriteString(groups[n])
+			}
+			i++
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package useragent
+
+import "testing"
+
+func TestParseRegexes(t *testing.T) {
+	data := []byte(`# comment
+user_agent_parsers:
+  - regex: '(Foo)/(\d+)'
+
+  - regex: "(Bar)\\/(\\d+)" # trailing comment
+    family_replacement: 'Bar''s $1'
+  - regex: '(?<=x)lookbehind'
+
+os_parsers:
+  - regex: (Baz) (\d+)
+    os_replacement: Baz OS
+
+device_parsers:
+  - regex: 'phone (\w+)'
+    regex_flag: 'i'
+    brand_replacement: 'Acme'
+`)
+	parsed, err := parseRegexes(data)
+	if err != nil {
+		t.Fatalf("parseRegexes failed: %v", err)
+	}
+	if len(parsed.ua) != 2 || len(parsed.os) != 1 || len(parsed.device) != 1 || parsed.skipped != 1 {
+		t.Fatalf("unexpected rules %+v", parsed)
+	}
+
+	values, ok := match(parsed.ua, "Bar/7", nil)
+	if !ok || values[0] != "Bar's Bar" || values[1] != "7" {
+		t.Errorf("unexpected user agent match %q", values)
+	}
+	values, ok = match(parsed.os, "Baz 3", nil)
+	if !ok || values[0] != "Baz OS" || values[1] != "3" {
+		t.Errorf("unexpected os match %q", values)
+	}
+	values, ok = match(parsed.device, "PHONE X1", map[int]int{1: 0, 2: 1})
+	if !ok || values[0] != "X1" || values[1] != "Acme" || values[2] != "X1" {
+		t.Errorf("unexpected device match %q", values)
+	}
+}
+
+func TestParseRegexes_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"empty":         "",
+		"no section":    "  - regex: 'x'\n",
+		"unterminated":  "user_agent_parsers:\n  - regex: 'x\n",
+		"missing colon": "user_agent_parsers:\n  - regex\n",
+	}
+	for name, data := range tests {
+		if _, err := parseRegexes([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Package useragent parses User-Agent strings into browser, operating
+// system, device and bot details using a uap-core regexes.yaml database.
+package useragent
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"myip/internal/rdns"
+)
+
+//go:embed regexes.yaml
+var defaultRegexes []byte
+
+const (
+	// maxCached bounds the in-memory cache of parsed strings.
+	maxCached = 10000
+	// maxLength caps the part of a User-Agent that is parsed and cached.
+	maxLength = 1024
+)
+
+// Device types.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceTV      = "tv"
+	DeviceConsole = "console"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+// Bot categories.
+const (
+	BotSearch  = "search"
+	BotCrawler = "crawler"
+	BotTool    = "tool"
+)
+
+// Browser is the user agent family and version.
+type Browser struct {
+	Family  string `json:"family"`
+	Version string `json:"version,omitempty"`
+}
+
+// OS is the operating system family and version.
+type OS struct {
+	Family  string `json:"family"`
+	Version string `json:"version,omitempty"`
+}
+
+// Device describes the hardware.
+type Device struct {
+	Family string `json:"family"`
+	Brand  string `json:"brand,omitempty"`
+	Model  string `json:"model,omitempty"`
+	Type   string `json:"type"`
+}
+
+// Bot describes an automated client.
+type Bot struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// Verified is set by VerifyBot for search engines that publish the
+	// reverse DNS domains of their crawlers.
+	Verified *bool `json:"verified,omitempty"`
+
+	domains []string
+}
+
+// Info is the parsed User-Agent.
+type Info struct {
+	Browser Browser `json:"browser"`
+	OS      OS      `json:"os"`
+	Device  Device  `json:"device"`
+	Bot     *Bot    `json:"bot,omitempty"`
+}
+
+// searchBots lists the reverse DNS domains search engines document for
+// verifying their crawlers.
+var searchBots = map[string][]string{
+	"Googlebot":              {"googlebot.com", "google.com", "googleusercontent.com"},
+	"Googlebot-Image":        {"googlebot.com", "google.com", "googleusercontent.com"},
+	"Googlebot-Video":        {"googlebot.com", "google.com", "googleusercontent.com"},
+	"Googlebot-News":         {"googlebot.com", "google.com", "googleusercontent.com"},
+	"AdsBot-Google":          {"googlebot.com", "google.com", "googleusercontent.com"},
+	"AdsBot-Google-Mobile":   {"googlebot.com", "google.com", "googleusercontent.com"},
+	"Google-InspectionTool":  {"googlebot.com", "google.com", "googleusercontent.com"},
+	"bingbot":                {"search.msn.com"},
+	"BingPreview":            {"search.msn.com"},
+	"adidxbot":               {"search.msn.com"},
+	"YandexBot":              {"yandex.ru", "yandex.net", "yandex.com"},
+	"YandexImages":           {"yandex.ru", "yandex.net", "yandex.com"},
+	"YandexMobileBot":        {"yandex.ru", "yandex.net", "yandex.com"},
+	"YandexAccessibilityBot": {"yandex.ru", "yandex.net", "yandex.com"},
+	"Baiduspider":            {"crawl.baidu.com", "crawl.baidu.jp"},
+	"Applebot":               {"applebot.apple.com"},
+	"SeznamBot":              {"seznam.cz"},
+	"PetalBot":               {"petalsearch.com"},
+}
+
+// tools are HTTP clients and libraries that uap-core does not mark as
+// spiders.
+var tools = []string{
+	"curl", "Wget", "Python Requests", "Python-urllib", "Python aiohttp", "Python httpx", "Go-http-client",
+	"HTTPie", "PostmanRuntime", "okhttp", "Apache-HttpClient", "axios", "node-fetch", "undici", "libwww-perl",
+	"PowerShell", "HeadlessChrome",
+}
+
+var tvPattern = regexp.MustCompile(`SmartTV|SMART-TV|Smart TV|Web0S|AppleTV|CrKey|BRAVIA|HbbTV|GoogleTV|Tizen.+TV`)
+
+// Parser parses User-Agent strings and caches the results.
+type Parser struct {
+	rules rules
+
+	mu    sync.Mutex
+	cache map[string]Info
+}
+
+// Default returns a parser using the embedded database.
+func Default() *Parser {
+	parser, err := Load(defaultRegexes)
+	if err != nil {
+		panic("useragent: embedded regexes: " + err.Error())
+	}
+	return parser
+}
+
+// Open loads a uap-core regexes.yaml file.
+func Open(path string) (*Parser, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read user agent regexes: %w", err)
+	}
+	parser, err := Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return parser, nil
+}
+
+// Load parses a uap-core regexes.yaml database.
+func Load(data []byte) (*Parser, error) {
+	parsed, err := parseRegexes(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Parser{rules: parsed, cache: map[string]Info{}}, nil
+}
+
+// Skipped returns how many patterns of the database were dropped because
+// Go's regexp syntax does not support them.
+func (p *Parser) Skipped() int {
+	return p.rules.skipped
+}
+
+// Parse returns the details of userAgent.
+func (p *Parser) Parse(userAgent string) Info {
+	if len(userAgent) > maxLength {
+		userAgent = userAgent[:maxLength]
+	}
+	p.mu.Lock()
+	info, ok := p.cache[userAgent]
+	p.mu.Unlock()
+	if !ok {
+		info = p.parse(userAgent)
+		p.mu.Lock()
+		if len(p.cache) >= maxCached {
+			// Drop an arbitrary entry; popular strings are re-added quickly.
+			for key := range p.cache {
+				delete(p.cache, key)
+				break
+			}
+		}
+		p.cache[userAgent] = info
+		p.mu.Unlock()
+	}
+
+	// Callers may verify the bot, so they get their own copy.
+	if info.Bot != nil {
+		bot := *info.Bot
+		info.Bot = &bot
+	}
+	return info
+}
+
+func (p *Parser) parse(userAgent string) Info {
+	info := Info{
+		Browser: Browser{Family: "Other"},
+		OS:      OS{Family: "Other"},
+		Device:  Device{Family: "Other"},
+	}
+	if values, ok := match(p.rules.ua, userAgent, nil); ok && values[0] != "" {
+		info.Browser = Browser{Family: values[0], Version: version(values[1:])}
+	}
+	if values, ok := match(p.rules.os, userAgent, nil); ok && values[0] != "" {
+		info.OS = OS{Family: values[0], Version: version(values[1:])}
+	}
+	// Device models default to the first group and brands to nothing.
+	if values, ok := match(p.rules.device, userAgent, map[int]int{1: 0, 2: 1}); ok && values[0] != "" {
+		info.Device = Device{Family: values[0], Brand: values[1], Model: values[2]}
+	}
+
+	switch {
+	case info.Device.Family == "Spider":
+		info.Bot = &Bot{Name: info.Browser.Family, Category: BotCrawler}
+		if domains, ok := searchBots[info.Browser.Family]; ok {
+			info.Bot.Category = BotSearch
+			info.Bot.domains = domains
+		}
+	case slices.Contains(tools, info.Browser.Family):
+		info.Bot = &Bot{Name: info.Browser.Family, Category: BotTool}
+	}
+	if info.Bot != nil && info.Bot.Name == "Other" {
+		info.Bot.Name = info.Device.Family
+	}
+	info.Device.Type = deviceType(info, userAgent)
+	return info
+}
+
+func version(parts []string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part == "" {
+			break
+		}
+		nonEmpty = append(nonEmpty, part)
+	}
+	return strings.Join(nonEmpty, ".")
+}
+
+// deviceType classifies the device from the parsed fields, falling back to
+// User-Agent markers uap-core has no field for.
+func deviceType(info Info, userAgent string) string {
+	device := info.Device.Family
+	switch {
+	case info.Bot != nil:
+		return DeviceBot
+	case strings.Contains(device, "iPad"):
+		return DeviceTablet
+	case strings.Contains(device, "PlayStation"), strings.Contains(device, "Xbox"), strings.Contains(device, "Nintendo"):
+		return DeviceConsole
+	case device == "Smart TV", tvPattern.MatchString(userAgent):
+		return DeviceTV
+	case info.OS.Family == "Android":
+		if strings.Contains(userAgent, "Mobile") {
+			return DeviceMobile
+		}
+		return DeviceTablet
+	case info.OS.Family == "iOS", info.OS.Family == "Windows Phone", strings.Contains(userAgent, "Mobi"):
+		return DeviceMobile
+	}
+	switch info.OS.Family {
+	case "Windows", "Mac OS X", "Linux", "Chrome OS", "Ubuntu", "Fedora", "Debian", "FreeBSD", "OpenBSD":
+		return DeviceDesktop
+	}
+	return DeviceUnknown
+}
+
+// ReverseDNS resolves the forward-confirmed PTR name of an address.
+type ReverseDNS interface {
+	Lookup(ctx context.Context, ip string) (rdns.Result, error)
+}
+
+// VerifyBot checks a search engine crawler claimed by the User-Agent
+// against the forward-confirmed reverse DNS of ip, as the engines
+// recommend. Other bots are left unverified.
+func VerifyBot(ctx context.Context, bot *Bot, ip string, resolver ReverseDNS) error {
+	if bot == nil || len(bot.domains) == 0 {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(ip); err != nil || !addr.IsGlobalUnicast() {
+		verified := false
+		bot.Verified = &verified
+		return nil
+	}
+	result, err := resolver.Lookup(ctx, ip)
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", bot.Name, err)
+	}
+	verified := result.FCrDNS && matchesDomain(result.Hostname, bot.domains)
+	bot.Verified = &verified
+	return nil
+}
+
+func matchesDomain(hostname string, domains []string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	for _, domain := range domains {
+		if strings.HasSuffix(hostname, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
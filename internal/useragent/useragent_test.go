@@ -0,0 +1,159 @@
+package useragent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"myip/internal/rdns"
+)
+
+func TestParser_Parse(t *testing.T) {
+	parser := Default()
+	if parser.Skipped() != 0 {
+		t.Errorf("embedded database has %d patterns Go cannot compile", parser.Skipped())
+	}
+
+	tests := []struct {
+		ua                 string
+		browser, version   string
+		os, osVersion      string
+		device, deviceType string
+		bot, botCategory   string
+	}{
+		{
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.91 Safari/537.36",
+			browser: "Chrome", version: "124.0.6367", os: "Windows", osVersion: "10", device: "Other", deviceType: DeviceDesktop,
+		},
+		{
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			browser: "Edge", version: "124.0.2478", os: "Windows", osVersion: "10", device: "Other", deviceType: DeviceDesktop,
+		},
+		{
+			ua:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15",
+			browser: "Safari", version: "17.4.1", os: "Mac OS X", osVersion: "10.15.7", device: "Mac", deviceType: DeviceDesktop,
+		},
+		{
+			ua:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1",
+			browser: "Mobile Safari", version: "17.4.1", os: "iOS", osVersion: "17.4.1", device: "iPhone", deviceType: DeviceMobile,
+		},
+		{
+			ua:      "Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.82 Mobile Safari/537.36",
+			browser: "Chrome Mobile", version: "124.0.6367", os: "Android", osVersion: "14", device: "Samsung SM-S918B", deviceType: DeviceMobile,
+		},
+		{
+			ua:      "Mozilla/5.0 (Android 14; Tablet; rv:125.0) Gecko/125.0 Firefox/125.0",
+			browser: "Firefox Mobile", version: "125.0", os: "Android", osVersion: "14", device: "Other", deviceType: DeviceTablet,
+		},
+		{
+			ua:      "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			browser: "Firefox", version: "125.0", os: "Ubuntu", device: "Other", deviceType: DeviceDesktop,
+		},
+		{
+			ua:      "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			browser: "Googlebot", version: "2.1", os: "Other", device: "Spider", deviceType: DeviceBot, bot: "Googlebot", botCategory: BotSearch,
+		},
+		{
+			ua:      "Mozilla/5.0 (compatible; ExampleBot/1.0; +https://example.com/bot)",
+			browser: "Other", os: "Other", device: "Spider", deviceType: DeviceBot, bot: "Spider", botCategory: BotCrawler,
+		},
+		{
+			ua:      "curl/8.5.0",
+			browser: "curl", version: "8.5.0", os: "Other", device: "Other", deviceType: DeviceBot, bot: "curl", botCategory: BotTool,
+		},
+		{
+			ua:      "python-requests/2.31.0",
+			browser: "Python Requests", version: "2.31.0", os: "Other", device: "Other", deviceType: DeviceBot, bot: "Python Requests", botCategory: BotTool,
+		},
+		{
+			ua:      "Mozilla/5.0 (SMART-TV; Linux; Tizen 6.0) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/4.0 Chrome/76.0.3809.146 TV Safari/537.36",
+			browser: "Samsung Internet", version: "4.0", os: "Tizen", osVersion: "6.0", device: "Smart TV", deviceType: DeviceTV,
+		},
+	}
+	for _, tt := range tests {
+		info := parser.Parse(tt.ua)
+		if info.Browser.Family != tt.browser || info.Browser.Version != tt.version {
+			t.Errorf("%s: browser %+v, want %s %s", tt.ua, info.Browser, tt.browser, tt.version)
+		}
+		if info.OS.Family != tt.os || info.OS.Version != tt.osVersion {
+			t.Errorf("%s: os %+v, want %s %s", tt.ua, info.OS, tt.os, tt.osVersion)
+		}
+		if info.Device.Family != tt.device || info.Device.Type != tt.deviceType {
+			t.Errorf("%s: device %+v, want %s %s", tt.ua, info.Device, tt.device, tt.deviceType)
+		}
+		switch {
+		case tt.bot == "" && info.Bot != nil:
+			t.Errorf("%s: unexpected bot %+v", tt.ua, info.Bot)
+		case tt.bot != "" && (info.Bot == nil || info.Bot.Name != tt.bot || info.Bot.Category != tt.botCategory):
+			t.Errorf("%s: bot %+v, want %s %s", tt.ua, info.Bot, tt.bot, tt.botCategory)
+		}
+	}
+}
+
+func TestParser_Cache(t *testing.T) {
+	parser := Default()
+	ua := "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)"
+	first := parser.Parse(ua)
+	verified := true
+	first.Bot.Verified = &verified
+
+	// Cached results are copied, so verifying one does not leak into the
+	// next caller.
+	if second := parser.Parse(ua); second.Bot.Verified != nil {
+		t.Error("expected the cached bot to stay unverified")
+	}
+	if len(parser.cache) != 1 {
+		t.Errorf("expected one cached entry, got %d", len(parser.cache))
+	}
+
+	parser.Parse(strings.Repeat("x", 2*maxLength))
+	for key := range parser.cache {
+		if len(key) > maxLength {
+			t.Errorf("cached key of %d bytes", len(key))
+		}
+	}
+}
+
+type mockResolver struct {
+	result rdns.Result
+	err    error
+}
+
+func (m mockResolver) Lookup(ctx context.Context, ip string) (rdns.Result, error) {
+	return m.result, m.err
+}
+
+func TestVerifyBot(t *testing.T) {
+	googlebot := "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	tests := map[string]struct {
+		ip     string
+		result rdns.Result
+		want   bool
+	}{
+		"genuine":        {"66.249.66.1", rdns.Result{Hostname: "crawl-66-249-66-1.googlebot.com.", FCrDNS: true}, true},
+		"not confirmed":  {"66.249.66.1", rdns.Result{Hostname: "crawl-66-249-66-1.googlebot.com", FCrDNS: false}, false},
+		"other domain":   {"198.51.100.1", rdns.Result{Hostname: "host.googlebot.com.example.net", FCrDNS: true}, false},
+		"private source": {"10.0.0.1", rdns.Result{}, false},
+	}
+	for name, tt := range tests {
+		info := Default().Parse(googlebot)
+		if err := VerifyBot(context.Background(), info.Bot, tt.ip, mockResolver{result: tt.result}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if info.Bot.Verified == nil || *info.Bot.Verified != tt.want {
+			t.Errorf("%s: verified %v, want %v", name, info.Bot.Verified, tt.want)
+		}
+	}
+
+	info := Default().Parse(googlebot)
+	if err := VerifyBot(context.Background(), info.Bot, "66.249.66.1", mockResolver{err: errors.New("timeout")}); err == nil || info.Bot.Verified != nil {
+		t.Errorf("expected lookup error to leave the bot unverified, got %v", err)
+	}
+
+	info = Default().Parse("curl/8.5.0")
+	VerifyBot(context.Background(), info.Bot, "198.51.100.1", mockResolver{})
+	if info.Bot.Verified != nil {
+		t.Error("expected tools to stay unverified")
+	}
+}
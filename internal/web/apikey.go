@@ -0,0 +1,141 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"myip/internal/apikey"
+)
+
+// KeyManager authorizes API keys and reports their usage.
+type KeyManager interface {
+	Authorize(ctx context.Context, secret string) (apikey.Quota, error)
+	AuthorizeN(ctx context.Context, secret string, n int64) (apikey.Quota, error)
+	Usage(ctx context.Context, secret string) (apikey.Key, []apikey.DayUsage, error)
+}
+
+// authorizedKeyKey is the context key of the API key RequireAPIKey
+// accepted.
+type authorizedKeyKey struct{}
+
+// RequireAPIKey lets self-lookups through anonymously but requires a key
+// with remaining daily quota for ?ip= lookups of other addresses. The
+// accepted key is passed on so that RateLimit can charge its bucket. Batch
+// lookups are charged per address by BatchHandler instead.
+func RequireAPIKey(next http.Handler, keys KeyManager, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isArbitraryLookup(r) || r.URL.Path == BatchPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		quota, err := keys.Authorize(ctx, apiKeySecret(r))
+		cancel()
+		if !authorized(w, quota, err, onError) {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authorizedKeyKey{}, quota.Key)))
+	})
+}
+
+// authorizedKey returns the API key RequireAPIKey accepted for r.
+func authorizedKey(r *http.Request) (apikey.Key, bool) {
+	key, ok := r.Context().Value(authorizedKeyKey{}).(apikey.Key)
+	return key, ok
+}
+
+// isArbitraryLookup reports whether r asks about an address other than
+// the caller's own. Addresses are compared as values so that other
+// spellings of the caller's address, like ::ffff:1.2.3.4, count as self.
+func isArbitraryLookup(r *http.Request) bool {
+	if isLookupPath(r.URL.Path) {
+		return true
+	}
+	ip := lookupIP(r)
+	if ip == "" {
+		return false
+	}
+	target, err := netip.ParseAddr(ip)
+	if err != nil {
+		return true
+	}
+	self, err := netip.ParseAddr(requestIP(r))
+	return err != nil || target.Unmap() != self.Unmap()
+}
+
+// authorized writes quota headers and, when the key was rejected, the
+// error response. It reports whether the request may proceed.
+func authorized(w http.ResponseWriter, quota apikey.Quota, err error, onError func(error)) bool {
+	setQuotaHeaders(w.Header(), quota)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, apikey.ErrMissingKey), errors.Is(err, apikey.ErrInvalidKey):
+		writeJSONError(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, apikey.ErrQuotaExceeded):
+		w.Header().Set("Retry-After", strconv.Itoa(int(quota.Reset.Seconds())))
+		writeJSONError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		if onError != nil {
+			onError(err)
+		}
+		writeJSONError(w, http.StatusServiceUnavailable, "api key check unavailable")
+	}
+	return false
+}
+
+type usageResponse struct {
+	Name       string            `json:"name"`
+	ID         string            `json:"id"`
+	DailyQuota int64             `json:"daily_quota"`
+	Usage      []apikey.DayUsage `json:"usage"`
+}
+
+// UsageHandler returns the daily usage of the API key sent with the request.
+func UsageHandler(keys KeyManager, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+
+		key, usage, err := keys.Usage(ctx, apiKeySecret(r))
+		if err != nil {
+			authorized(w, apikey.Quota{}, err, onError)
+			return
+		}
+
+		payload := usageResponse{Name: key.Name, ID: key.ID, DailyQuota: key.DailyQuota, Usage: usage}
+		if err := writeJSON(w, http.StatusOK, payload); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+// apiKeySecret reads the key from X-API-Key, a bearer token or ?key=.
+func apiKeySecret(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.URL.Query().Get("key"))
+}
+
+func setQuotaHeaders(h http.Header, quota apikey.Quota) {
+	if quota.Key.ID == "" {
+		return
+	}
+	h.Set("X-Quota-Used", strconv.FormatInt(quota.Used, 10))
+	if quota.Key.DailyQuota > 0 {
+		h.Set("X-Quota-Limit", strconv.FormatInt(quota.Key.DailyQuota, 10))
+		h.Set("X-Quota-Remaining", strconv.FormatInt(quota.Remaining, 10))
+		h.Set("X-Quota-Reset", strconv.Itoa(int(quota.Reset.Seconds())))
+	}
+}
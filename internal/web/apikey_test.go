@@ -0,0 +1,94 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myip/internal/apikey"
+)
+
+type mockKeyManager struct {
+	authorize  func(ctx context.Context, secret string) (apikey.Quota, error)
+	authorizeN func(ctx context.Context, secret string, n int64) (apikey.Quota, error)
+}
+
+func (m *mockKeyManager) Authorize(ctx context.Context, secret string) (apikey.Quota, error) {
+	return m.authorize(ctx, secret)
+}
+
+func (m *mockKeyManager) AuthorizeN(ctx context.Context, secret string, n int64) (apikey.Quota, error) {
+	return m.authorizeN(ctx, secret, n)
+}
+
+func (m *mockKeyManager) Usage(ctx context.Context, secret string) (apikey.Key, []apikey.DayUsage, error) {
+	return apikey.Key{}, nil, apikey.ErrInvalidKey
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	keys := &mockKeyManager{
+		authorize: func(ctx context.Context, secret string) (apikey.Quota, error) {
+			if secret != "s3cret" {
+				return apikey.Quota{}, apikey.ErrInvalidKey
+			}
+			key := apikey.Key{ID: "abc", DailyQuota: 10}
+			return apikey.Quota{Key: key, Used: 3, Remaining: 7}, nil
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := TrustProxies(RequireAPIKey(next, keys, nil), nil)
+
+	tests := []struct {
+		name      string
+		url       string
+		remote    string
+		header    string
+		forwarded string
+		expected  int
+	}{
+		{name: "Self lookup", url: "/", expected: http.StatusOK},
+		{name: "Self lookup via ip", url: "/?ip=1.2.3.4", expected: http.StatusOK},
+		{name: "Self lookup via mapped ip", url: "/?ip=::ffff:1.2.3.4", expected: http.StatusOK},
+		{name: "Self lookup via long IPv6", url: "/?ip=2001:0db8:0:0:0:0:0:0001", remote: "[2001:db8::1]:1234", expected: http.StatusOK},
+		{name: "Lookup of a neighbour", url: "/?ip=2001:db8::2", remote: "[2001:db8::1]:1234", expected: http.StatusUnauthorized},
+		{name: "Lookup without key", url: "/?ip=8.8.8.8", expected: http.StatusUnauthorized},
+		{name: "Lookup with query key", url: "/?ip=8.8.8.8&key=s3cret", expected: http.StatusOK},
+		{name: "Lookup with header key", url: "/?ip=8.8.8.8", header: "s3cret", expected: http.StatusOK},
+		{name: "Lookup with forged X-Forwarded-For", url: "/?ip=8.8.8.8", forwarded: "8.8.8.8", expected: http.StatusUnauthorized},
+		{name: "Prefix without key", url: PrefixPath + "10.0.0.0/8", expected: http.StatusUnauthorized},
+		{name: "Summarize without key", url: SummarizePath, expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.RemoteAddr = "1.2.3.4:1234"
+			if tt.remote != "" {
+				req.RemoteAddr = tt.remote
+			}
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?ip=8.8.8.8", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("X-Quota-Remaining") != "7" {
+		t.Errorf("expected X-Quota-Remaining 7, got %q", rec.Header().Get("X-Quota-Remaining"))
+	}
+}
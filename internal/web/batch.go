@@ -0,0 +1,267 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"myip/internal/ratelimit"
+)
+
+// BatchPath is the route of the batch lookup endpoint.
+const BatchPath = "/api/batch"
+
+const (
+	defaultBatchMaxItems    = 1000
+	defaultBatchConcurrency = 8
+	// maxBatchLineBytes bounds a single address line; IPv6 with zone fits easily.
+	maxBatchLineBytes = 128
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// BatchOptions limits the size and parallelism of batch lookups. With
+// Keys and Limiter set, a batch costs one API key quota unit and one
+// lookup rate limit token per distinct address, reserved up front.
+type BatchOptions struct {
+	MaxItems    int
+	Concurrency int
+	Keys        KeyManager
+	Limiter     RateLimiter
+}
+
+type batchResult struct {
+	*apiResponse
+	IP    string `json:"ip"`
+	Error string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// BatchHandler resolves a JSON array or newline separated list of
+// addresses through the service with bounded parallelism. Duplicate
+// addresses are fetched once. With "Accept: application/x-ndjson" or
+// ?stream=1 results are streamed as they complete instead of returned in
+// input order.
+func BatchHandler(service Service, opts BatchOptions) http.Handler {
+	if opts.MaxItems <= 0 {
+		opts.MaxItems = defaultBatchMaxItems
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultBatchConcurrency
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := http.MaxBytesReader(w, r.Body, int64(opts.MaxItems*maxBatchLineBytes))
+		ips, err := parseBatch(body, r.Header.Get("Content-Type"), opts.MaxItems)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !chargeBatch(w, r, opts, service.OnError, distinctAddresses(ips)) {
+			return
+		}
+
+		if wantsStream(r) {
+			streamBatch(w, r.Context(), service, ips, opts.Concurrency)
+			return
+		}
+
+		results := make([]batchResult, len(ips))
+		resolveBatch(r.Context(), service, ips, opts.Concurrency, func(i int, result batchResult) {
+			results[i] = result
+		})
+		if err := writeJSON(w, http.StatusOK, batchResponse{Results: results}); err != nil {
+			service.OnError(err)
+		}
+	})
+}
+
+// chargeBatch reserves n lookups against the caller's API key quota and
+// rate limit and writes the rejection when either is exhausted. A batch
+// the lookup bucket could never hold is refused before anything is
+// charged, and the key is checked before any token is taken from its
+// bucket.
+func chargeBatch(w http.ResponseWriter, r *http.Request, opts BatchOptions, onError func(error), n int) bool {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	if opts.Limiter != nil {
+		if burst, ok := opts.Limiter.Capacity(ratelimit.ClassLookup, requestIP(r)); ok && n > burst {
+			writeJSONError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("batch of %d addresses exceeds the lookup rate limit burst of %d; split it", n, burst))
+			return false
+		}
+	}
+	var keyID string
+	if opts.Keys != nil {
+		quota, err := opts.Keys.AuthorizeN(ctx, apiKeySecret(r), int64(n))
+		if !authorized(w, quota, err, onError) {
+			return false
+		}
+		keyID = quota.Key.ID
+	}
+	if opts.Limiter != nil {
+		result, err := allowLookups(ctx, opts.Limiter, r, keyID, n)
+		if err != nil {
+			onError(err)
+		}
+		result.SetHeaders(w.Header())
+		if !result.Allowed {
+			writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded for %d addresses", n))
+			return false
+		}
+	}
+	return true
+}
+
+// distinctAddresses counts the addresses resolveBatch will fetch.
+func distinctAddresses(ips []string) int {
+	seen := map[string]bool{}
+	for _, ip := range ips {
+		if net.ParseIP(ip) != nil {
+			seen[ip] = true
+		}
+	}
+	return len(seen)
+}
+
+func streamBatch(w http.ResponseWriter, ctx context.Context, service Service, ips []string, concurrency int) {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	var mu sync.Mutex
+	resolveBatch(ctx, service, ips, concurrency, func(i int, result batchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := encoder.Encode(result); err != nil {
+			service.OnError(err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+}
+
+// resolveBatch fetches every distinct valid address once and reports the
+// result for each input position through emit. Addresses not started
+// when ctx ends are reported as canceled.
+func resolveBatch(ctx context.Context, service Service, ips []string, concurrency int, emit func(int, batchResult)) {
+	positions := map[string][]int{}
+	var unique []string
+	for i, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			emit(i, batchResult{IP: ip, Error: "invalid ip address"})
+			continue
+		}
+		if _, ok := positions[ip]; !ok {
+			unique = append(unique, ip)
+		}
+		positions[ip] = append(positions[ip], i)
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for n, ip := range unique {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			for _, ip := range unique[n:] {
+				for _, i := range positions[ip] {
+					emit(i, batchResult{IP: ip, Error: "canceled"})
+				}
+			}
+			break
+		}
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := fetchBatchItem(ctx, service, ip)
+			for _, i := range positions[ip] {
+				emit(i, result)
+			}
+		}(ip)
+	}
+	wg.Wait()
+}
+
+func fetchBatchItem(ctx context.Context, service Service, ip string) batchResult {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	response, err := service.Fetch(ctx, ip)
+	if err == nil {
+		err = response.Error
+	}
+	if err != nil {
+		service.OnError(fmt.Errorf("batch %s: %w", ip, err))
+		return batchResult{IP: ip, Error: "lookup failed"}
+	}
+
+	payload := newAPIResponse(response)
+	return batchResult{apiResponse: &payload, IP: ip}
+}
+
+func parseBatch(body io.Reader, contentType string, maxItems int) ([]string, error) {
+	var ips []string
+	if strings.Contains(strings.ToLower(contentType), "json") {
+		if err := json.NewDecoder(body).Decode(&ips); err != nil {
+			return nil, batchBodyError(err)
+		}
+	} else {
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			for _, field := range strings.FieldsFunc(scanner.Text(), isBatchSeparator) {
+				ips = append(ips, field)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, batchBodyError(err)
+		}
+	}
+
+	for i := range ips {
+		ips[i] = strings.TrimSpace(ips[i])
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("no addresses given")
+	}
+	if len(ips) > maxItems {
+		return nil, fmt.Errorf("too many addresses: %d, max %d", len(ips), maxItems)
+	}
+	return ips, nil
+}
+
+func batchBodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errors.New("request body too large")
+	}
+	return fmt.Errorf("invalid request body: %w", err)
+}
+
+func isBatchSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t' || r == '\r'
+}
+
+func wantsStream(r *http.Request) bool {
+	if r.URL.Query().Get("stream") == "1" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+}
@@ -0,0 +1,203 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"myip/internal/apikey"
+	"myip/internal/ratelimit"
+	"myip/internal/rdap"
+)
+
+func newBatchService(calls *int32) *mockService {
+	return &mockService{
+		fetch: func(ctx context.Context, ip string) (Response, error) {
+			atomic.AddInt32(calls, 1)
+			return Response{IP: ip, CountCall: 1, RDAP: rdap.Info{Country: "US"}}, nil
+		},
+		onError: func(err error) {},
+	}
+}
+
+func TestBatchHandler_JSON(t *testing.T) {
+	var calls int32
+	handler := BatchHandler(newBatchService(&calls), BatchOptions{MaxItems: 10, Concurrency: 2})
+
+	body := `["8.8.8.8", "bogus", "1.1.1.1", "8.8.8.8"]`
+	req := httptest.NewRequest(http.MethodPost, BatchPath, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Results []struct {
+			IP      string `json:"ip"`
+			Country string `json:"country"`
+			Error   string `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(resp.Results))
+	}
+	if resp.Results[0].IP != "8.8.8.8" || resp.Results[0].Country != "US" {
+		t.Errorf("unexpected first result %+v", resp.Results[0])
+	}
+	if resp.Results[1].Error == "" {
+		t.Errorf("expected error for invalid address")
+	}
+	if resp.Results[3].IP != "8.8.8.8" || resp.Results[3].Country != "US" {
+		t.Errorf("expected duplicate to reuse result, got %+v", resp.Results[3])
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 fetches for distinct addresses, got %d", calls)
+	}
+}
+
+func TestBatchHandler_StreamPlainText(t *testing.T) {
+	var calls int32
+	handler := BatchHandler(newBatchService(&calls), BatchOptions{})
+
+	req := httptest.NewRequest(http.MethodPost, BatchPath+"?stream=1", strings.NewReader("8.8.8.8\n1.1.1.1, 9.9.9.9\n"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != ndjsonContentType {
+		t.Errorf("expected %s, got %s", ndjsonContentType, ct)
+	}
+	lines := 0
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var item map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("invalid ndjson line %q: %v", scanner.Text(), err)
+		}
+		lines++
+	}
+	if lines != 3 {
+		t.Errorf("expected 3 lines, got %d", lines)
+	}
+}
+
+func TestBatchHandler_TooMany(t *testing.T) {
+	var calls int32
+	handler := BatchHandler(newBatchService(&calls), BatchOptions{MaxItems: 2})
+
+	req := httptest.NewRequest(http.MethodPost, BatchPath, strings.NewReader("1.1.1.1\n2.2.2.2\n3.3.3.3\n"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+	if calls != 0 {
+		t.Errorf("expected no fetches, got %d", calls)
+	}
+}
+
+func TestBatchHandler_ChargesPerAddress(t *testing.T) {
+	var calls int32
+	var tokens int
+	var units int64
+	limiter := &mockLimiter{
+		allowN: func(ctx context.Context, class ratelimit.Class, ip string, n int) (ratelimit.Result, error) {
+			tokens = n
+			return ratelimit.Result{Limited: true, Allowed: true, Remaining: 10 - n}, nil
+		},
+	}
+	keys := &mockKeyManager{
+		authorizeN: func(ctx context.Context, secret string, n int64) (apikey.Quota, error) {
+			units = n
+			if n > 2 {
+				return apikey.Quota{Key: apikey.Key{DailyQuota: 2}}, apikey.ErrQuotaExceeded
+			}
+			return apikey.Quota{Key: apikey.Key{ID: "abc", DailyQuota: 2}, Used: n, Remaining: 2 - n}, nil
+		},
+	}
+	handler := BatchHandler(newBatchService(&calls), BatchOptions{Keys: keys, Limiter: limiter})
+
+	req := httptest.NewRequest(http.MethodPost, BatchPath, strings.NewReader("1.1.1.1\n2.2.2.2\n1.1.1.1\nbogus\n3.3.3.3\n"))
+	req.Header.Set("X-API-Key", "s3cret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if units != 3 {
+		t.Errorf("expected 3 distinct addresses charged, got %d units", units)
+	}
+	if tokens != 0 {
+		t.Errorf("expected no rate limit tokens taken for a rejected key, got %d", tokens)
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 over quota, got %d", rec.Code)
+	}
+	if calls != 0 {
+		t.Errorf("expected no fetches, got %d", calls)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, BatchPath, strings.NewReader("1.1.1.1\n2.2.2.2\n"))
+	req.Header.Set("X-API-Key", "s3cret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || tokens != 2 || limiter.keyID != "abc" {
+		t.Errorf("expected 200 with 2 tokens taken from the key bucket, got %d, %d and key %q", rec.Code, tokens, limiter.keyID)
+	}
+}
+
+func TestBatchHandler_AboveBurst(t *testing.T) {
+	var calls int32
+	limiter := &mockLimiter{
+		burst: 2,
+		allowN: func(ctx context.Context, class ratelimit.Class, ip string, n int) (ratelimit.Result, error) {
+			t.Errorf("expected no tokens taken for a batch above the burst, got %d", n)
+			return ratelimit.Result{}, nil
+		},
+	}
+	keys := &mockKeyManager{
+		authorizeN: func(ctx context.Context, secret string, n int64) (apikey.Quota, error) {
+			t.Errorf("expected no quota charged for a batch above the burst, got %d", n)
+			return apikey.Quota{}, nil
+		},
+	}
+	handler := BatchHandler(newBatchService(&calls), BatchOptions{Keys: keys, Limiter: limiter})
+
+	req := httptest.NewRequest(http.MethodPost, BatchPath, strings.NewReader("1.1.1.1\n2.2.2.2\n3.3.3.3\n"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "burst of 2") {
+		t.Errorf("expected the burst in the error, got %s", rec.Body)
+	}
+	if calls != 0 {
+		t.Errorf("expected no fetches, got %d", calls)
+	}
+}
+
+func TestResolveBatch_Canceled(t *testing.T) {
+	var calls int32
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	canceled := 0
+	resolveBatch(ctx, newBatchService(&calls), []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}, 1, func(i int, result batchResult) {
+		if result.Error == "canceled" {
+			canceled++
+		}
+	})
+	if canceled != 3 {
+		t.Errorf("expected every address canceled, got %d", canceled)
+	}
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"myip/internal/asn"
+	"myip/internal/dnsleak"
+	"myip/internal/geoip"
+)
+
+// DNSLeakPath starts a DNS leak test; results are served below it by
+// visit ID.
+const DNSLeakPath = "/api/dnsleak"
+
+// maxDNSLeakResolvers bounds the resolvers enriched per request.
+const maxDNSLeakResolvers = 16
+
+// DNSLeakTest issues per-visit names and reports the resolvers that looked
+// them up.
+type DNSLeakTest interface {
+	Start(ctx context.Context) (dnsleak.Visit, error)
+	Results(ctx context.Context, id string) ([]dnsleak.Resolver, bool, error)
+}
+
+// DNSLeakCache keeps the enrichment of each resolver for the lifetime of a
+// visit, so the page polling the result does not repeat the lookups.
+type DNSLeakCache interface {
+	DNSLeakEnrichments(ctx context.Context, id string) (map[string][]byte, error)
+	SaveDNSLeakEnrichment(ctx context.Context, id, ip string, data []byte, ttl time.Duration) error
+}
+
+type dnsLeakResolver struct {
+	dnsleak.Resolver
+	dnsLeakEnrichment
+}
+
+type dnsLeakEnrichment struct {
+	Country string          `json:"country,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Geo     *geoip.Location `json:"geo,omitempty"`
+	*asn.Info
+}
+
+type dnsLeakReport struct {
+	ID        string            `json:"id"`
+	ClientIP  string            `json:"client_ip"`
+	Resolvers []dnsLeakResolver `json:"resolvers"`
+}
+
+// DNSLeakStartHandler issues a visit whose hosts the page resolves.
+func DNSLeakStartHandler(test DNSLeakTest, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visit, err := test.Start(r.Context())
+		if err != nil {
+			onError(err)
+			writeJSONError(w, http.StatusServiceUnavailable, "dns leak test unavailable")
+			return
+		}
+		if err := writeJSON(w, http.StatusCreated, visit); err != nil {
+			onError(err)
+		}
+	})
+}
+
+// DNSLeakResultHandler lists the resolvers seen for the visit in the {id}
+// path value, enriched with RDAP, ASN and location data. With a cache each
+// resolver is enriched once per visit however often the page polls.
+func DNSLeakResultHandler(test DNSLeakTest, enricher Enricher, cache DNSLeakCache, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		resolvers, ok, err := test.Results(r.Context(), id)
+		if err != nil {
+			onError(err)
+			writeJSONError(w, http.StatusServiceUnavailable, "dns leak test unavailable")
+			return
+		}
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "unknown or expired dns leak test")
+			return
+		}
+		if len(resolvers) > maxDNSLeakResolvers {
+			resolvers = resolvers[:maxDNSLeakResolvers]
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+		var cached map[string][]byte
+		if cache != nil {
+			if cached, err = cache.DNSLeakEnrichments(ctx, id); err != nil {
+				onError(err)
+			}
+		}
+
+		report := dnsLeakReport{ID: id, ClientIP: requestIP(r), Resolvers: make([]dnsLeakResolver, len(resolvers))}
+		var wg sync.WaitGroup
+		for i, resolver := range resolvers {
+			report.Resolvers[i].Resolver = resolver
+			if data, ok := cached[resolver.IP]; ok && json.Unmarshal(data, &report.Resolvers[i].dnsLeakEnrichment) == nil {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				response := enricher.Enrich(ctx, resolver.IP)
+				enrichment := dnsLeakEnrichment{
+					Country: response.RDAP.Country,
+					Name:    response.RDAP.Name,
+					Geo:     response.Geo,
+					Info:    response.ASN,
+				}
+				report.Resolvers[i].dnsLeakEnrichment = enrichment
+				if cache == nil {
+					return
+				}
+				data, err := json.Marshal(enrichment)
+				if err == nil {
+					err = cache.SaveDNSLeakEnrichment(ctx, id, resolver.IP, data, dnsleak.TTL)
+				}
+				if err != nil {
+					onError(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if err := writeJSON(w, http.StatusOK, report); err != nil {
+			onError(err)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"myip/internal/asn"
+	"myip/internal/dnsleak"
+	"myip/internal/rdap"
+)
+
+type mockDNSLeakTest struct {
+	visit     dnsleak.Visit
+	resolvers map[string][]dnsleak.Resolver
+	err       error
+}
+
+func (m *mockDNSLeakTest) Start(ctx context.Context) (dnsleak.Visit, error) {
+	return m.visit, m.err
+}
+
+func (m *mockDNSLeakTest) Results(ctx context.Context, id string) ([]dnsleak.Resolver, bool, error) {
+	resolvers, ok := m.resolvers[id]
+	return resolvers, ok, m.err
+}
+
+type enrichFunc func(ctx context.Context, ip string) Response
+
+func (f enrichFunc) Enrich(ctx context.Context, ip string) Response {
+	return f(ctx, ip)
+}
+
+type mockDNSLeakCache map[string]map[string][]byte
+
+func (m mockDNSLeakCache) DNSLeakEnrichments(ctx context.Context, id string) (map[string][]byte, error) {
+	return m[id], nil
+}
+
+func (m mockDNSLeakCache) SaveDNSLeakEnrichment(ctx context.Context, id, ip string, data []byte, ttl time.Duration) error {
+	if m[id] == nil {
+		m[id] = map[string][]byte{}
+	}
+	m[id][ip] = data
+	return nil
+}
+
+func newDNSLeakMux(test DNSLeakTest, enricher Enricher, cache DNSLeakCache, onError func(error)) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("POST "+DNSLeakPath, DNSLeakStartHandler(test, onError))
+	mux.Handle("GET "+DNSLeakPath+"/{id}", DNSLeakResultHandler(test, enricher, cache, onError))
+	return mux
+}
+
+func TestDNSLeakHandlers(t *testing.T) {
+	test := &mockDNSLeakTest{
+		visit: dnsleak.Visit{ID: "abc", Hosts: []string{"0.abc.leak.example.com"}},
+		resolvers: map[string][]dnsleak.Resolver{"abc": {
+			{IP: "198.51.100.53", Queries: 2, Subnets: []string{"203.0.113.0/24"}},
+			{IP: "192.0.2.53", Queries: 1},
+		}},
+	}
+	enricher := enrichFunc(func(ctx context.Context, ip string) Response {
+		if ip == "192.0.2.53" {
+			return Response{IP: ip, RDAP: rdap.Info{Country: "NL", Name: "RESOLVER-NET"}, ASN: &asn.Info{Number: 64500}}
+		}
+		return Response{IP: ip}
+	})
+	mux := newDNSLeakMux(test, enricher, nil, func(err error) { t.Error(err) })
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, DNSLeakPath, nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var visit dnsleak.Visit
+	if err := json.Unmarshal(rec.Body.Bytes(), &visit); err != nil || visit.ID != "abc" || len(visit.Hosts) != 1 {
+		t.Fatalf("unexpected visit %s, %v", rec.Body, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, DNSLeakPath+"/abc", nil)
+	req.RemoteAddr = "203.0.113.9:5000"
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var report struct {
+		ClientIP  string `json:"client_ip"`
+		Resolvers []struct {
+			IP      string   `json:"ip"`
+			Queries int      `json:"queries"`
+			Subnets []string `json:"subnets"`
+			Country string   `json:"country"`
+			Name    string   `json:"name"`
+			ASN     uint32   `json:"asn"`
+		} `json:"resolvers"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.ClientIP != "203.0.113.9" || len(report.Resolvers) != 2 {
+		t.Fatalf("unexpected report %s", rec.Body)
+	}
+	if r := report.Resolvers[0]; r.IP != "198.51.100.53" || r.Queries != 2 || len(r.Subnets) != 1 {
+		t.Errorf("unexpected first resolver %+v", r)
+	}
+	if r := report.Resolvers[1]; r.Country != "NL" || r.Name != "RESOLVER-NET" || r.ASN != 64500 {
+		t.Errorf("expected enriched second resolver, got %+v", r)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DNSLeakPath+"/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown visit, got %d", rec.Code)
+	}
+}
+
+func TestDNSLeakHandlers_Cache(t *testing.T) {
+	test := &mockDNSLeakTest{resolvers: map[string][]dnsleak.Resolver{"abc": {{IP: "192.0.2.53", Queries: 1}}}}
+	var calls int32
+	enricher := enrichFunc(func(ctx context.Context, ip string) Response {
+		atomic.AddInt32(&calls, 1)
+		return Response{IP: ip, RDAP: rdap.Info{Country: "NL"}}
+	})
+	mux := newDNSLeakMux(test, enricher, mockDNSLeakCache{}, func(err error) { t.Error(err) })
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DNSLeakPath+"/abc", nil))
+		if !strings.Contains(rec.Body.String(), `"country":"NL"`) {
+			t.Errorf("poll %d: expected the enriched resolver, got %s", i, rec.Body)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected one enrichment per resolver and visit, got %d", calls)
+	}
+}
+
+func TestDNSLeakHandlers_StoreError(t *testing.T) {
+	var reported error
+	test := &mockDNSLeakTest{err: errors.New("redis down")}
+	mux := newDNSLeakMux(test, enrichFunc(func(ctx context.Context, ip string) Response { return Response{} }), nil, func(err error) { reported = err })
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, DNSLeakPath, nil),
+		httptest.NewRequest(http.MethodGet, DNSLeakPath+"/abc", nil),
+	} {
+		reported = nil
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusServiceUnavailable || reported == nil {
+			t.Errorf("%s %s: expected 503 with reported error, got %d", req.Method, req.URL, rec.Code)
+		}
+	}
+}
+
+func TestHandler_DNSLeakSection(t *testing.T) {
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &mockService{
+		fetch: func(ctx context.Context, ip string) (Response, error) {
+			return Response{IP: ip, CountCall: 1}, nil
+		},
+		onError: func(err error) { t.Error(err) },
+	}
+	h := NewHandler(tmpl, service)
+
+	for _, enabled := range []bool{false, true} {
+		h.SetDNSLeak(enabled)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		body := rec.Body.String()
+		if strings.Contains(body, `id="dnsleak-info"`) != enabled {
+			t.Errorf("enabled=%v: unexpected DNS leak section presence", enabled)
+		}
+		if !regexp.MustCompile(`const dnsLeakEnabled =\s*` + strconv.FormatBool(enabled) + `\s*;`).MatchString(body) {
+			t.Errorf("enabled=%v: unexpected dnsLeakEnabled flag", enabled)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/netip"
+
+	"myip/internal/dualstack"
+)
+
+// DualStackPath starts a dual-stack test; probes and the summary are
+// served below it by visit ID.
+const DualStackPath = "/api/dualstack"
+
+const (
+	maxDualStackBodyBytes = 4 << 10
+	// largeProbeBytes spans several full size packets at any common MTU.
+	largeProbeBytes = 8 << 10
+)
+
+// DualStackTest issues visits, records probes and summarizes them.
+type DualStackTest interface {
+	Start(ctx context.Context, dualHost string) (dualstack.Visit, error)
+	Record(ctx context.Context, id, probe string, addr netip.Addr) (dualstack.Observation, bool, error)
+	Summarize(ctx context.Context, id string, results map[string]dualstack.ClientResult) (dualstack.Summary, bool, error)
+}
+
+type dualStackProbeResponse struct {
+	dualstack.Observation
+	Padding string `json:"padding,omitempty"`
+}
+
+// DualStackStartHandler issues a visit whose dual-stack probe uses the
+// host the page was loaded from.
+func DualStackStartHandler(test DualStackTest, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visit, err := test.Start(r.Context(), r.Host)
+		if err != nil {
+			onError(err)
+			writeJSONError(w, http.StatusServiceUnavailable, "dual stack test unavailable")
+			return
+		}
+		if err := writeJSON(w, http.StatusCreated, visit); err != nil {
+			onError(err)
+		}
+	})
+}
+
+// DualStackProbeHandler records the {probe} of visit {id}. Probes are
+// fetched cross-origin from the single-family hosts, so any origin may
+// read the response.
+func DualStackProbeHandler(test DualStackTest, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		// no-transform keeps proxies from recompressing the large probes.
+		w.Header().Set("Cache-Control", "no-store, no-transform")
+
+		addr, err := netip.ParseAddr(requestIP(r))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "unknown client address")
+			return
+		}
+		probe := r.PathValue("probe")
+		obs, ok, err := test.Record(r.Context(), r.PathValue("id"), probe, addr)
+		if err != nil {
+			onError(err)
+			writeJSONError(w, http.StatusServiceUnavailable, "dual stack test unavailable")
+			return
+		}
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "unknown or expired dual stack test")
+			return
+		}
+
+		payload := dualStackProbeResponse{Observation: obs}
+		if probe == dualstack.ProbeIPv4Large || probe == dualstack.ProbeIPv6Large {
+			payload.Padding = probePadding()
+		}
+		if err := writeJSON(w, http.StatusOK, payload); err != nil {
+			onError(err)
+		}
+	})
+}
+
+// probePadding returns largeProbeBytes of random base64. Repeated text
+// would shrink to a few bytes under gzip or brotli and never fill the
+// packets the large probes exist to send.
+func probePadding() string {
+	buf := make([]byte, largeProbeBytes*3/4)
+	rand.Read(buf)
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// DualStackSummaryHandler combines the probe results posted by the page as
+// {"probes": {"ipv4": {"ok": true, "duration_ms": 42}, ...}} with what the
+// server observed.
+func DualStackSummaryHandler(test DualStackTest, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Probes map[string]dualstack.ClientResult `json:"probes"`
+		}
+		body := http.MaxBytesReader(w, r.Body, maxDualStackBodyBytes)
+		if err := json.NewDecoder(body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+
+		summary, ok, err := test.Summarize(r.Context(), r.PathValue("id"), req.Probes)
+		if err != nil {
+			onError(err)
+			writeJSONError(w, http.StatusServiceUnavailable, "dual stack test unavailable")
+			return
+		}
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "unknown or expired dual stack test")
+			return
+		}
+		if err := writeJSON(w, http.StatusOK, summary); err != nil {
+			onError(err)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"myip/internal/dualstack"
+)
+
+type mockDualStackTest struct {
+	dualHost string
+	recorded map[string]netip.Addr
+	results  map[string]dualstack.ClientResult
+}
+
+func (m *mockDualStackTest) Start(ctx context.Context, dualHost string) (dualstack.Visit, error) {
+	m.dualHost = dualHost
+	return dualstack.Visit{ID: "abc", Probes: map[string]string{dualstack.ProbeDual: "//" + dualHost + "/api/dualstack/abc/dual"}}, nil
+}
+
+func (m *mockDualStackTest) Record(ctx context.Context, id, probe string, addr netip.Addr) (dualstack.Observation, bool, error) {
+	if id != "abc" {
+		return dualstack.Observation{}, false, nil
+	}
+	m.recorded[probe] = addr
+	return dualstack.Observation{IP: addr.String(), Family: dualstack.FamilyIPv6}, true, nil
+}
+
+func (m *mockDualStackTest) Summarize(ctx context.Context, id string, results map[string]dualstack.ClientResult) (dualstack.Summary, bool, error) {
+	m.results = results
+	return dualstack.Summary{Status: dualstack.StatusDualStack}, id == "abc", nil
+}
+
+func TestDualStackHandlers(t *testing.T) {
+	test := &mockDualStackTest{recorded: map[string]netip.Addr{}}
+	onError := func(err error) { t.Error(err) }
+	mux := http.NewServeMux()
+	mux.Handle("POST "+DualStackPath, DualStackStartHandler(test, onError))
+	mux.Handle("GET "+DualStackPath+"/{id}/{probe}", DualStackProbeHandler(test, onError))
+	mux.Handle("POST "+DualStackPath+"/{id}", DualStackSummaryHandler(test, onError))
+
+	req := httptest.NewRequest(http.MethodPost, "http://myip.example.com"+DualStackPath, nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated || test.dualHost != "myip.example.com" {
+		t.Fatalf("unexpected start %d %s, host %q", rec.Code, rec.Body, test.dualHost)
+	}
+
+	for probe, large := range map[string]bool{dualstack.ProbeIPv6: false, dualstack.ProbeIPv6Large: true} {
+		req = httptest.NewRequest(http.MethodGet, DualStackPath+"/abc/"+probe, nil)
+		req.RemoteAddr = "[2001:db8::7]:5000"
+		req.Header.Set("Origin", "https://myip.example.com")
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Fatalf("%s: unexpected probe response %d %v", probe, rec.Code, rec.Header())
+		}
+		if test.recorded[probe] != netip.MustParseAddr("2001:db8::7") {
+			t.Errorf("%s: expected the client address recorded, got %v", probe, test.recorded[probe])
+		}
+		if (rec.Body.Len() > largeProbeBytes) != large {
+			t.Errorf("%s: unexpected body size %d", probe, rec.Body.Len())
+		}
+		if !strings.Contains(rec.Header().Get("Cache-Control"), "no-transform") {
+			t.Errorf("%s: expected no-transform, got %q", probe, rec.Header().Get("Cache-Control"))
+		}
+		if large {
+			var compressed bytes.Buffer
+			zw := gzip.NewWriter(&compressed)
+			zw.Write(rec.Body.Bytes())
+			zw.Close()
+			if compressed.Len() < largeProbeBytes*2/3 {
+				t.Errorf("%s: padding compresses to %d bytes", probe, compressed.Len())
+			}
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DualStackPath+"/missing/ipv4", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown visit, got %d", rec.Code)
+	}
+
+	body := `{"probes": {"ipv4": {"ok": true, "duration_ms": 42.5}}}`
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, DualStackPath+"/abc", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var summary dualstack.Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil || summary.Status != dualstack.StatusDualStack {
+		t.Errorf("unexpected summary %s, %v", rec.Body, err)
+	}
+	if got := test.results[dualstack.ProbeIPv4]; !got.OK || got.DurationMs != 42.5 {
+		t.Errorf("expected page results passed on, got %+v", test.results)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, DualStackPath+"/abc", strings.NewReader("{")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid body, got %d", rec.Code)
+	}
+}
+
+func TestHandler_DualStackSection(t *testing.T) {
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &mockService{
+		fetch: func(ctx context.Context, ip string) (Response, error) {
+			return Response{IP: ip, CountCall: 1}, nil
+		},
+		onError: func(err error) { t.Error(err) },
+	}
+	h := NewHandler(tmpl, service)
+
+	for _, enabled := range []bool{false, true} {
+		h.SetDualStack(enabled)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if strings.Contains(rec.Body.String(), `id="dualstack-info"`) != enabled {
+			t.Errorf("enabled=%v: unexpected IPv4/IPv6 section presence", enabled)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"myip/internal/fingerprint"
+)
+
+// FingerprintPath is the route where the page submits its fingerprint.
+const FingerprintPath = "/api/fingerprint"
+
+const maxFingerprintBytes = 64 << 10
+
+// The fingerprint owner cookie holds a random per-browser secret that
+// scopes the history, so clients sharing an address cannot read each
+// other's fingerprints.
+const (
+	fingerprintCookie       = "myip_fp"
+	fingerprintOwnerBytes   = 16
+	fingerprintCookieMaxAge = 30 * 24 * time.Hour
+)
+
+// FingerprintHistory stores fingerprints per IP address and browser.
+type FingerprintHistory interface {
+	Save(ctx context.Context, ip, owner string, fp fingerprint.Fingerprint) (fingerprint.Record, bool, error)
+	Recent(ctx context.Context, ip, owner string) ([]fingerprint.Record, error)
+}
+
+// FingerprintStats aggregates how common fingerprint attributes are.
+type FingerprintStats interface {
+	Add(ctx context.Context, fp fingerprint.Fingerprint) (fingerprint.Summary, error)
+	Lookup(ctx context.Context, fp fingerprint.Fingerprint) (fingerprint.Summary, error)
+}
+
+type fingerprintResponse struct {
+	ID      string               `json:"id"`
+	Version int                  `json:"version"`
+	SeenAt  time.Time            `json:"seen_at"`
+	Stats   *fingerprint.Summary `json:"stats,omitempty"`
+}
+
+// FingerprintHandler validates a submitted fingerprint and stores it under
+// the caller's own address and owner cookie, issuing the cookie on first
+// use. When stats is set, a fingerprint is counted the first time it is
+// seen from a browser and the response reports how common its attributes
+// are; stats errors only drop that part.
+func FingerprintHandler(history FingerprintHistory, stats FingerprintStats, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFingerprintBytes))
+		decoder.DisallowUnknownFields()
+
+		var fp fingerprint.Fingerprint
+		if err := decoder.Decode(&fp); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, "fingerprint too large")
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, "invalid fingerprint: "+err.Error())
+			return
+		}
+		if err := fp.Validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		owner := fingerprintOwner(r)
+		if owner == "" {
+			owner = newFingerprintOwner(w, r)
+		}
+		record, created, err := history.Save(r.Context(), requestIP(r), owner, fp)
+		if err != nil {
+			onError(err)
+			writeJSONError(w, http.StatusServiceUnavailable, "fingerprint storage unavailable")
+			return
+		}
+
+		payload := fingerprintResponse{ID: record.ID, Version: record.Fingerprint.Version, SeenAt: record.SeenAt}
+		if stats != nil {
+			count := stats.Lookup
+			if created {
+				count = stats.Add
+			}
+			summary, err := count(r.Context(), fp)
+			if err != nil {
+				onError(err)
+			} else {
+				payload.Stats = &summary
+			}
+		}
+		if err := writeJSON(w, http.StatusCreated, payload); err != nil {
+			onError(err)
+		}
+	})
+}
+
+// fingerprintOwner returns the owner secret from the request cookie, or ""
+// when there is none or it was not issued by newFingerprintOwner.
+func fingerprintOwner(r *http.Request) string {
+	cookie, err := r.Cookie(fingerprintCookie)
+	if err != nil {
+		return ""
+	}
+	if raw, err := hex.DecodeString(cookie.Value); err != nil || len(raw) != fingerprintOwnerBytes {
+		return ""
+	}
+	return cookie.Value
+}
+
+// newFingerprintOwner generates an owner secret and sets it as an HttpOnly
+// cookie.
+func newFingerprintOwner(w http.ResponseWriter, r *http.Request) string {
+	buf := make([]byte, fingerprintOwnerBytes)
+	rand.Read(buf)
+	owner := hex.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     fingerprintCookie,
+		Value:    owner,
+		Path:     "/",
+		MaxAge:   int(fingerprintCookieMaxAge.Seconds()),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return owner
+}
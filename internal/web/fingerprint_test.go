@@ -0,0 +1,182 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"myip/internal/fingerprint"
+)
+
+type mockHistory struct {
+	saved map[string]fingerprint.Fingerprint
+	err   error
+}
+
+func (m *mockHistory) Save(ctx context.Context, ip, owner string, fp fingerprint.Fingerprint) (fingerprint.Record, bool, error) {
+	if m.err != nil {
+		return fingerprint.Record{}, false, m.err
+	}
+	prev, exists := m.saved[ip+"/"+owner]
+	m.saved[ip+"/"+owner] = fp
+	created := !exists || prev.ID() != fp.ID()
+	return fingerprint.Record{ID: fp.ID(), SeenAt: time.Unix(0, 0), Fingerprint: fp}, created, nil
+}
+
+type mockStats struct {
+	added  int
+	looked int
+	err    error
+}
+
+func (m *mockStats) Add(ctx context.Context, fp fingerprint.Fingerprint) (fingerprint.Summary, error) {
+	m.added++
+	return fingerprint.Summary{Total: int64(m.added)}, m.err
+}
+
+func (m *mockStats) Lookup(ctx context.Context, fp fingerprint.Fingerprint) (fingerprint.Summary, error) {
+	m.looked++
+	return fingerprint.Summary{Total: int64(m.added)}, m.err
+}
+
+func (m *mockHistory) Recent(ctx context.Context, ip, owner string) ([]fingerprint.Record, error) {
+	fp, ok := m.saved[ip+"/"+owner]
+	if !ok {
+		return nil, nil
+	}
+	return []fingerprint.Record{{ID: fp.ID(), Fingerprint: fp}}, nil
+}
+
+func TestFingerprintHandler(t *testing.T) {
+	history := &mockHistory{saved: map[string]fingerprint.Fingerprint{}}
+	handler := FingerprintHandler(history, nil, func(error) {})
+
+	var cookies []*http.Cookie
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, FingerprintPath+"?ip=8.8.8.8", strings.NewReader(body))
+		req.RemoteAddr = "203.0.113.1:4000"
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"version": 1, "user_agent": "Mozilla/5.0", "canvas": {"hash": "abc", "data_length": 10}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp fingerprintResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.ID == "" || resp.Version != 1 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	cookies = rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != fingerprintCookie || !cookies[0].HttpOnly {
+		t.Fatalf("expected an HttpOnly owner cookie, got %+v", cookies)
+	}
+	if _, ok := history.saved["203.0.113.1/"+cookies[0].Value]; !ok {
+		t.Error("expected fingerprint stored under the caller's address and owner")
+	}
+	if rec := post(`{"version": 1, "user_agent": "Mozilla/5.0"}`); len(rec.Result().Cookies()) != 0 {
+		t.Errorf("expected the owner cookie to be reused, got %+v", rec.Result().Cookies())
+	}
+	if len(history.saved) != 1 {
+		t.Errorf("expected one owner, got %v", history.saved)
+	}
+
+	tests := map[string]struct {
+		body string
+		code int
+	}{
+		"unknown field":   {`{"version": 1, "user_agent": "x", "extra": true}`, http.StatusBadRequest},
+		"unknown version": {`{"version": 7, "user_agent": "x"}`, http.StatusBadRequest},
+		"not json":        {`hello`, http.StatusBadRequest},
+		"too large":       {`{"version": 1, "user_agent": "` + strings.Repeat("x", maxFingerprintBytes) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for name, tt := range tests {
+		if rec := post(tt.body); rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d", name, tt.code, rec.Code)
+		}
+	}
+
+	history.err = errors.New("redis down")
+	if rec := post(`{"version": 1, "user_agent": "x"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 on storage error, got %d", rec.Code)
+	}
+}
+
+func TestFingerprintHandler_Stats(t *testing.T) {
+	history := &mockHistory{saved: map[string]fingerprint.Fingerprint{}}
+	stats := &mockStats{}
+	handler := FingerprintHandler(history, stats, func(error) {})
+
+	post := func() fingerprintResponse {
+		req := httptest.NewRequest(http.MethodPost, FingerprintPath, strings.NewReader(`{"version": 1, "user_agent": "Mozilla/5.0"}`))
+		req.AddCookie(&http.Cookie{Name: fingerprintCookie, Value: strings.Repeat("ab", fingerprintOwnerBytes)})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+		}
+		var resp fingerprintResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+
+	if resp := post(); resp.Stats == nil || resp.Stats.Total != 1 {
+		t.Errorf("expected stats in response, got %+v", resp.Stats)
+	}
+	post()
+	if stats.added != 1 || stats.looked != 1 {
+		t.Errorf("expected a repeated fingerprint to be looked up, not counted: added %d, looked %d", stats.added, stats.looked)
+	}
+
+	stats.err = errors.New("redis down")
+	if resp := post(); resp.Stats != nil {
+		t.Errorf("expected stats omitted on error, got %+v", resp.Stats)
+	}
+}
+
+func TestHandler_Fingerprints(t *testing.T) {
+	service := &mockService{
+		fetch: func(ctx context.Context, ip string) (Response, error) {
+			return Response{IP: ip, CountCall: 1}, nil
+		},
+		onError: func(err error) { t.Error(err) },
+	}
+	owner := strings.Repeat("ab", fingerprintOwnerBytes)
+	history := &mockHistory{saved: map[string]fingerprint.Fingerprint{
+		"203.0.113.1/" + owner: {Version: 1, UserAgent: "Mozilla/5.0"},
+	}}
+	h := NewHandler(nil, service)
+	h.SetFingerprints(history)
+
+	tests := map[string]struct {
+		remoteAddr, target, owner string
+		want                      bool
+	}{
+		"own browser":   {"203.0.113.1:4000", "/api", owner, true},
+		"same address":  {"203.0.113.1:4000", "/api", strings.Repeat("cd", fingerprintOwnerBytes), false},
+		"no cookie":     {"203.0.113.1:4000", "/api", "", false},
+		"other address": {"198.51.100.7:4000", "/api?ip=203.0.113.1", owner, false},
+	}
+	for name, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.owner != "" {
+			req.AddCookie(&http.Cookie{Name: fingerprintCookie, Value: tt.owner})
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := strings.Contains(rec.Body.String(), `"fingerprints":[{"id":`); got != tt.want {
+			t.Errorf("%s: fingerprints included %v, want %v: %s", name, got, tt.want, rec.Body)
+		}
+	}
+}
@@ -7,11 +7,24 @@ import (
 	"html/template"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
+	"sync"
 	"time"
 
+	"myip/internal/asn"
+	"myip/internal/clienthints"
+	"myip/internal/dnsbl"
+	"myip/internal/fingerprint"
+	"myip/internal/geoip"
+	"myip/internal/hosting"
+	"myip/internal/listen"
+	"myip/internal/netcalc"
 	"myip/internal/rdap"
+	"myip/internal/rdns"
 	"myip/internal/store"
+	"myip/internal/tor"
+	"myip/internal/useragent"
 )
 
 const requestTimeout = 3 * time.Second
@@ -24,30 +37,103 @@ type Service interface {
 
 // Response represents the data returned for a client request.
 type Response struct {
-	IP        string       `json:"ip"`
-	CountCall int64        `json:"count_call"`
-	RDAP      rdap.Info    `json:"rdap"`
-	Events    []rdap.Event `json:"events"`
-	Error     error        `json:"-"`
+	IP         string          `json:"ip"`
+	CountCall  int64           `json:"count_call"`
+	RDAP       rdap.Info       `json:"rdap"`
+	Events     []rdap.Event    `json:"events"`
+	RDNS       rdns.Result     `json:"rdns"`
+	Geo        *geoip.Location `json:"geo,omitempty"`
+	ASN        *asn.Info       `json:"asn,omitempty"`
+	Hosting    *hosting.Match  `json:"hosting,omitempty"`
+	Tor        *tor.Match      `json:"tor,omitempty"`
+	Reputation *dnsbl.Result   `json:"reputation,omitempty"`
+	Error      error           `json:"-"`
 }
 
-// Handler serves the root endpoint.
+// Handler serves the root endpoint and any additional API routes.
 type Handler struct {
-	tmpl    *template.Template
-	service Service
+	tmpl      *template.Template
+	service   Service
+	mux       *http.ServeMux
+	stunPort  int
+	dnsLeak   bool
+	dualStack bool
+
+	userAgents  UserAgentParser
+	botVerifier useragent.ReverseDNS
+	history     FingerprintHistory
+}
+
+// UserAgentParser parses User-Agent strings.
+type UserAgentParser interface {
+	Parse(userAgent string) useragent.Info
 }
 
 // NewHandler constructs a new Handler.
 func NewHandler(tmpl *template.Template, service Service) *Handler {
-	return &Handler{tmpl: tmpl, service: service}
+	h := &Handler{tmpl: tmpl, service: service, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/", h.serveRoot)
+	return h
+}
+
+// Handle registers an additional route using http.ServeMux patterns.
+func (h *Handler) Handle(pattern string, handler http.Handler) {
+	h.mux.Handle(pattern, handler)
+}
+
+// SetSTUNPort makes the page gather WebRTC candidates through the STUN
+// responder listening on port of the same host.
+func (h *Handler) SetSTUNPort(port int) {
+	h.stunPort = port
+}
+
+// SetDNSLeak makes the page run the DNS leak test served under
+// DNSLeakPath.
+func (h *Handler) SetDNSLeak(enabled bool) {
+	h.dnsLeak = enabled
+}
+
+// SetDualStack makes the page run the IPv4/IPv6 test served under
+// DualStackPath.
+func (h *Handler) SetDualStack(enabled bool) {
+	h.dualStack = enabled
+}
+
+// SetUserAgents adds the parsed User-Agent of the caller to responses.
+// With verifier, search engine crawlers are checked against their
+// forward-confirmed reverse DNS.
+func (h *Handler) SetUserAgents(parser UserAgentParser, verifier useragent.ReverseDNS) {
+	h.userAgents = parser
+	h.botVerifier = verifier
+}
+
+// SetFingerprints includes the fingerprints previously submitted by the
+// caller's browser from its own address in API responses. They are only
+// returned with the owner cookie set by FingerprintHandler, and lookups of
+// other addresses never include them.
+func (h *Handler) SetFingerprints(history FingerprintHistory) {
+	h.history = history
 }
 
-// ServeHTTP handles the root endpoint.
+// ServeHTTP dispatches to the registered routes.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// serveRoot handles the root endpoint.
+func (h *Handler) serveRoot(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" && !strings.HasPrefix(r.URL.Path, "/api") {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
+	if message := invalidLookup(r); message != "" {
+		if wantsJSON(r) {
+			writeJSONError(w, http.StatusBadRequest, message)
+		} else {
+			http.Error(w, message, http.StatusBadRequest)
+		}
+		return
+	}
 
 	ip := clientIP(r)
 	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
@@ -66,17 +152,30 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	listener, _ := listen.FromContext(r.Context())
+	var hints *clienthints.Hints
+	if parsed, ok := clienthints.Parse(r.Header); ok {
+		hints = &parsed
+	}
+	w.Header().Set("Accept-CH", strings.Join(clienthints.Requested, ", "))
+	// The response reflects the hints and the parsed User-Agent, so caches
+	// must key on all of them.
+	w.Header().Add("Vary", "User-Agent, "+strings.Join(clienthints.Names, ", "))
+	userAgent := h.parseUserAgent(ctx, r)
+
 	if wantsJSON(r) {
 		w.Header().Set("Content-Type", "application/json")
-		payload := apiResponse{
-			IP:        response.IP,
-			CountCall: response.CountCall,
-			Country:   response.RDAP.Country,
-			Handle:    response.RDAP.Handle,
-			IPVersion: response.RDAP.IPVersion,
-			Name:      response.RDAP.Name,
-			Type:      response.RDAP.Type,
-			Events:    response.RDAP.Events,
+		payload := newAPIResponse(response)
+		payload.Listener = listener.Name
+		payload.Family = listener.Family
+		payload.ClientHints = hints
+		payload.UserAgent = userAgent
+		if h.history != nil && lookupIP(r) == "" {
+			records, err := h.history.Recent(ctx, ip, fingerprintOwner(r))
+			if err != nil {
+				h.service.OnError(fmt.Errorf("fingerprint history: %w", err))
+			}
+			payload.Fingerprints = records
 		}
 		if err := json.NewEncoder(w).Encode(payload); err != nil {
 			h.service.OnError(err)
@@ -85,12 +184,30 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Browsers missing a critical hint retry the navigation with it, so the
+	// first page view already shows the high entropy values.
+	w.Header().Set("Critical-CH", strings.Join(clienthints.Critical, ", "))
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	data := templateData{
 		IP:        response.IP,
 		CountCall: response.CountCall,
 		RDAP:      response.RDAP,
 		HasRDAP:   hasRDAP(response.RDAP),
+		RDNS:      response.RDNS,
+		Geo:       response.Geo,
+		ASN:       response.ASN,
+		Hosting:   response.Hosting,
+		Tor:       response.Tor,
+		Listener:  listener,
+
+		Reputation: response.Reputation,
+		STUNPort:   h.stunPort,
+		DNSLeak:    h.dnsLeak,
+		DualStack:  h.dualStack,
+
+		Representation: represent(response.IP),
+		ClientHints:    hints,
+		UserAgent:      userAgent,
 	}
 	if err := h.tmpl.Execute(w, data); err != nil {
 		h.service.OnError(err)
@@ -99,15 +216,76 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseUserAgent describes the caller's User-Agent, verifying claimed
+// search engine crawlers against the caller's own address.
+func (h *Handler) parseUserAgent(ctx context.Context, r *http.Request) *useragent.Info {
+	if h.userAgents == nil || r.UserAgent() == "" {
+		return nil
+	}
+	info := h.userAgents.Parse(r.UserAgent())
+	if info.Bot != nil && h.botVerifier != nil {
+		if err := useragent.VerifyBot(ctx, info.Bot, requestIP(r), h.botVerifier); err != nil {
+			h.service.OnError(err)
+		}
+	}
+	return &info
+}
+
 type apiResponse struct {
-	IP        string       `json:"ip"`
-	CountCall int64        `json:"count_call"`
-	Country   string       `json:"country"`
-	Handle    string       `json:"handle"`
-	IPVersion string       `json:"ipVersion"`
-	Name      string       `json:"name"`
-	Type      string       `json:"type"`
-	Events    []rdap.Event `json:"events"`
+	IP         string          `json:"ip"`
+	CountCall  int64           `json:"count_call"`
+	Country    string          `json:"country"`
+	Handle     string          `json:"handle"`
+	IPVersion  string          `json:"ipVersion"`
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Events     []rdap.Event    `json:"events"`
+	Hostname   string          `json:"hostname,omitempty"`
+	FCrDNS     bool            `json:"fcrdns"`
+	Listener   string          `json:"listener,omitempty"`
+	Family     string          `json:"address_family,omitempty"`
+	Geo        *geoip.Location `json:"geo,omitempty"`
+	Hosting    *hosting.Match  `json:"hosting,omitempty"`
+	Reputation *dnsbl.Result   `json:"reputation,omitempty"`
+	*asn.Info
+	*tor.Match
+
+	Representation *netcalc.Representation `json:"representation,omitempty"`
+	Fingerprints   []fingerprint.Record    `json:"fingerprints,omitempty"`
+	ClientHints    *clienthints.Hints      `json:"client_hints,omitempty"`
+	UserAgent      *useragent.Info         `json:"user_agent,omitempty"`
+}
+
+func newAPIResponse(response Response) apiResponse {
+	return apiResponse{
+		IP:        response.IP,
+		CountCall: response.CountCall,
+		Country:   response.RDAP.Country,
+		Handle:    response.RDAP.Handle,
+		IPVersion: response.RDAP.IPVersion,
+		Name:      response.RDAP.Name,
+		Type:      response.RDAP.Type,
+		Events:    response.RDAP.Events,
+		Hostname:  response.RDNS.Hostname,
+		FCrDNS:    response.RDNS.FCrDNS,
+		Geo:       response.Geo,
+		Hosting:   response.Hosting,
+		Info:      response.ASN,
+		Match:     response.Tor,
+
+		Reputation: response.Reputation,
+
+		Representation: represent(response.IP),
+	}
+}
+
+func represent(ip string) *netcalc.Representation {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	repr := netcalc.Represent(addr)
+	return &repr
 }
 
 type templateData struct {
@@ -115,37 +293,61 @@ type templateData struct {
 	CountCall int64
 	RDAP      rdap.Info
 	HasRDAP   bool
+	RDNS      rdns.Result
+	Geo       *geoip.Location
+	ASN       *asn.Info
+	Hosting   *hosting.Match
+	Tor       *tor.Match
+	Listener  listen.Info
+
+	Reputation *dnsbl.Result
+	STUNPort   int
+	DNSLeak    bool
+	DualStack  bool
+
+	Representation *netcalc.Representation
+	ClientHints    *clienthints.Hints
+	UserAgent      *useragent.Info
 }
 
 func clientIP(r *http.Request) string {
+	if ip := lookupIP(r); ip != "" {
+		return ip
+	}
+	return requestIP(r)
+}
+
+// lookupIP returns the valid ?ip= parameter, if any.
+func lookupIP(r *http.Request) string {
 	if ip := strings.TrimSpace(r.URL.Query().Get("ip")); ip != "" {
 		if net.ParseIP(ip) != nil {
 			return ip
 		}
 	}
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		parts := strings.Split(forwarded, ",")
-		for _, part := range parts {
-			ip := strings.TrimSpace(part)
-			if net.ParseIP(ip) != nil {
-				return ip
-			}
-		}
+	return ""
+}
+
+// invalidLookup explains why a non-empty ?ip= is not a single address, so
+// that the caller does not silently get their own address instead.
+func invalidLookup(r *http.Request) string {
+	raw := strings.TrimSpace(r.URL.Query().Get("ip"))
+	if raw == "" || lookupIP(r) != "" {
+		return ""
 	}
-	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
-		if net.ParseIP(realIP) != nil {
-			return realIP
-		}
+	if _, err := netip.ParsePrefix(raw); err == nil {
+		return fmt.Sprintf("ip must be a single address; networks are served by %s%s", PrefixPath, raw)
 	}
+	return "invalid ip address"
+}
 
-	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
-	if err == nil {
-		if net.ParseIP(host) != nil {
-			return host
-		}
+// requestIP returns the address of the caller itself, ignoring ?ip=. It is
+// the connection address unless TrustProxies resolved it from forwarding
+// headers.
+func requestIP(r *http.Request) string {
+	if fwd, ok := r.Context().Value(forwardingKey{}).(forwarding); ok {
+		return fwd.client
 	}
-
-	return r.RemoteAddr
+	return peerIP(r)
 }
 
 func wantsJSON(r *http.Request) bool {
@@ -190,48 +392,203 @@ type RDAPLookup interface {
 	Lookup(ctx context.Context, ip string) (rdap.Info, error)
 }
 
+// ReverseDNS resolves PTR names with forward confirmation.
+type ReverseDNS interface {
+	Lookup(ctx context.Context, ip string) (rdns.Result, error)
+}
+
+// GeoIP looks up locations in a local database.
+type GeoIP interface {
+	Lookup(ip string) (geoip.Location, bool, error)
+}
+
+// ASNLookup resolves the origin AS of an address.
+type ASNLookup interface {
+	Lookup(ctx context.Context, ip string) (asn.Info, bool, error)
+}
+
+// HostingRanges matches addresses against cloud and hosting provider
+// ranges.
+type HostingRanges interface {
+	Lookup(ip string) (hosting.Match, bool)
+}
+
+// TorExits reports whether an address is a Tor exit relay.
+type TorExits interface {
+	Lookup(ip string) tor.Match
+}
+
+// Reputation checks an address against DNS blocklists.
+type Reputation interface {
+	Check(ctx context.Context, ip string) (dnsbl.Result, bool, error)
+}
+
 // ServiceImpl is the default implementation of Service.
 type ServiceImpl struct {
 	store      Store
 	rdapClient RDAPLookup
+	reverseDNS ReverseDNS
+	geoIP      GeoIP
+	asn        ASNLookup
+	hosting    HostingRanges
+	torExits   TorExits
+	reputation Reputation
 	onError    func(error)
 }
 
+// SetReverseDNS enables hostname lookups for fetched addresses.
+func (s *ServiceImpl) SetReverseDNS(resolver ReverseDNS) {
+	s.reverseDNS = resolver
+}
+
+// SetASN enables autonomous system lookups for fetched addresses.
+func (s *ServiceImpl) SetASN(lookup ASNLookup) {
+	s.asn = lookup
+}
+
+// SetHosting enables datacenter and cloud range detection.
+func (s *ServiceImpl) SetHosting(ranges HostingRanges) {
+	s.hosting = ranges
+}
+
+// SetTorExits enables Tor exit detection.
+func (s *ServiceImpl) SetTorExits(exits TorExits) {
+	s.torExits = exits
+}
+
+// SetReputation enables DNSBL checks for fetched addresses.
+func (s *ServiceImpl) SetReputation(checker Reputation) {
+	s.reputation = checker
+}
+
+// SetGeoIP enables offline geolocation of fetched addresses.
+func (s *ServiceImpl) SetGeoIP(db GeoIP) {
+	s.geoIP = db
+}
+
 // Fetch returns the response for a given IP.
 func (s *ServiceImpl) Fetch(ctx context.Context, ip string) (Response, error) {
 	var fetchError error
-	count, err := s.store.IncrementCount(ctx, ip)
+	count, err := s.Count(ctx, ip)
 	if err != nil {
 		s.OnError(err)
 		fetchError = err
 	}
 
-	info := rdap.Info{}
-	if s.rdapClient != nil {
-		cached, fetchedAt, ok, err := s.store.GetCached(ctx, ip)
-		if err != nil {
-			s.OnError(err)
-		}
+	response := s.Enrich(ctx, ip)
+	response.CountCall = count
+	response.Error = fetchError
+	return response, nil
+}
 
-		if ok && !store.NeedsRefresh(fetchedAt) {
-			info = cached
-		} else {
-			fetched, err := s.rdapClient.Lookup(ctx, ip)
+// Count records a call from ip and returns the number of calls so far. Other
+// front ends such as the STUN server count through it too.
+func (s *ServiceImpl) Count(ctx context.Context, ip string) (int64, error) {
+	return s.store.IncrementCount(ctx, ip)
+}
+
+// Enrich collects everything known about ip without counting the call.
+func (s *ServiceImpl) Enrich(ctx context.Context, ip string) Response {
+	response := Response{IP: ip}
+
+	// RDAP, DNS, DNSBL and autnum lookups are network bound, so resolve
+	// them side by side.
+	var wg sync.WaitGroup
+	if s.reverseDNS != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := s.reverseDNS.Lookup(ctx, ip)
+			if err != nil {
+				s.OnError(fmt.Errorf("reverse dns: %w", err))
+			}
+			response.RDNS = result
+		}()
+	}
+
+	if s.asn != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, ok, err := s.asn.Lookup(ctx, ip)
+			if err != nil {
+				s.OnError(fmt.Errorf("asn lookup: %w", err))
+			}
+			if ok {
+				response.ASN = &info
+			}
+		}()
+	}
+
+	if s.reputation != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, ok, err := s.reputation.Check(ctx, ip)
 			if err != nil {
-				s.OnError(fmt.Errorf("rdap lookup: %w", err))
-				if ok {
-					info = cached
-				}
-			} else {
-				info = fetched
-				if err := s.store.SetCached(ctx, ip, fetched, time.Now().UTC()); err != nil {
-					s.OnError(err)
-				}
+				s.OnError(fmt.Errorf("dnsbl check: %w", err))
 			}
+			if ok {
+				response.Reputation = &result
+			}
+		}()
+	}
+
+	if s.geoIP != nil {
+		loc, ok, err := s.geoIP.Lookup(ip)
+		if err != nil {
+			s.OnError(fmt.Errorf("geoip lookup: %w", err))
+		}
+		if ok {
+			response.Geo = &loc
+		}
+	}
+
+	if s.hosting != nil {
+		if match, ok := s.hosting.Lookup(ip); ok {
+			response.Hosting = &match
 		}
 	}
 
-	return Response{IP: ip, CountCall: count, RDAP: info, Events: info.Events, Error: fetchError}, nil
+	if s.torExits != nil {
+		match := s.torExits.Lookup(ip)
+		response.Tor = &match
+	}
+
+	response.RDAP = s.RDAP(ctx, ip)
+	response.Events = response.RDAP.Events
+	wg.Wait()
+	return response
+}
+
+// RDAP returns RDAP information for ip from the cache, refreshing it from
+// the RDAP API when stale. Lookup errors are reported through OnError and
+// yield the stale cache entry or empty info.
+func (s *ServiceImpl) RDAP(ctx context.Context, ip string) rdap.Info {
+	if s.rdapClient == nil {
+		return rdap.Info{}
+	}
+
+	cached, fetchedAt, ok, err := s.store.GetCached(ctx, ip)
+	if err != nil {
+		s.OnError(err)
+	}
+	if ok && !store.NeedsRefresh(fetchedAt) {
+		return cached
+	}
+
+	fetched, err := s.rdapClient.Lookup(ctx, ip)
+	if err != nil {
+		s.OnError(fmt.Errorf("rdap lookup: %w", err))
+		if ok {
+			return cached
+		}
+		return rdap.Info{}
+	}
+	if err := s.store.SetCached(ctx, ip, fetched, time.Now().UTC()); err != nil {
+		s.OnError(err)
+	}
+	return fetched
 }
 
 // OnError calls the error handler.
@@ -240,3 +597,17 @@ func (s *ServiceImpl) OnError(err error) {
 		s.onError(err)
 	}
 }
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(payload)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myip/internal/clienthints"
+	"myip/internal/rdns"
+	"myip/internal/useragent"
+)
+
+func TestHandler_ClientHints(t *testing.T) {
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &mockService{
+		fetch: func(ctx context.Context, ip string) (Response, error) {
+			return Response{IP: ip, CountCall: 1}, nil
+		},
+		onError: func(err error) { t.Error(err) },
+	}
+	h := NewHandler(tmpl, service)
+
+	newRequest := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+		req.Header.Set("Sec-CH-UA", `"Chromium";v="124"`)
+		req.Header.Set("Sec-CH-UA-Platform", `"Windows"`)
+		req.Header.Set("Sec-CH-UA-Arch", `"arm"`)
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequest("/"))
+	vary := map[string]bool{}
+	for _, name := range strings.Split(rec.Header().Get("Vary"), ",") {
+		vary[strings.TrimSpace(name)] = true
+	}
+	for _, name := range append([]string{"User-Agent"}, clienthints.Names...) {
+		if !vary[name] {
+			t.Errorf("expected Vary on %s, got %q", name, rec.Header().Get("Vary"))
+		}
+	}
+	if !strings.Contains(rec.Header().Get("Accept-CH"), "Sec-CH-UA-Full-Version-List") ||
+		!strings.Contains(rec.Header().Get("Critical-CH"), "Sec-CH-UA-Platform-Version") {
+		t.Errorf("expected client hints requested, got %v", rec.Header())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `id="client-hints"`) || !strings.Contains(body, "<td>arm</td>") ||
+		!strings.Contains(body, "platform hint &#34;Windows&#34; but User-Agent claims Linux") {
+		t.Errorf("expected client hints section with the platform mismatch, got %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequest("/api"))
+	if rec.Header().Get("Critical-CH") != "" {
+		t.Error("expected Critical-CH only on pages")
+	}
+	var payload struct {
+		ClientHints struct {
+			Platform        string            `json:"platform"`
+			Raw             map[string]string `json:"raw"`
+			Inconsistencies []string          `json:"inconsistencies"`
+		} `json:"client_hints"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ClientHints.Platform != "Windows" || len(payload.ClientHints.Raw) != 3 || len(payload.ClientHints.Inconsistencies) != 1 {
+		t.Errorf("unexpected client hints %+v", payload.ClientHints)
+	}
+}
+
+type mockReverseDNS struct {
+	result rdns.Result
+}
+
+func (m mockReverseDNS) Lookup(ctx context.Context, ip string) (rdns.Result, error) {
+	return m.result, nil
+}
+
+func TestHandler_UserAgent(t *testing.T) {
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &mockService{
+		fetch: func(ctx context.Context, ip string) (Response, error) {
+			return Response{IP: ip, CountCall: 1}, nil
+		},
+		onError: func(err error) { t.Error(err) },
+	}
+	h := NewHandler(tmpl, service)
+	h.SetUserAgents(useragent.Default(), mockReverseDNS{rdns.Result{Hostname: "crawl.example.net", FCrDNS: true}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.RemoteAddr = "66.249.66.1:4000"
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var payload struct {
+		UserAgent *useragent.Info `json:"user_agent"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	info := payload.UserAgent
+	if info == nil || info.Browser.Family != "Googlebot" || info.Device.Type != useragent.DeviceBot {
+		t.Fatalf("unexpected user agent %+v", info)
+	}
+	if info.Bot == nil || info.Bot.Verified == nil || *info.Bot.Verified {
+		t.Errorf("expected a Googlebot claim from a foreign host to fail verification, got %+v", info.Bot)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "<td>curl (tool)</td>") {
+		t.Errorf("expected the page to show the bot, got %s", rec.Body)
+	}
+}
+
+func TestHandler_InvalidLookup(t *testing.T) {
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &mockService{
+		fetch: func(ctx context.Context, ip string) (Response, error) {
+			t.Errorf("unexpected fetch of %s", ip)
+			return Response{IP: ip}, nil
+		},
+		onError: func(err error) { t.Error(err) },
+	}
+	h := NewHandler(tmpl, service)
+
+	tests := map[string]string{
+		"/api?ip=10.0.0.0/8": PrefixPath + "10.0.0.0/8",
+		"/?ip=bogus":         "invalid ip address",
+	}
+	for url, message := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), message) {
+			t.Errorf("%s: expected %q in %q", url, message, rec.Body)
+		}
+	}
+}
+
+func TestParseTemplates_Sections(t *testing.T) {
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &mockService{
+		fetch: func(ctx context.Context, ip string) (Response, error) {
+			return Response{IP: ip, CountCall: 1}, nil
+		},
+		onError: func(err error) { t.Error(err) },
+	}
+	rec := httptest.NewRecorder()
+	NewHandler(tmpl, service).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+
+	for _, section := range []string{"webgl", "audio", "speech", "media", "permissions"} {
+		if tmpl.Lookup(section+".html") == nil {
+			t.Errorf("missing %s partial", section)
+		}
+		if !strings.Contains(body, `id="`+section+`-info"`) {
+			t.Errorf("expected the page to include the %s section", section)
+		}
+	}
+}
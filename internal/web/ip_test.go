@@ -25,6 +25,31 @@ func TestClientIP(t *testing.T) {
 				"X-Forwarded-For": "10.0.0.1, 1.2.3.4",
 			},
 			remote:   "192.168.1.1:1234",
+			expected: "1.2.3.4",
+		},
+		{
+			name: "X-Forwarded-For skips trusted hops",
+			headers: map[string]string{
+				"X-Forwarded-For": "10.0.0.1, 192.168.1.7",
+			},
+			remote:   "192.168.1.1:1234",
+			expected: "10.0.0.1",
+		},
+		{
+			name: "X-Forwarded-For from untrusted peer",
+			headers: map[string]string{
+				"X-Forwarded-For": "8.8.8.8",
+				"X-Real-IP":       "8.8.4.4",
+			},
+			remote:   "1.2.3.4:1234",
+			expected: "1.2.3.4",
+		},
+		{
+			name: "X-Forwarded-For over unix socket",
+			headers: map[string]string{
+				"X-Forwarded-For": "10.0.0.1",
+			},
+			remote:   "@",
 			expected: "10.0.0.1",
 		},
 		{
@@ -65,6 +90,10 @@ func TestClientIP(t *testing.T) {
 		},
 	}
 
+	proxies, err := ParseTrustedProxies([]string{"192.168.1.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			path := "/"
@@ -77,7 +106,10 @@ func TestClientIP(t *testing.T) {
 				req.Header.Set(k, v)
 			}
 
-			got := clientIP(req)
+			var got string
+			TrustProxies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}), proxies).ServeHTTP(httptest.NewRecorder(), req)
 			if got != tt.expected {
 				t.Errorf("clientIP() = %v, want %v", got, tt.expected)
 			}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"myip/internal/ratelimit"
+)
+
+// RateLimiter checks a request class against the caller's buckets.
+type RateLimiter interface {
+	Allow(ctx context.Context, class ratelimit.Class, ip string) (ratelimit.Result, error)
+	AllowN(ctx context.Context, class ratelimit.Class, ip string, n int) (ratelimit.Result, error)
+	AllowKey(ctx context.Context, class ratelimit.Class, ip, id string, n int) (ratelimit.Result, error)
+	Capacity(class ratelimit.Class, ip string) (int, bool)
+}
+
+// RateLimit rejects requests over the caller's limit with 429. Arbitrary
+// ?ip= lookups use their own, stricter bucket, which is the bucket of the
+// API key when RequireAPIKey accepted one. Limiter errors fail open.
+// Batch lookups are charged per address by BatchHandler instead.
+func RateLimit(next http.Handler, limiter RateLimiter, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == BatchPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		var result ratelimit.Result
+		var err error
+		if key, ok := authorizedKey(r); ok && requestClass(r) == ratelimit.ClassLookup {
+			result, err = allowLookups(ctx, limiter, r, key.ID, 1)
+		} else {
+			result, err = limiter.Allow(ctx, requestClass(r), requestIP(r))
+		}
+		cancel()
+		if err != nil && onError != nil {
+			onError(err)
+		}
+
+		result.SetHeaders(w.Header())
+		if !result.Allowed {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowLookups takes n lookup tokens for r, from the bucket of API key
+// keyID when it is set and from the caller's address bucket otherwise.
+func allowLookups(ctx context.Context, limiter RateLimiter, r *http.Request, keyID string, n int) (ratelimit.Result, error) {
+	if keyID != "" {
+		return limiter.AllowKey(ctx, ratelimit.ClassLookup, requestIP(r), keyID, n)
+	}
+	return limiter.AllowN(ctx, ratelimit.ClassLookup, requestIP(r), n)
+}
+
+func requestClass(r *http.Request) ratelimit.Class {
+	switch {
+	case lookupIP(r) != "", isLookupPath(r.URL.Path):
+		return ratelimit.ClassLookup
+	case wantsJSON(r):
+		return ratelimit.ClassAPI
+	default:
+		return ratelimit.ClassPage
+	}
+}
+
+// isLookupPath reports endpoints that resolve caller-supplied addresses.
+// Summarizing prefixes is plain arithmetic and not one of them.
+func isLookupPath(path string) bool {
+	return path == BatchPath || (strings.HasPrefix(path, PrefixPath) && path != SummarizePath)
+}
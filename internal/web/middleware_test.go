@@ -0,0 +1,123 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myip/internal/apikey"
+	"myip/internal/ratelimit"
+)
+
+type mockLimiter struct {
+	allow  func(ctx context.Context, class ratelimit.Class, ip string) (ratelimit.Result, error)
+	allowN func(ctx context.Context, class ratelimit.Class, ip string, n int) (ratelimit.Result, error)
+	burst  int
+	keyID  string
+}
+
+func (m *mockLimiter) Allow(ctx context.Context, class ratelimit.Class, ip string) (ratelimit.Result, error) {
+	return m.allow(ctx, class, ip)
+}
+
+func (m *mockLimiter) Capacity(class ratelimit.Class, ip string) (int, bool) {
+	return m.burst, m.burst > 0
+}
+
+func (m *mockLimiter) AllowN(ctx context.Context, class ratelimit.Class, ip string, n int) (ratelimit.Result, error) {
+	return m.allowN(ctx, class, ip, n)
+}
+
+func (m *mockLimiter) AllowKey(ctx context.Context, class ratelimit.Class, ip, id string, n int) (ratelimit.Result, error) {
+	m.keyID = id
+	return m.allowN(ctx, class, ip, n)
+}
+
+func TestRateLimit(t *testing.T) {
+	var gotClass ratelimit.Class
+	var gotIP string
+	limiter := &mockLimiter{
+		allow: func(ctx context.Context, class ratelimit.Class, ip string) (ratelimit.Result, error) {
+			gotClass, gotIP = class, ip
+			return ratelimit.Result{Limited: true, Allowed: false}, nil
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("next handler must not be called")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/?ip=8.8.8.8", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	// Without trusted proxies a forged header must not pick the bucket.
+	req.Header.Set("X-Forwarded-For", "9.9.9.9")
+	rec := httptest.NewRecorder()
+	TrustProxies(RateLimit(next, limiter, nil), nil).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", rec.Code)
+	}
+	if gotClass != ratelimit.ClassLookup {
+		t.Errorf("expected lookup class, got %s", gotClass)
+	}
+	if gotIP != "1.2.3.4" {
+		t.Errorf("expected caller IP 1.2.3.4, got %s", gotIP)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+}
+
+func TestRateLimit_APIKey(t *testing.T) {
+	keys := &mockKeyManager{
+		authorize: func(ctx context.Context, secret string) (apikey.Quota, error) {
+			return apikey.Quota{Key: apikey.Key{ID: "abc"}}, nil
+		},
+	}
+	var calls int
+	limiter := &mockLimiter{
+		allow: func(ctx context.Context, class ratelimit.Class, ip string) (ratelimit.Result, error) {
+			calls++
+			return ratelimit.Result{Allowed: true}, nil
+		},
+		allowN: func(ctx context.Context, class ratelimit.Class, ip string, n int) (ratelimit.Result, error) {
+			calls++
+			return ratelimit.Result{Allowed: true}, nil
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := TrustProxies(RequireAPIKey(RateLimit(next, limiter, nil), keys, nil), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/?ip=8.8.8.8", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	req.Header.Set("X-API-Key", "s3cret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if calls != 1 || limiter.keyID != "abc" {
+		t.Errorf("expected one lookup charged to key abc, got %d calls and key %q", calls, limiter.keyID)
+	}
+
+	limiter.keyID = ""
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if calls != 2 || limiter.keyID != "" {
+		t.Errorf("expected a self lookup charged to the address, got %d calls and key %q", calls, limiter.keyID)
+	}
+}
+
+func TestRequestClass(t *testing.T) {
+	tests := map[string]ratelimit.Class{
+		"/":                       ratelimit.ClassPage,
+		"/api":                    ratelimit.ClassAPI,
+		"/api?ip=1.1.1.1":         ratelimit.ClassLookup,
+		"/?ip=invalid":            ratelimit.ClassPage,
+		PrefixPath + "10.0.0.0/8": ratelimit.ClassLookup,
+		SummarizePath:             ratelimit.ClassAPI,
+	}
+	for url, expected := range tests {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if got := requestClass(req); got != expected {
+			t.Errorf("requestClass(%s) = %s, want %s", url, got, expected)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"myip/internal/netcalc"
+	"myip/internal/rdap"
+)
+
+// PrefixPath is the route prefix of the subnet calculator endpoints.
+const PrefixPath = "/api/prefix/"
+
+// SummarizePath aggregates caller-supplied prefixes without looking them up.
+const SummarizePath = PrefixPath + "summarize"
+
+// RDAPSource returns cached RDAP information without counting a visit.
+type RDAPSource interface {
+	RDAP(ctx context.Context, ip string) rdap.Info
+}
+
+type prefixResponse struct {
+	netcalc.Subnet
+	RDAP    *rdap.Info `json:"rdap,omitempty"`
+	Subnets []string   `json:"subnets,omitempty"`
+}
+
+type summarizeResponse struct {
+	Prefixes []string `json:"prefixes"`
+}
+
+// PrefixHandler serves /api/prefix/{cidr...}: the subnet calculator view of
+// the prefix, the RDAP allocation containing it and, with ?split=N, its
+// subnets of length N.
+func PrefixHandler(source RDAPSource, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix, err := netcalc.ParsePrefix(r.PathValue("cidr"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		payload := prefixResponse{Subnet: netcalc.Describe(prefix)}
+
+		if raw := r.URL.Query().Get("split"); raw != "" {
+			length, err := strconv.Atoi(raw)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "split must be a prefix length")
+				return
+			}
+			subnets, err := netcalc.Split(prefix, length)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			payload.Subnets = prefixStrings(subnets)
+		}
+
+		if source != nil && netcalc.IsGlobal(prefix.Addr()) {
+			ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+			info := source.RDAP(ctx, prefix.Addr().String())
+			cancel()
+			if hasRDAP(info) {
+				payload.RDAP = &info
+			}
+		}
+
+		if err := writeJSON(w, http.StatusOK, payload); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+// SummarizeHandler aggregates a JSON array or newline separated list of
+// prefixes into the minimal covering set.
+func SummarizeHandler(maxItems int, onError func(error)) http.Handler {
+	if maxItems <= 0 {
+		maxItems = defaultBatchMaxItems
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := http.MaxBytesReader(w, r.Body, int64(maxItems*maxBatchLineBytes))
+		items, err := parseBatch(body, r.Header.Get("Content-Type"), maxItems)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		prefixes := make([]netip.Prefix, 0, len(items))
+		for _, item := range items {
+			prefix, err := netcalc.ParsePrefix(item)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			prefixes = append(prefixes, prefix)
+		}
+
+		payload := summarizeResponse{Prefixes: prefixStrings(netcalc.Summarize(prefixes))}
+		if err := writeJSON(w, http.StatusOK, payload); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	result := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		result = append(result, prefix.String())
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myip/internal/rdap"
+)
+
+type mockRDAPSource struct {
+	calls []string
+}
+
+func (m *mockRDAPSource) RDAP(ctx context.Context, ip string) rdap.Info {
+	m.calls = append(m.calls, ip)
+	return rdap.Info{Name: "EXAMPLE-NET"}
+}
+
+func TestPrefixHandler(t *testing.T) {
+	source := &mockRDAPSource{}
+	h := NewHandler(nil, &mockService{})
+	h.Handle("GET "+PrefixPath+"{cidr...}", PrefixHandler(source, nil))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PrefixPath+"8.8.8.0/23?split=24", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp prefixResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Network != "8.8.8.0" || resp.Broadcast != "8.8.9.255" {
+		t.Errorf("unexpected subnet %+v", resp.Subnet)
+	}
+	if len(resp.Subnets) != 2 {
+		t.Errorf("expected 2 subnets, got %v", resp.Subnets)
+	}
+	if resp.RDAP == nil || resp.RDAP.Name != "EXAMPLE-NET" {
+		t.Errorf("expected RDAP allocation, got %+v", resp.RDAP)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PrefixPath+"10.0.0.0/8", nil))
+	if len(source.calls) != 1 {
+		t.Errorf("expected no RDAP lookup for private range, got %v", source.calls)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PrefixPath+"10.0.0.0/33", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid prefix, got %d", rec.Code)
+	}
+}
+
+func TestSummarizeHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, PrefixPath+"summarize", strings.NewReader("10.0.0.0/24\n10.0.1.0/24\n"))
+	rec := httptest.NewRecorder()
+	SummarizeHandler(0, nil).ServeHTTP(rec, req)
+
+	var resp summarizeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Prefixes) != 1 || resp.Prefixes[0] != "10.0.0.0/23" {
+		t.Errorf("unexpected summary %v", resp.Prefixes)
+	}
+}
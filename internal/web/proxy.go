@@ -0,0 +1,131 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type forwardingKey struct{}
+
+// forwarding is what TrustProxies resolved for a request. Unverified are
+// the forwarding hops no trusted proxy vouches for: everything an
+// untrusted peer claims, or the hops a trusted proxy received from further
+// upstream than the client.
+type forwarding struct {
+	client      string
+	peerTrusted bool
+	unverified  []string
+}
+
+// ParseTrustedProxies parses proxy addresses and CIDR prefixes.
+func ParseTrustedProxies(items []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("parse trusted proxy %q: %w", item, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("parse trusted proxy %q: %w", item, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// TrustProxies resolves the caller's address once per request for
+// requestIP. X-Forwarded-For and X-Real-IP are honoured only on
+// connections from proxies and over unix sockets; from anyone else they
+// are trivially forged, so the connection address is used.
+func TrustProxies(next http.Handler, proxies []netip.Prefix) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fwd := forwardedIP(r, proxies)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), forwardingKey{}, fwd)))
+	})
+}
+
+// requestForwarding returns what TrustProxies resolved, or the view of a
+// deployment without trusted proxies when it did not run.
+func requestForwarding(r *http.Request) forwarding {
+	if fwd, ok := r.Context().Value(forwardingKey{}).(forwarding); ok {
+		return fwd
+	}
+	return forwardedIP(r, nil)
+}
+
+// forwardedIP walks X-Forwarded-For from the nearest hop and returns the
+// first address that is not a trusted proxy, falling back to X-Real-IP
+// and the connection address.
+func forwardedIP(r *http.Request, proxies []netip.Prefix) forwarding {
+	peer := peerIP(r)
+	if addr, err := netip.ParseAddr(peer); err == nil && !trusted(addr, proxies) {
+		return forwarding{client: peer, unverified: forwardedFor(r)}
+	}
+
+	var hops []netip.Addr
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, part := range strings.Split(header, ",") {
+			if addr, err := netip.ParseAddr(strings.TrimSpace(part)); err == nil && addr.Zone() == "" {
+				hops = append(hops, addr)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !trusted(hops[i], proxies) {
+			fwd := forwarding{client: hops[i].String(), peerTrusted: true}
+			for _, hop := range hops[:i] {
+				fwd.unverified = append(fwd.unverified, hop.String())
+			}
+			return fwd
+		}
+	}
+	if len(hops) > 0 {
+		return forwarding{client: hops[0].String(), peerTrusted: true}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return forwarding{client: realIP, peerTrusted: true}
+	}
+	return forwarding{client: peer, peerTrusted: true}
+}
+
+// forwardedFor lists the non-empty X-Forwarded-For entries as sent.
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, part := range strings.Split(header, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				hops = append(hops, part)
+			}
+		}
+	}
+	return hops
+}
+
+func trusted(addr netip.Addr, proxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIP returns the address of the connection.
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err == nil && net.ParseIP(host) != nil {
+		return host
+	}
+	return r.RemoteAddr
+}
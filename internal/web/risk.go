@@ -0,0 +1,117 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"myip/internal/risk"
+)
+
+// RiskPath is the route of the proxy/VPN scoring endpoint.
+const RiskPath = "/api/risk"
+
+const (
+	maxRiskBodyBytes = 16 << 10
+	maxRiskListItems = 32
+)
+
+// Enricher collects IP information without counting a visit.
+type Enricher interface {
+	Enrich(ctx context.Context, ip string) Response
+}
+
+// RiskEngine scores proxy and VPN signals.
+type RiskEngine interface {
+	Evaluate(signals risk.Signals) risk.Report
+}
+
+// clientSignals are reported by the page after it ran its checks.
+type clientSignals struct {
+	TimeZone  string   `json:"time_zone"`
+	Languages []string `json:"languages"`
+	WebRTCIPs []string `json:"webrtc_ips"`
+}
+
+// RiskHandler scores the caller's own address from request headers, the
+// IP enrichment and the client signals posted as JSON. An empty body
+// yields a server-side only score.
+func RiskHandler(enricher Enricher, engine RiskEngine, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var client clientSignals
+		body := http.MaxBytesReader(w, r.Body, maxRiskBodyBytes)
+		if err := json.NewDecoder(body).Decode(&client); err != nil && !errors.Is(err, io.EOF) {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+
+		ip := requestIP(r)
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		response := enricher.Enrich(ctx, ip)
+		cancel()
+
+		signals := requestSignals(r, ip)
+		signals.RDAPName = response.RDAP.Name
+		signals.RDAPType = response.RDAP.Type
+		signals.IPCountry = response.RDAP.Country
+		if response.Geo != nil {
+			signals.IPTimeZone = response.Geo.TimeZone
+			if response.Geo.CountryCode != "" {
+				signals.IPCountry = response.Geo.CountryCode
+			}
+		}
+		if response.Hosting != nil {
+			signals.HostingProvider = response.Hosting.Provider
+		}
+		signals.Tor = response.Tor != nil && response.Tor.IsTor
+		signals.Blocklisted = response.Reputation != nil && response.Reputation.Listed
+
+		signals.BrowserTimeZone = client.TimeZone
+		signals.WebRTCIPs = truncate(client.WebRTCIPs, maxRiskListItems)
+		signals.Languages = truncate(client.Languages, maxRiskListItems)
+		if len(signals.Languages) == 0 {
+			signals.Languages = acceptLanguages(r.Header.Get("Accept-Language"))
+		}
+
+		if err := writeJSON(w, http.StatusOK, engine.Evaluate(signals)); err != nil {
+			onError(err)
+		}
+	})
+}
+
+// requestSignals extracts the connection and proxy header signals.
+func requestSignals(r *http.Request, ip string) risk.Signals {
+	fwd := requestForwarding(r)
+	return risk.Signals{
+		ClientIP:     ip,
+		PeerIP:       peerIP(r),
+		PeerTrusted:  fwd.peerTrusted,
+		ProxyHops:    truncate(fwd.unverified, maxRiskListItems),
+		ForwardedFor: forwardedFor(r),
+		Via:          r.Header.Get("Via"),
+		Forwarded:    r.Header.Get("Forwarded"),
+	}
+}
+
+// acceptLanguages returns the language tags of an Accept-Language header
+// in the order given.
+func acceptLanguages(header string) []string {
+	var tags []string
+	for _, part := range strings.Split(header, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if tag = strings.TrimSpace(tag); tag != "" && tag != "*" {
+			tags = append(tags, tag)
+		}
+	}
+	return truncate(tags, maxRiskListItems)
+}
+
+func truncate(items []string, n int) []string {
+	if len(items) > n {
+		return items[:n]
+	}
+	return items
+}
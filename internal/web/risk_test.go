@@ -0,0 +1,136 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myip/internal/geoip"
+	"myip/internal/rdap"
+	"myip/internal/risk"
+)
+
+type mockEnricher struct {
+	response Response
+	ip       string
+}
+
+func (m *mockEnricher) Enrich(ctx context.Context, ip string) Response {
+	m.ip = ip
+	response := m.response
+	response.IP = ip
+	return response
+}
+
+func TestRiskHandler(t *testing.T) {
+	engine, err := risk.NewEngine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enricher := &mockEnricher{response: Response{
+		RDAP: rdap.Info{Name: "EXAMPLE-HOSTING", Country: "DE"},
+		Geo:  &geoip.Location{CountryCode: "DE", TimeZone: "Europe/Berlin"},
+	}}
+	handler := RiskHandler(enricher, engine, func(err error) { t.Error(err) })
+
+	body := `{"time_zone": "Asia/Tokyo", "languages": ["ja-JP"], "webrtc_ips": ["198.51.100.7"]}`
+	req := httptest.NewRequest(http.MethodPost, RiskPath+"?ip=8.8.8.8", strings.NewReader(body))
+	req.RemoteAddr = "203.0.113.1:5000"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if enricher.ip != "203.0.113.1" {
+		t.Errorf("expected the caller's own address to be scored, got %s", enricher.ip)
+	}
+
+	var report risk.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	triggered := map[string]bool{}
+	for _, f := range report.Findings {
+		triggered[f.Rule] = f.Triggered
+	}
+	for _, rule := range []string{"rdap_keywords", "timezone_mismatch", "language_mismatch", "webrtc_leak"} {
+		if !triggered[rule] {
+			t.Errorf("expected %s to trigger, findings %+v", rule, report.Findings)
+		}
+	}
+	if report.Score < 75 || report.Level != "high" {
+		t.Errorf("expected high score, got %d %s", report.Score, report.Level)
+	}
+}
+
+func TestRiskHandler_EmptyBody(t *testing.T) {
+	engine, _ := risk.NewEngine(nil)
+	handler := RiskHandler(&mockEnricher{}, engine, func(err error) { t.Error(err) })
+
+	req := httptest.NewRequest(http.MethodPost, RiskPath, nil)
+	req.RemoteAddr = "203.0.113.1:5000"
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, RiskPath, strings.NewReader("{"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for broken JSON, got %d", rec.Code)
+	}
+}
+
+func TestRiskHandler_PeerMismatch(t *testing.T) {
+	engine, _ := risk.NewEngine(nil)
+	proxies, err := ParseTrustedProxies([]string{"198.51.100.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := TrustProxies(RiskHandler(&mockEnricher{}, engine, func(err error) { t.Error(err) }), proxies)
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		expected  bool
+	}{
+		{name: "direct", remote: "203.0.113.1:5000", expected: false},
+		{name: "trusted CDN", remote: "198.51.100.7:5000", forwarded: "203.0.113.1", expected: false},
+		{name: "unknown proxy", remote: "192.0.2.7:5000", forwarded: "203.0.113.1", expected: true},
+		{name: "proxy before the CDN", remote: "198.51.100.7:5000", forwarded: "203.0.113.1, 192.0.2.7", expected: true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, RiskPath, nil)
+		req.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var report risk.Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range report.Findings {
+			if f.Rule == "peer_mismatch" && f.Triggered != tt.expected {
+				t.Errorf("%s: expected peer_mismatch %v, got %+v", tt.name, tt.expected, f)
+			}
+		}
+	}
+}
+
+func TestAcceptLanguages(t *testing.T) {
+	got := acceptLanguages("de-CH, fr;q=0.8, *;q=0.5")
+	if strings.Join(got, ",") != "de-CH,fr" {
+		t.Errorf("unexpected tags %v", got)
+	}
+}
@@ -2,10 +2,17 @@ package web
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
+	"myip/internal/asn"
+	"myip/internal/dnsbl"
+	"myip/internal/geoip"
+	"myip/internal/hosting"
 	"myip/internal/rdap"
+	"myip/internal/tor"
 )
 
 type mockRDAPLookup struct {
@@ -72,3 +79,173 @@ func TestServiceImpl_Fetch(t *testing.T) {
 		t.Errorf("expected country US, got %s", resp.RDAP.Country)
 	}
 }
+
+type mockGeoIP map[string]geoip.Location
+
+func (m mockGeoIP) Lookup(ip string) (geoip.Location, bool, error) {
+	loc, ok := m[ip]
+	return loc, ok, nil
+}
+
+func TestServiceImpl_FetchGeoIP(t *testing.T) {
+	ms := &mockStore{
+		incrementCount: func(ctx context.Context, ip string) (int64, error) {
+			return 1, nil
+		},
+	}
+	s := NewService(ms, nil, nil)
+	s.SetGeoIP(mockGeoIP{"1.2.3.4": {CountryCode: "NL", TimeZone: "Europe/Amsterdam"}})
+
+	resp, err := s.Fetch(context.Background(), "1.2.3.4")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if resp.Geo == nil || resp.Geo.TimeZone != "Europe/Amsterdam" {
+		t.Errorf("expected geo location, got %+v", resp.Geo)
+	}
+
+	resp, _ = s.Fetch(context.Background(), "5.6.7.8")
+	if resp.Geo != nil {
+		t.Errorf("expected no location for unknown address, got %+v", resp.Geo)
+	}
+}
+
+type mockASN map[string]asn.Info
+
+func (m mockASN) Lookup(ctx context.Context, ip string) (asn.Info, bool, error) {
+	info, ok := m[ip]
+	return info, ok, nil
+}
+
+func TestServiceImpl_FetchASN(t *testing.T) {
+	ms := &mockStore{
+		incrementCount: func(ctx context.Context, ip string) (int64, error) {
+			return 1, nil
+		},
+	}
+	s := NewService(ms, nil, nil)
+	s.SetASN(mockASN{"8.8.8.8": {Number: 15169, Name: "GOOGLE", Prefix: "8.8.8.0/24"}})
+
+	resp, err := s.Fetch(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	data, err := json.Marshal(newAPIResponse(resp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"asn":15169`, `"as_name":"GOOGLE"`, `"as_prefix":"8.8.8.0/24"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+
+	resp, _ = s.Fetch(context.Background(), "192.0.2.1")
+	data, _ = json.Marshal(newAPIResponse(resp))
+	if strings.Contains(string(data), `"asn"`) {
+		t.Errorf("expected no asn fields for unrouted address, got %s", data)
+	}
+}
+
+type mockHosting map[string]hosting.Match
+
+func (m mockHosting) Lookup(ip string) (hosting.Match, bool) {
+	match, ok := m[ip]
+	return match, ok
+}
+
+func TestServiceImpl_FetchHosting(t *testing.T) {
+	ms := &mockStore{
+		incrementCount: func(ctx context.Context, ip string) (int64, error) {
+			return 1, nil
+		},
+	}
+	s := NewService(ms, nil, nil)
+	s.SetHosting(mockHosting{"3.5.140.10": {Provider: "AWS", Service: "EC2", Region: "ap-northeast-2", Prefix: "3.5.140.0/22"}})
+
+	resp, err := s.Fetch(context.Background(), "3.5.140.10")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if resp.Hosting == nil || resp.Hosting.Provider != "AWS" {
+		t.Errorf("expected AWS match, got %+v", resp.Hosting)
+	}
+}
+
+type mockTorExits map[string]string
+
+func (m mockTorExits) Lookup(ip string) tor.Match {
+	fingerprint, ok := m[ip]
+	return tor.Match{IsTor: ok, Fingerprint: fingerprint}
+}
+
+func TestServiceImpl_FetchTor(t *testing.T) {
+	ms := &mockStore{
+		incrementCount: func(ctx context.Context, ip string) (int64, error) {
+			return 1, nil
+		},
+	}
+	s := NewService(ms, nil, nil)
+	s.SetTorExits(mockTorExits{"162.247.74.201": "ABCD"})
+
+	resp, _ := s.Fetch(context.Background(), "162.247.74.201")
+	data, _ := json.Marshal(newAPIResponse(resp))
+	if !strings.Contains(string(data), `"is_tor":true,"tor_fingerprint":"ABCD"`) {
+		t.Errorf("expected tor fields, got %s", data)
+	}
+
+	resp, _ = s.Fetch(context.Background(), "192.0.2.1")
+	data, _ = json.Marshal(newAPIResponse(resp))
+	if !strings.Contains(string(data), `"is_tor":false`) {
+		t.Errorf("expected is_tor false, got %s", data)
+	}
+}
+
+type mockReputation struct{}
+
+func (mockReputation) Check(ctx context.Context, ip string) (dnsbl.Result, bool, error) {
+	if ip == "10.0.0.1" {
+		return dnsbl.Result{}, false, nil
+	}
+	zone := dnsbl.ZoneResult{Zone: "bl.example", Listed: true, Codes: []string{"127.0.0.2"}}
+	return dnsbl.Result{Listed: true, Zones: []dnsbl.ZoneResult{zone}}, true, nil
+}
+
+func TestServiceImpl_FetchReputation(t *testing.T) {
+	ms := &mockStore{
+		incrementCount: func(ctx context.Context, ip string) (int64, error) {
+			return 1, nil
+		},
+	}
+	s := NewService(ms, nil, nil)
+	s.SetReputation(mockReputation{})
+
+	resp, _ := s.Fetch(context.Background(), "192.0.2.1")
+	if resp.Reputation == nil || !resp.Reputation.Listed {
+		t.Errorf("expected listing, got %+v", resp.Reputation)
+	}
+
+	resp, _ = s.Fetch(context.Background(), "10.0.0.1")
+	if resp.Reputation != nil {
+		t.Errorf("expected no reputation for private address, got %+v", resp.Reputation)
+	}
+}
+
+func TestServiceImpl_Count(t *testing.T) {
+	var counted []string
+	ms := &mockStore{
+		incrementCount: func(ctx context.Context, ip string) (int64, error) {
+			counted = append(counted, ip)
+			return int64(len(counted)), nil
+		},
+	}
+	s := NewService(ms, nil, nil)
+
+	if n, err := s.Count(context.Background(), "203.0.113.1"); err != nil || n != 1 {
+		t.Fatalf("Count returned %d, %v", n, err)
+	}
+	resp, _ := s.Fetch(context.Background(), "203.0.113.1")
+	if resp.CountCall != 2 {
+		t.Errorf("expected Fetch to share the counter, got %d", resp.CountCall)
+	}
+}
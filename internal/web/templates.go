@@ -6,12 +6,13 @@ import (
 	"html/template"
 )
 
-//go:embed templates/*.html
+//go:embed templates/*.html templates/sections/*.html
 var templatesFS embed.FS
 
-// ParseTemplates parses embedded HTML templates.
+// ParseTemplates parses the embedded page and its sections. Each section
+// is a partial named after its file, e.g. "webgl.html".
 func ParseTemplates() (*template.Template, error) {
-	tmpl, err := template.ParseFS(templatesFS, "templates/*.html")
+	tmpl, err := template.ParseFS(templatesFS, "templates/*.html", "templates/sections/*.html")
 	if err != nil {
 		return nil, fmt.Errorf("parse templates: %w", err)
 	}
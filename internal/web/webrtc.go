@@ -0,0 +1,156 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// WebRTCPath is the route where the page reports its ICE candidates.
+const WebRTCPath = "/api/webrtc"
+
+const (
+	maxWebRTCBodyBytes  = 16 << 10
+	maxWebRTCCandidates = 32
+)
+
+// WebRTC leak verdicts.
+const (
+	verdictLeak         = "leak"
+	verdictNoLeak       = "no_leak"
+	verdictNoCandidates = "no_public_candidates"
+)
+
+// STUNObservations tells whether the STUN responder answered an address
+// and where the ICE connectivity checks for a ufrag came from.
+type STUNObservations interface {
+	Seen(addr netip.AddrPort) bool
+	Checks(ufrag string) []netip.AddrPort
+}
+
+// webrtcUfrag is the ICE ufrag the page gave the server's side of the
+// connection: 16 to 64 ice-chars, random per visit.
+var webrtcUfrag = regexp.MustCompile(`^[A-Za-z0-9+/]{16,64}$`)
+
+type webrtcRequest struct {
+	Candidates []webrtcCandidate `json:"candidates"`
+	Ufrag      string            `json:"ufrag"`
+}
+
+type webrtcCandidate struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+	Port    uint16 `json:"port"`
+
+	Public        bool `json:"public"`
+	MatchesClient bool `json:"matches_client"`
+	// Observed is set for server reflexive candidates whose address and
+	// port the STUN responder saw, confirming the browser reported it
+	// faithfully.
+	Observed bool `json:"observed"`
+}
+
+type webrtcReport struct {
+	ClientIP   string            `json:"client_ip"`
+	Leak       bool              `json:"leak"`
+	Verdict    string            `json:"verdict"`
+	Detail     string            `json:"detail"`
+	Candidates []webrtcCandidate `json:"candidates"`
+	// Checks are the addresses the STUN responder received this visit's
+	// ICE connectivity checks from, whatever candidates the page reported.
+	Checks []string `json:"checks"`
+}
+
+// WebRTCHandler compares the ICE candidates gathered by the page, and the
+// sources of the connectivity checks it sent to the STUN responder, against
+// the address of the HTTP request. A public address of the same family
+// other than the client's means WebRTC bypasses the proxy or VPN in use.
+func WebRTCHandler(observations STUNObservations, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req webrtcRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebRTCBodyBytes)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if len(req.Candidates) > maxWebRTCCandidates {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("at most %d candidates are allowed", maxWebRTCCandidates))
+			return
+		}
+		if req.Ufrag != "" && !webrtcUfrag.MatchString(req.Ufrag) {
+			writeJSONError(w, http.StatusBadRequest, "invalid ufrag")
+			return
+		}
+
+		var checks []netip.AddrPort
+		if observations != nil && req.Ufrag != "" {
+			checks = observations.Checks(req.Ufrag)
+		}
+		report := compareCandidates(requestIP(r), req.Candidates, checks, observations)
+		if err := writeJSON(w, http.StatusOK, report); err != nil {
+			onError(err)
+		}
+	})
+}
+
+func compareCandidates(clientIP string, candidates []webrtcCandidate, checks []netip.AddrPort, observations STUNObservations) webrtcReport {
+	report := webrtcReport{ClientIP: clientIP, Candidates: []webrtcCandidate{}, Checks: []string{}}
+	client, _ := netip.ParseAddr(clientIP)
+	client = client.Unmap()
+
+	var leaked []string
+	public := 0
+	// compare counts a public address and records it as leaked unless it is
+	// the client's. An address of the other family is expected on dual
+	// stack connections and proves nothing.
+	compare := func(addr netip.Addr) {
+		if client.IsValid() && addr.Is4() != client.Is4() {
+			return
+		}
+		public++
+		if addr != client && !slices.Contains(leaked, addr.String()) {
+			leaked = append(leaked, addr.String())
+		}
+	}
+	for _, c := range candidates {
+		out := webrtcCandidate{Type: c.Type, Address: c.Address, Port: c.Port}
+		// mDNS host candidates (*.local) hide the address and never leak.
+		addr, err := netip.ParseAddr(c.Address)
+		if err == nil {
+			addr = addr.Unmap()
+			out.Public = addr.IsGlobalUnicast() && !addr.IsPrivate()
+			out.MatchesClient = addr == client
+			if observations != nil && c.Type == "srflx" {
+				out.Observed = observations.Seen(netip.AddrPortFrom(addr, c.Port))
+			}
+		}
+		if out.Public {
+			compare(addr)
+		}
+		report.Candidates = append(report.Candidates, out)
+	}
+	for _, check := range checks {
+		addr := check.Addr().Unmap()
+		report.Checks = append(report.Checks, netip.AddrPortFrom(addr, check.Port()).String())
+		if addr.IsGlobalUnicast() && !addr.IsPrivate() {
+			compare(addr)
+		}
+	}
+
+	switch {
+	case len(leaked) > 0:
+		report.Leak = true
+		report.Verdict = verdictLeak
+		report.Detail = fmt.Sprintf("WebRTC exposes %s while HTTP comes from %s", strings.Join(leaked, ", "), clientIP)
+	case public > 0:
+		report.Verdict = verdictNoLeak
+		report.Detail = "WebRTC public addresses match the HTTP client address"
+	default:
+		report.Verdict = verdictNoCandidates
+		report.Detail = "no public WebRTC addresses of the request's family; WebRTC or UDP may be blocked"
+	}
+	return report
+}
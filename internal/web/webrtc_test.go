@@ -0,0 +1,113 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type mockObservations struct {
+	seen   map[netip.AddrPort]bool
+	checks map[string][]netip.AddrPort
+}
+
+func (m mockObservations) Seen(addr netip.AddrPort) bool {
+	return m.seen[addr]
+}
+
+func (m mockObservations) Checks(ufrag string) []netip.AddrPort {
+	return m.checks[ufrag]
+}
+
+func TestWebRTCHandler(t *testing.T) {
+	observations := mockObservations{
+		seen: map[netip.AddrPort]bool{netip.MustParseAddrPort("198.51.100.9:50000"): true},
+		checks: map[string][]netip.AddrPort{
+			"0123456789abcdef": {netip.MustParseAddrPort("198.51.100.20:61000")},
+			"fedcba9876543210": {netip.MustParseAddrPort("203.0.113.1:61000")},
+		},
+	}
+	handler := WebRTCHandler(observations, func(error) {})
+
+	post := func(body string) (int, webrtcReport) {
+		req := httptest.NewRequest(http.MethodPost, WebRTCPath, strings.NewReader(body))
+		req.RemoteAddr = "203.0.113.1:4000"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var report webrtcReport
+		json.Unmarshal(rec.Body.Bytes(), &report)
+		return rec.Code, report
+	}
+
+	tests := map[string]struct {
+		body    string
+		verdict string
+	}{
+		"leak": {`{"candidates": [
+			{"type": "host", "address": "3c1b.local", "port": 1},
+			{"type": "srflx", "address": "198.51.100.9", "port": 50000}
+		]}`, verdictLeak},
+		"match":         {`{"candidates": [{"type": "srflx", "address": "203.0.113.1", "port": 1}]}`, verdictNoLeak},
+		"other family":  {`{"candidates": [{"type": "srflx", "address": "2001:db8::1", "port": 1}, {"type": "srflx", "address": "203.0.113.1", "port": 1}]}`, verdictNoLeak},
+		"check leak":    {`{"ufrag": "0123456789abcdef", "candidates": [{"type": "srflx", "address": "203.0.113.1", "port": 1}]}`, verdictLeak},
+		"check match":   {`{"ufrag": "fedcba9876543210", "candidates": []}`, verdictNoLeak},
+		"private only":  {`{"candidates": [{"type": "host", "address": "192.168.1.5", "port": 1}]}`, verdictNoCandidates},
+		"no candidates": {`{"candidates": []}`, verdictNoCandidates},
+	}
+	for name, tt := range tests {
+		code, report := post(tt.body)
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", name, code)
+		}
+		if report.Verdict != tt.verdict || report.Leak != (tt.verdict == verdictLeak) {
+			t.Errorf("%s: unexpected report %+v", name, report)
+		}
+		if report.ClientIP != "203.0.113.1" {
+			t.Errorf("%s: expected client IP from the request, got %q", name, report.ClientIP)
+		}
+	}
+
+	_, report := post(`{"candidates": [{"type": "srflx", "address": "198.51.100.9", "port": 50000}, {"type": "srflx", "address": "198.51.100.9", "port": 50001}]}`)
+	if !report.Candidates[0].Observed || report.Candidates[1].Observed {
+		t.Errorf("expected only the address seen by STUN to be observed, got %+v", report.Candidates)
+	}
+
+	_, report = post(`{"ufrag": "0123456789abcdef", "candidates": []}`)
+	if len(report.Checks) != 1 || report.Checks[0] != "198.51.100.20:61000" {
+		t.Errorf("expected the checks seen by STUN, got %v", report.Checks)
+	}
+	if code, _ := post(`{"ufrag": "short", "candidates": []}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid ufrag, got %d", code)
+	}
+
+	if code, _ := post(`not json`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid body, got %d", code)
+	}
+}
+
+func TestHandler_STUNPort(t *testing.T) {
+	tmpl, err := ParseTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &mockService{
+		fetch: func(ctx context.Context, ip string) (Response, error) {
+			return Response{IP: ip, CountCall: 1}, nil
+		},
+		onError: func(err error) { t.Error(err) },
+	}
+	h := NewHandler(tmpl, service)
+	h.SetSTUNPort(3478)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if !regexp.MustCompile(`const stunPort =\s*3478\s*;`).MatchString(rec.Body.String()) {
+		t.Error("expected the page to use the STUN responder")
+	}
+}
@@ -0,0 +1,95 @@
+package whoami
+
+import (
+	"net/netip"
+	"strings"
+
+	"myip/internal/dns"
+)
+
+const (
+	// Answers depend on who asks, so resolvers must not cache them.
+	answerTTL = 0
+	udpSize   = 1232
+)
+
+// Responder answers "what is my IP" queries for a single name, like
+// o-o.myaddr.l.google.com: TXT returns the address of the querying
+// resolver and the EDNS Client Subnet it forwarded, A and AAAA return the
+// querier's address.
+type Responder struct {
+	name string
+	soa  dns.RR
+}
+
+// New creates a responder authoritative for name.
+func New(name string) *Responder {
+	name = dns.CanonicalName(name)
+	return &Responder{
+		name: name,
+		soa:  dns.NewSOA(name, answerTTL),
+	}
+}
+
+// ServeDNS implements dns.Handler.
+func (r *Responder) ServeDNS(req *dns.Message, from netip.AddrPort, transport string) *dns.Message {
+	resp := req.Reply()
+	resp.Authoritative = true
+
+	var subnet *dns.ClientSubnet
+	if opt, ok := req.OPT(); ok {
+		var options []dns.Option
+		if subnet = dns.ClientSubnetOf(opt); subnet != nil {
+			// The answer varies with the subnet, so it is scoped to all of it.
+			subnet.ScopePrefix = uint8(subnet.Prefix.Bits())
+			options = append(options, subnet.Option())
+		}
+		resp.Additional = append(resp.Additional, dns.NewOPT(udpSize, options...))
+	}
+
+	switch {
+	case req.Opcode != 0:
+		resp.Rcode = dns.RcodeNotImp
+		return resp
+	case len(req.Questions) != 1:
+		resp.Rcode = dns.RcodeFormErr
+		return resp
+	}
+
+	q := req.Questions[0]
+	name := dns.CanonicalName(q.Name)
+	switch {
+	case name == r.name:
+	case strings.HasSuffix(name, "."+r.name):
+		resp.Rcode = dns.RcodeNXDomain
+		resp.Authority = append(resp.Authority, r.soa)
+		return resp
+	default:
+		resp.Authoritative = false
+		resp.Rcode = dns.RcodeRefused
+		return resp
+	}
+
+	addr := from.Addr()
+	answer := dns.RR{Name: q.Name, Type: q.Type, Class: dns.ClassINET, TTL: answerTTL}
+	switch {
+	case q.Type == dns.TypeTXT:
+		answer.TXT = []string{addr.String()}
+		if subnet != nil {
+			ecs := answer
+			ecs.TXT = []string{"edns0-client-subnet " + subnet.Prefix.String()}
+			resp.Answers = append(resp.Answers, answer, ecs)
+			return resp
+		}
+	case q.Type == dns.TypeA && addr.Is4(), q.Type == dns.TypeAAAA && addr.Is6():
+		answer.Addr = addr
+	case q.Type == dns.TypeSOA:
+		answer = r.soa
+	default:
+		// The name exists but has no data of this type.
+		resp.Authority = append(resp.Authority, r.soa)
+		return resp
+	}
+	resp.Answers = append(resp.Answers, answer)
+	return resp
+}
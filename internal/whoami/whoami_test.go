@@ -0,0 +1,71 @@
+package whoami
+
+import (
+	"net/netip"
+	"testing"
+
+	"myip/internal/dns"
+)
+
+func query(name string, qtype uint16, additional ...dns.RR) *dns.Message {
+	return &dns.Message{
+		Header:     dns.Header{ID: 1, RecursionDesired: true},
+		Questions:  []dns.Question{{Name: name, Type: qtype, Class: dns.ClassINET}},
+		Additional: additional,
+	}
+}
+
+func TestResponder_ServeDNS(t *testing.T) {
+	r := New("WhoAmI.Example.com")
+	v4 := netip.MustParseAddrPort("198.51.100.7:5353")
+	v6 := netip.MustParseAddrPort("[2001:db8::7]:5353")
+
+	resp := r.ServeDNS(query("whoami.example.com.", dns.TypeTXT), v4, "udp")
+	if resp.Rcode != dns.RcodeSuccess || !resp.Authoritative || len(resp.Answers) != 1 || resp.Answers[0].TXT[0] != "198.51.100.7" {
+		t.Errorf("unexpected TXT response %+v", resp)
+	}
+	if resp.Answers[0].TTL != 0 {
+		t.Errorf("expected uncacheable answer, got TTL %d", resp.Answers[0].TTL)
+	}
+
+	resp = r.ServeDNS(query("whoami.example.com.", dns.TypeA), v4, "udp")
+	if len(resp.Answers) != 1 || resp.Answers[0].Addr != v4.Addr() {
+		t.Errorf("unexpected A response %+v", resp)
+	}
+	resp = r.ServeDNS(query("whoami.example.com.", dns.TypeA), v6, "udp")
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answers) != 0 || len(resp.Authority) != 1 {
+		t.Errorf("expected NODATA for A over IPv6, got %+v", resp)
+	}
+	resp = r.ServeDNS(query("whoami.example.com.", dns.TypeAAAA), v6, "tcp")
+	if len(resp.Answers) != 1 || resp.Answers[0].Addr != v6.Addr() {
+		t.Errorf("unexpected AAAA response %+v", resp)
+	}
+
+	if resp = r.ServeDNS(query("x.whoami.example.com.", dns.TypeA), v4, "udp"); resp.Rcode != dns.RcodeNXDomain {
+		t.Errorf("expected NXDOMAIN below the name, got %+v", resp)
+	}
+	if resp = r.ServeDNS(query("example.org.", dns.TypeA), v4, "udp"); resp.Rcode != dns.RcodeRefused || resp.Authoritative {
+		t.Errorf("expected REFUSED for other names, got %+v", resp)
+	}
+}
+
+func TestResponder_ClientSubnet(t *testing.T) {
+	r := New("whoami.example.com")
+	subnet := dns.ClientSubnet{Prefix: netip.MustParsePrefix("203.0.113.0/24")}
+	req := query("whoami.example.com.", dns.TypeTXT, dns.NewOPT(4096, subnet.Option()))
+
+	resp := r.ServeDNS(req, netip.MustParseAddrPort("198.51.100.7:5353"), "udp")
+	if len(resp.Answers) != 2 || resp.Answers[1].TXT[0] != "edns0-client-subnet 203.0.113.0/24" {
+		t.Fatalf("expected client subnet answer, got %+v", resp.Answers)
+	}
+
+	opt, ok := resp.OPT()
+	if !ok {
+		t.Fatal("expected OPT in response")
+	}
+	options, _ := dns.ParseOptions(opt)
+	echoed, err := dns.ParseClientSubnet(options[0].Data)
+	if err != nil || echoed.Prefix != subnet.Prefix || echoed.ScopePrefix != 24 {
+		t.Errorf("expected subnet echoed with scope /24, got %+v, %v", echoed, err)
+	}
+}